@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/zaquestion/lab/internal/git"
+)
+
+var apiCmd = &cobra.Command{
+	Use:   "api <method> <path>",
+	Short: "Make an authenticated request to the GitLab API",
+	Long: `Make a request to the GitLab v4 API using the configured host and token,
+printing the response as pretty JSON.
+
+The path is relative to /api/v4. The placeholders :id and :fullpath are
+replaced with the project of the current repository, URL encoded for :id.
+
+Fields given with -f are sent as the query string for GET and DELETE requests,
+and as a JSON object otherwise. Without fields, a JSON body may be piped on
+stdin.`,
+	Example: `lab api get projects/:id/merge_requests
+lab api get projects/:id/issues --paginate
+lab api post projects/:id/issues -f title="Found a bug" -f labels=bug
+echo '{"title": "Found a bug"}' | lab api post projects/:id/issues`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		method := strings.ToUpper(args[0])
+		fields, err := cmd.Flags().GetStringArray("field")
+		if err != nil {
+			log.Fatal(err)
+		}
+		paginate, err := cmd.Flags().GetBool("paginate")
+		if err != nil {
+			log.Fatal(err)
+		}
+		if paginate && method != http.MethodGet {
+			log.Fatal("--paginate can only be used with GET requests")
+		}
+
+		path, query, err := apiPath(args[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+		var body []byte
+		switch method {
+		case http.MethodGet, http.MethodDelete, http.MethodHead:
+			if err := parseAPIFields(fields, query); err != nil {
+				log.Fatal(err)
+			}
+		default:
+			body, err = apiBody(fields, os.Stdin)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		if paginate {
+			if err := printAPIList(os.Stdout, path, query); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+		b, _, err := labClient.APIRequest(ctx, method, path, query, body)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := printAPIResponse(os.Stdout, b); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+// apiPath expands the placeholders of path and splits off its query string.
+// The client is switched to the host of the current remote, when there is
+// one.
+func apiPath(path string) (string, url.Values, error) {
+	path = strings.TrimPrefix(path, "/")
+	path = strings.TrimPrefix(path, "api/v4/")
+	var rawQuery string
+	if i := strings.Index(path, "?"); i >= 0 {
+		path, rawQuery = path[:i], path[i+1:]
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var rn string
+	if git.InsideGitRepo() {
+		rn, err = projectForRemote(forkedFromRemote)
+	}
+	if strings.Contains(path, ":id") || strings.Contains(path, ":fullpath") {
+		if rn == "" {
+			if err == nil {
+				err = errors.New("not in a git repository")
+			}
+			return "", nil, errors.Wrap(err, "cannot expand :id and :fullpath")
+		}
+		path = strings.NewReplacer(
+			":id", url.QueryEscape(rn),
+			":fullpath", rn,
+		).Replace(path)
+	}
+	return path, query, nil
+}
+
+// parseAPIFields adds the key=value fields to values
+func parseAPIFields(fields []string, values url.Values) error {
+	for _, f := range fields {
+		kv := strings.SplitN(f, "=", 2)
+		if len(kv) != 2 {
+			return errors.Errorf("field %q must be formatted as key=value", f)
+		}
+		values.Add(kv[0], kv[1])
+	}
+	return nil
+}
+
+// apiBody returns the JSON body of a request, made of fields, or read from
+// stdin when no fields are given. A terminal on stdin is not read.
+func apiBody(fields []string, stdin *os.File) ([]byte, error) {
+	if len(fields) > 0 {
+		values := url.Values{}
+		if err := parseAPIFields(fields, values); err != nil {
+			return nil, err
+		}
+		obj := make(map[string]interface{}, len(values))
+		for k, v := range values {
+			if len(v) == 1 {
+				obj[k] = v[0]
+			} else {
+				obj[k] = v
+			}
+		}
+		return json.Marshal(obj)
+	}
+
+	fi, err := stdin.Stat()
+	if err != nil || fi.Mode()&os.ModeCharDevice != 0 {
+		return nil, nil
+	}
+	b, err := ioutil.ReadAll(stdin)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(b)) == 0 {
+		return nil, nil
+	}
+	if !json.Valid(b) {
+		return nil, errors.New("the body read from stdin is not valid JSON")
+	}
+	return b, nil
+}
+
+// printAPIResponse pretty-prints a JSON response body. Other bodies, such as
+// job traces, are printed as they are.
+func printAPIResponse(w io.Writer, b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, b, "", "  "); err != nil {
+		_, err = w.Write(b)
+		return err
+	}
+	buf.WriteByte('\n')
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// printAPIList prints all the pages of the list at path as a single JSON
+// list, printing each page as it arrives
+func printAPIList(w io.Writer, path string, query url.Values) error {
+	it := labClient.APIRequestIter(ctx, path, query)
+	defer it.Close()
+	fmt.Fprint(w, "[")
+	sep := "\n  "
+	for it.Next() {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, it.Value().(json.RawMessage), "  ", "  "); err != nil {
+			return err
+		}
+		fmt.Fprint(w, sep)
+		buf.WriteTo(w)
+		sep = ",\n  "
+	}
+	if err := it.Err(); err != nil {
+		fmt.Fprintln(w)
+		return err
+	}
+	if sep == "\n  " {
+		fmt.Fprintln(w, "]")
+		return nil
+	}
+	fmt.Fprintln(w, "\n]")
+	return nil
+}
+
+func init() {
+	apiCmd.Flags().StringArrayP("field", "f", nil, "Add a key=value field to the request, may be repeated")
+	apiCmd.Flags().Bool("paginate", false, "Fetch all the pages of a list, printing them as a single list")
+	apiCmd.MarkZshCompPositionalArgumentWords(1, "get", "post", "put", "delete")
+	RootCmd.AddCommand(apiCmd)
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_api(t *testing.T) {
+	t.Parallel()
+	repo := copyTestRepo(t)
+	cmd := exec.Command(labBinaryPath, "api", "get", "projects/:id")
+	cmd.Dir = repo
+
+	b, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Log(string(b))
+		t.Fatal(err)
+	}
+	assert.Contains(t, string(b), `"path_with_namespace": "zaquestion/test"`)
+}
+
+func Test_apiPaginate(t *testing.T) {
+	t.Parallel()
+	repo := copyTestRepo(t)
+	cmd := exec.Command(labBinaryPath, "api", "get", "projects/:id/labels?per_page=2", "--paginate")
+	cmd.Dir = repo
+
+	b, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var labels []map[string]interface{}
+	out := strings.Join(getAppOutput(b), "\n")
+	require.NoError(t, json.Unmarshal([]byte(out), &labels))
+	assert.True(t, len(labels) > 2, "Expected more than one page of labels")
+}
+
+func Test_apiFields(t *testing.T) {
+	t.Parallel()
+	repo := copyTestRepo(t)
+	cmd := exec.Command(labBinaryPath, "api", "post", "projects/lab-testing%2Ftest/issues",
+		"-f", "title=api issue", "-f", "description=created by lab api")
+	cmd.Dir = repo
+
+	b, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Log(string(b))
+		t.Fatal(err)
+	}
+	assert.Contains(t, string(b), `"title": "api issue"`)
+}
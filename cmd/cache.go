@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: `Manage the cache of GitLab projects and users`,
+	Long: `lab caches slow-changing objects, such as projects, labels and users, so
+that repeated commands and completions don't have to look them up again. The
+cache lives in $XDG_CACHE_HOME/lab (~/.cache/lab by default).`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+		return
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(cacheCmd)
+}
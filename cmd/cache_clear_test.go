@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_cacheClear(t *testing.T) {
+	repo := copyTestRepo(t)
+	cmd := exec.Command(labBinaryPath, "label", "list")
+	cmd.Dir = repo
+	b, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(b))
+
+	dir := filepath.Join(os.Getenv("XDG_CACHE_HOME"), "lab")
+	_, err = os.Stat(dir)
+	require.NoError(t, err, "Expected the project and labels to be cached")
+
+	cmd = exec.Command(labBinaryPath, "cache", "clear")
+	cmd.Dir = repo
+	b, err = cmd.CombinedOutput()
+	require.NoError(t, err, string(b))
+	_, err = os.Stat(dir)
+	require.True(t, os.IsNotExist(err), "Expected the cache to be removed")
+}
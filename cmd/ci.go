@@ -1,7 +1,14 @@
 package cmd
 
 import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	gitlab "github.com/xanzy/go-gitlab"
+	"github.com/zaquestion/lab/internal/git"
+	lab "github.com/zaquestion/lab/internal/gitlab"
 )
 
 // ciCmd represents the ci command
@@ -11,6 +18,93 @@ var ciCmd = &cobra.Command{
 	Long:  ``,
 }
 
+// addCIPipelineFlags adds the flags picking another pipeline than the latest
+// one of the branch to cmd
+func addCIPipelineFlags(cmd *cobra.Command) {
+	cmd.Flags().Int("pipeline", 0, "Use the pipeline with the given ID")
+	cmd.Flags().String("sha", "", "Use the latest pipeline of the given commit, such as HEAD~1")
+	cmd.Flags().Int("mr", 0, "Use the latest pipeline of the given merge request, such as its detached or merged result pipeline")
+}
+
+// ciPipelineID returns the ID of the pipeline of the project pid picked by
+// the flags added by addCIPipelineFlags, or else of the latest pipeline of
+// branch. It is an error when there is no such pipeline.
+func ciPipelineID(cmd *cobra.Command, pid interface{}, branch string) (int, error) {
+	id, _ := cmd.Flags().GetInt("pipeline")
+	sha, _ := cmd.Flags().GetString("sha")
+	mrNum, _ := cmd.Flags().GetInt("mr")
+	set := 0
+	for _, name := range []string{"pipeline", "sha", "mr"} {
+		if cmd.Flags().Changed(name) {
+			set++
+		}
+	}
+	if set > 1 {
+		return 0, errors.New("--pipeline, --sha and --mr can't be used together")
+	}
+
+	var (
+		err  error
+		what string
+	)
+	switch {
+	case id > 0:
+		return id, nil
+	case sha != "":
+		var commit string
+		commit, err = git.RevParse(sha)
+		if err != nil {
+			return 0, err
+		}
+		what = "commit " + commit
+		id, err = labClient.CILatestPipeline(ctx, pid, gitlab.ListProjectPipelinesOptions{SHA: &commit})
+	case mrNum > 0:
+		what = fmt.Sprintf("merge request !%d", mrNum)
+		it := labClient.CIMRPipelineListIter(ctx, pid, mrNum, 1)
+		if it.Next() {
+			id = it.Value().(*lab.Pipeline).ID
+		}
+		err = it.Err()
+		it.Close()
+	default:
+		what = "branch " + branch
+		id, err = labClient.CILatestPipeline(ctx, pid, gitlab.ListProjectPipelinesOptions{Ref: &branch})
+	}
+	if err != nil {
+		return 0, err
+	}
+	if id == 0 {
+		return 0, errors.Errorf("no pipeline found for %s", what)
+	}
+	return id, nil
+}
+
+// ciJobArgs parses the [remote [[branch:]job]] arguments of the ci commands
+// working with a job. The branch defaults to the current one and the remote
+// to the one it is pushed to.
+func ciJobArgs(args []string) (remote, branch, jobName string, err error) {
+	branch, err = git.CurrentBranch()
+	if err != nil {
+		return "", "", "", err
+	}
+	if len(args) > 1 {
+		jobName = args[1]
+		if strings.Contains(args[1], ":") {
+			ps := strings.SplitN(args[1], ":", 2)
+			branch, jobName = ps[0], ps[1]
+		}
+	}
+	remote = determineSourceRemote(branch)
+	if len(args) > 0 {
+		ok, err := git.IsRemote(args[0])
+		if err != nil || !ok {
+			return "", "", "", errors.Errorf("%s is not a remote: %v", args[0], err)
+		}
+		remote = args[0]
+	}
+	return remote, branch, jobName, nil
+}
+
 func init() {
 	RootCmd.AddCommand(ciCmd)
 }
@@ -0,0 +1,250 @@
+package cmd
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+var ciArtifactsCmd = &cobra.Command{
+	Use:   "artifacts [remote [[branch:]job]]",
+	Short: "Download the artifacts of a ci job",
+	Long: `Download the artifacts archive of a job and extract it, or only the files
+under the paths given with --path, to a directory. --list shows the files of
+the archive instead.
+
+The job is picked like lab ci trace does: if a job is not specified the latest
+running job or last job in the pipeline is used. Unlike lab ci trace, a named
+job which isn't in the pipeline is an error. The pipeline is the latest
+one of the branch unless picked with --pipeline, --sha or --mr.
+
+--latest gets the artifacts of the named job in the latest successful pipeline
+of the branch, without looking the job up first.`,
+	Example: `lab ci artifacts origin build              # extract the artifacts of build
+lab ci artifacts origin build -d /tmp/out  # extract them to /tmp/out
+lab ci artifacts origin build -p coverage  # extract coverage/ only
+lab ci artifacts origin build -p '*.xml'   # extract the XML files at the top
+lab ci artifacts origin master:build --latest --list`,
+	Args: cobra.MaximumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		remote, branch, jobName, err := ciJobArgs(args)
+		if err != nil {
+			log.Fatal(err)
+		}
+		dir, _ := cmd.Flags().GetString("dir")
+		paths, _ := cmd.Flags().GetStringSlice("path")
+		list, _ := cmd.Flags().GetBool("list")
+		latest, _ := cmd.Flags().GetBool("latest")
+
+		rn, err := projectForRemote(remote)
+		if err != nil {
+			log.Fatal(err)
+		}
+		project, err := labClient.FindProject(ctx, rn)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		var (
+			download func(io.Writer) error
+			from     string
+		)
+		if latest {
+			if jobName == "" {
+				log.Fatal("--latest needs the name of a job")
+			}
+			for _, name := range []string{"pipeline", "sha", "mr"} {
+				if cmd.Flags().Changed(name) {
+					log.Fatal("--latest can't be used with --pipeline, --sha or --mr")
+				}
+			}
+			from = fmt.Sprintf("the latest %s job of %s", jobName, branch)
+			download = func(w io.Writer) error {
+				return labClient.CILatestArtifacts(ctx, project.ID, branch, jobName, w)
+			}
+		} else {
+			pipelineID, err := ciPipelineID(cmd, project.ID, branch)
+			if err != nil {
+				log.Fatal(err)
+			}
+			job, err := labClient.CIJob(ctx, project.ID, pipelineID, jobName)
+			if err != nil {
+				log.Fatal(errors.Wrap(err, "failed to find job"))
+			}
+			if job == nil {
+				log.Fatalf("pipeline #%d has no jobs", pipelineID)
+			}
+			// CIJob falls back to another job, whose artifacts are
+			// not the ones asked for
+			if jobName != "" && job.Name != jobName {
+				log.Fatalf("no %s job in pipeline #%d", jobName, pipelineID)
+			}
+			from = fmt.Sprintf("%s job #%d", job.Name, job.ID)
+			if job.ArtifactsFile.Filename == "" {
+				log.Fatalf("%s has no artifacts", from)
+			}
+			download = func(w io.Writer) error {
+				return labClient.CIArtifacts(ctx, project.ID, job.ID, w)
+			}
+		}
+
+		archive, err := downloadArtifacts(download)
+		if err != nil {
+			log.Fatal(ciArtifactsError(err, from))
+		}
+		err = readArtifacts(archive, from, dir, paths, list)
+		os.Remove(archive)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+// downloadArtifacts writes an artifacts archive to a temporary file, whose
+// name it returns, as archives may be too large to hold in memory
+func downloadArtifacts(download func(io.Writer) error) (string, error) {
+	f, err := ioutil.TempFile("", "lab-artifacts-*.zip")
+	if err != nil {
+		return "", err
+	}
+	err = download(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// readArtifacts lists or extracts the files of the artifacts archive of from
+// under paths, as ci artifacts was told to
+func readArtifacts(archive, from, dir string, paths []string, list bool) error {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read the artifacts of %s", from)
+	}
+	defer zr.Close()
+	if list {
+		for _, f := range zr.File {
+			if artifactMatches(f.Name, paths) {
+				fmt.Printf("%10d  %s\n", f.UncompressedSize64, f.Name)
+			}
+		}
+		return nil
+	}
+	n, err := extractArtifacts(&zr.Reader, dir, paths)
+	if err != nil {
+		return err
+	}
+	if n == 0 && len(paths) > 0 {
+		return errors.Errorf("no artifacts of %s match %s", from, strings.Join(paths, ", "))
+	}
+	fmt.Printf("Extracted %d files from the artifacts of %s to %s\n", n, from, dir)
+	return nil
+}
+
+// ciArtifactsError turns the 404 returned for jobs without artifacts into a
+// readable error
+func ciArtifactsError(err error, from string) error {
+	if e, ok := err.(*gitlab.ErrorResponse); ok && e.Response.StatusCode == 404 {
+		return errors.Errorf("%s has no artifacts", from)
+	}
+	return errors.Wrapf(err, "failed to download the artifacts of %s", from)
+}
+
+// artifactMatches reports whether the file name of an artifacts archive is
+// one of paths, is under one of them or matches one of them as a pattern.
+// Every file matches when there are no paths.
+func artifactMatches(name string, paths []string) bool {
+	if len(paths) == 0 {
+		return true
+	}
+	name = strings.TrimSuffix(name, "/")
+	for _, p := range paths {
+		p = strings.Trim(path.Clean(filepath.ToSlash(p)), "/")
+		if name == p || strings.HasPrefix(name, p+"/") {
+			return true
+		}
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// extractArtifacts extracts the files of zr matching paths, as
+// artifactMatches does, under dir and returns how many it wrote. Files which
+// would end up outside of dir are an error.
+func extractArtifacts(zr *zip.Reader, dir string, paths []string) (int, error) {
+	n := 0
+	for _, f := range zr.File {
+		if !artifactMatches(f.Name, paths) {
+			continue
+		}
+		target := filepath.Join(dir, filepath.FromSlash(f.Name))
+		rel, err := filepath.Rel(dir, target)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return n, errors.Errorf("artifact %s would be extracted outside of %s", f.Name, dir)
+		}
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return n, err
+			}
+			continue
+		}
+		if err := extractArtifact(f, target); err != nil {
+			return n, errors.Wrapf(err, "failed to extract %s", f.Name)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// extractArtifact writes the file f of an artifacts archive to target
+func extractArtifact(f *zip.File, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	mode := f.Mode().Perm()
+	if mode == 0 {
+		mode = 0644
+	}
+	w, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func init() {
+	ciArtifactsCmd.Flags().StringP("dir", "d", ".", "Directory to extract the artifacts to")
+	ciArtifactsCmd.Flags().StringSliceP("path", "p", []string{}, "Only extract or list the artifacts under the given paths, which may be patterns")
+	ciArtifactsCmd.Flags().BoolP("list", "l", false, "List the files of the artifacts archive instead of extracting them")
+	ciArtifactsCmd.Flags().Bool("latest", false, "Get the artifacts of the named job in the latest successful pipeline of the branch")
+	addCIPipelineFlags(ciArtifactsCmd)
+	ciArtifactsCmd.MarkZshCompPositionalArgumentCustom(1, "__lab_completion_remote")
+	ciArtifactsCmd.MarkZshCompPositionalArgumentCustom(2, "__lab_completion_remote_branches $words[2]")
+	ciArtifactsCmd.MarkFlagDirname("dir")
+	ciCmd.AddCommand(ciArtifactsCmd)
+}
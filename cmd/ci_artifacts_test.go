@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ciArtifacts(t *testing.T) {
+	skipIfLive(t)
+	t.Parallel()
+	repo := copyTestRepo(t)
+
+	out := runLab(t, repo, "ci", "artifacts", "origin", "ci_test_pipeline:build1", "--list")
+	assert.Equal(t, []string{
+		"        11  bin/lab",
+		"        18  coverage/index.html",
+		"        10  coverage/lab.out",
+	}, getAppOutput([]byte(out)))
+
+	dir, err := ioutil.TempDir("", "lab-artifacts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	out = runLab(t, repo, "ci", "artifacts", "origin", "ci_test_pipeline:build1", "-d", dir, "-p", "coverage")
+	assert.Regexp(t, `Extracted 2 files from the artifacts of build1 job #\d+ to `+dir, out)
+	b, err := ioutil.ReadFile(filepath.Join(dir, "coverage", "lab.out"))
+	require.NoError(t, err)
+	assert.Equal(t, "mode: set\n", string(b))
+	_, err = os.Stat(filepath.Join(dir, "bin", "lab"))
+	assert.True(t, os.IsNotExist(err), err)
+
+	out = runLab(t, repo, "ci", "artifacts", "origin", "ci_test_pipeline:build1", "--latest", "-d", dir)
+	assert.Contains(t, out, "Extracted 3 files from the artifacts of the latest build1 job of ci_test_pipeline to "+dir)
+	assert.FileExists(t, filepath.Join(dir, "bin", "lab"))
+
+	out, err = runLabErr(repo, "ci", "artifacts", "origin", "ci_test_pipeline:build1", "-p", "docs", "-d", dir)
+	require.Error(t, err)
+	assert.Regexp(t, `no artifacts of build1 job #\d+ match docs`, out)
+
+	out, err = runLabErr(repo, "ci", "artifacts", "origin", "ci_test_pipeline:bulid1", "--list")
+	require.Error(t, err)
+	assert.Regexp(t, `no bulid1 job in pipeline #\d+`, out)
+
+	out, err = runLabErr(repo, "ci", "artifacts", "origin", "ci_test_pipeline:build2", "-d", dir)
+	require.Error(t, err)
+	assert.Regexp(t, `build2 job #\d+ has no artifacts`, out)
+
+	out, err = runLabErr(repo, "ci", "artifacts", "origin", "ci_test_pipeline:build2", "--latest", "-d", dir)
+	require.Error(t, err)
+	assert.Contains(t, out, "the latest build2 job of ci_test_pipeline has no artifacts")
+}
+
+func Test_artifactMatches(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		paths []string
+		want  bool
+	}{
+		{"bin/lab", nil, true},
+		{"bin/lab", []string{"bin"}, true},
+		{"bin/lab", []string{"bin/"}, true},
+		{"bin/lab", []string{"./bin/lab"}, true},
+		{"binaries/lab", []string{"bin"}, false},
+		{"report.xml", []string{"*.xml"}, true},
+		{"reports/unit.xml", []string{"*.xml"}, false},
+		{"reports/unit.xml", []string{"docs", "reports/*.xml"}, true},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.want, artifactMatches(test.name, test.paths), "%s %v", test.name, test.paths)
+	}
+}
+
+func Test_extractArtifactsOutsideDir(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	f, err := zw.Create("../escaped")
+	require.NoError(t, err)
+	f.Write([]byte("oops"))
+	require.NoError(t, zw.Close())
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "lab-artifacts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	n, err := extractArtifacts(zr, filepath.Join(dir, "out"), nil)
+	assert.EqualError(t, err, "artifact ../escaped would be extracted outside of "+filepath.Join(dir, "out"))
+	assert.Equal(t, 0, n)
+	_, err = os.Stat(filepath.Join(dir, "escaped"))
+	assert.True(t, os.IsNotExist(err), err)
+}
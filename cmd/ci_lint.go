@@ -8,7 +8,6 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
-	lab "github.com/zaquestion/lab/internal/gitlab"
 )
 
 // ciLintCmd represents the lint command
@@ -25,7 +24,7 @@ var ciLintCmd = &cobra.Command{
 		if !os.IsNotExist(err) && err != nil {
 			log.Fatal(err)
 		}
-		ok, err := lab.Lint(string(b))
+		ok, err := labClient.Lint(ctx, string(b))
 		if !ok || err != nil {
 			log.Fatal(errors.Wrap(err, "ci yaml invalid"))
 		}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	gitlab "github.com/xanzy/go-gitlab"
+	"github.com/zaquestion/lab/internal/git"
+	lab "github.com/zaquestion/lab/internal/gitlab"
+)
+
+var ciListCmd = &cobra.Command{
+	Use:     "list [remote [branch]]",
+	Aliases: []string{"ls"},
+	Short:   "List the recent pipelines of a project",
+	Long: `List the recent pipelines of a project, the newest first, with their status,
+ref, source, duration and the user who started them. A branch, --sha or --mr
+lists the pipelines of a branch, a commit or a merge request only.
+
+The duration and user take an extra request for each pipeline.`,
+	Example: `lab ci list                   # list the latest pipelines of the project
+lab ci list origin master     # list the pipelines of master
+lab ci list -s failed -n 20   # list the 20 latest failed pipelines
+lab ci list --sha HEAD        # list the pipelines of the checked out commit
+lab ci list --mr 12           # list the pipelines of merge request 12`,
+	Args: cobra.MaximumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		branch, err := git.CurrentBranch()
+		if err != nil {
+			log.Fatal(err)
+		}
+		remote := determineSourceRemote(branch)
+		if len(args) > 0 {
+			ok, err := git.IsRemote(args[0])
+			if err != nil || !ok {
+				log.Fatal(args[0], " is not a remote:", err)
+			}
+			remote = args[0]
+		}
+		rn, err := projectForRemote(remote)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		num, _ := cmd.Flags().GetInt("number")
+		if all, _ := cmd.Flags().GetBool("all"); all {
+			num = -1
+		}
+		status, _ := cmd.Flags().GetString("status")
+		sha, _ := cmd.Flags().GetString("sha")
+		mrNum, _ := cmd.Flags().GetInt("mr")
+
+		var it *lab.Iterator
+		if mrNum > 0 {
+			// GitLab doesn't filter the pipelines of merge requests
+			if len(args) > 1 || status != "" || sha != "" {
+				log.Fatal("--mr can't be used with a branch, --status or --sha")
+			}
+			it = labClient.CIMRPipelineListIter(ctx, rn, mrNum, num)
+		} else {
+			var opts gitlab.ListProjectPipelinesOptions
+			if len(args) > 1 {
+				opts.Ref = &args[1]
+			}
+			if status != "" {
+				opts.Status = gitlab.BuildState(gitlab.BuildStateValue(status))
+			}
+			if sha != "" {
+				commit, err := git.RevParse(sha)
+				if err != nil {
+					log.Fatal(err)
+				}
+				opts.SHA = &commit
+			}
+			it = labClient.CIPipelineListIter(ctx, rn, opts, num)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, byte(' '), 0)
+		err = printList(os.Stdout, it, func(v interface{}) {
+			// Only single pipelines come with their duration and user
+			p, err := labClient.CIPipeline(ctx, rn, v.(*lab.Pipeline).ID)
+			if err != nil {
+				log.Fatal(errors.Wrapf(err, "failed to get pipeline %d", v.(*lab.Pipeline).ID))
+			}
+			fmt.Fprintf(w, "#%d\t%s\t%s\t%s\t%s\t%s\n", p.ID, p.Status, p.Ref,
+				orDash(p.Source), orDash(ciPipelineDuration(p, time.Now())), orDash(p.User.Username))
+		})
+		w.Flush()
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+// ciPipelineDuration returns how long a pipeline ran, or has been running
+// by now, or "" when it didn't start
+func ciPipelineDuration(p *lab.Pipeline, now time.Time) string {
+	if p.Duration > 0 {
+		return (time.Duration(p.Duration) * time.Second).String()
+	}
+	if p.StartedAt == nil {
+		return ""
+	}
+	end := now
+	if p.FinishedAt != nil {
+		end = *p.FinishedAt
+	}
+	return end.Sub(*p.StartedAt).Round(time.Second).String()
+}
+
+// orDash returns s, or "-" when it is empty to keep the columns of a table
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func init() {
+	ciListCmd.Flags().IntP("number", "n", 10, "Number of pipelines to list")
+	ciListCmd.Flags().BoolP("all", "a", false, "List all the pipelines")
+	ciListCmd.Flags().StringP("status", "s", "", "List the pipelines with the given status, such as running or failed")
+	ciListCmd.Flags().String("sha", "", "List the pipelines of the given commit, such as HEAD~1")
+	ciListCmd.Flags().Int("mr", 0, "List the pipelines of the given merge request")
+	addFormatFlag(ciListCmd)
+	ciListCmd.MarkZshCompPositionalArgumentCustom(1, "__lab_completion_remote")
+	ciListCmd.MarkZshCompPositionalArgumentCustom(2, "__lab_completion_remote_branches $words[2]")
+	ciListCmd.MarkFlagCustom("status", "(created pending running success failed canceled skipped manual)")
+	ciCmd.AddCommand(ciListCmd)
+}
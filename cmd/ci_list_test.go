@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	lab "github.com/zaquestion/lab/internal/gitlab"
+)
+
+func Test_ciList(t *testing.T) {
+	skipIfLive(t)
+	t.Parallel()
+	repo := copyTestRepo(t)
+
+	out := getAppOutput([]byte(runLab(t, repo, "ci", "list", "origin", "ci_test_pipeline")))
+	require.Len(t, out, 1)
+	assert.Regexp(t, regexp.MustCompile(`^#\d+ +success +ci_test_pipeline +push +- +-$`), out[0])
+
+	out = getAppOutput([]byte(runLab(t, repo, "ci", "list", "origin", "--mr", "1")))
+	require.Len(t, out, 1)
+	assert.Regexp(t, regexp.MustCompile(`^#\d+ +failed +refs/merge-requests/1/head +push +1m35s +zaquestion$`), out[0])
+
+	out = getAppOutput([]byte(runLab(t, repo, "ci", "list", "origin", "-s", "failed", "--sha", "mrtest")))
+	require.Len(t, out, 1)
+	assert.Contains(t, out[0], "refs/merge-requests/1/head")
+}
+
+func Test_ciPipelineDuration(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC)
+	started := now.Add(-90 * time.Second)
+
+	p := &lab.Pipeline{}
+	assert.Equal(t, "", ciPipelineDuration(p, now))
+	p.StartedAt = &started
+	assert.Equal(t, "1m30s", ciPipelineDuration(p, now))
+	p.Duration = 42
+	assert.Equal(t, "42s", ciPipelineDuration(p, now))
+}
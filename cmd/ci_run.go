@@ -4,13 +4,13 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	gitlab "github.com/xanzy/go-gitlab"
 	"github.com/zaquestion/lab/internal/git"
-	lab "github.com/zaquestion/lab/internal/gitlab"
 )
 
 // ciCreateCmd represents the run command
@@ -22,26 +22,98 @@ var ciCreateCmd = &cobra.Command{
 
 Project will be inferred from branch if not provided
 
+Variables are given with -v, or read from the dotenv file given with
+--variable-file, such as one written by lab ci variable export. -v takes
+precedence over the file.
+
+--wait follows the pipeline until it finishes like lab ci status --wait does,
+and exits with the same codes.
+
 Note: "lab ci create" differs from "lab ci trigger" which is a different API`,
 	Example: `lab ci create feature_branch
-lab ci create -p engineering/integration_tests master`,
+lab ci create -p engineering/integration_tests master
+lab ci create -v DEPLOY=true -v ENVIRONMENT=staging master
+lab ci create --variable-file ci.env --wait --web`,
 	Run: func(cmd *cobra.Command, args []string) {
 		pid, branch, err := getCIRunOptions(cmd, args)
 		if err != nil {
 			log.Fatal(err)
 		}
-		pipeline, err := lab.CICreate(pid, &gitlab.CreatePipelineOptions{Ref: &branch})
+		vars, err := ciCreateVariables(cmd)
 		if err != nil {
 			log.Fatal(err)
 		}
-		project, err := lab.GetProject(pid)
+		pipeline, err := labClient.CICreate(ctx, pid, &gitlab.CreatePipelineOptions{
+			Ref:       &branch,
+			Variables: vars,
+		})
 		if err != nil {
 			log.Fatal(err)
 		}
-		fmt.Printf("%s/pipelines/%d\n", project.WebURL, pipeline.ID)
+		project, err := labClient.GetProject(ctx, pid)
+		if err != nil {
+			log.Fatal(err)
+		}
+		pipelineURL := fmt.Sprintf("%s/pipelines/%d", project.WebURL, pipeline.ID)
+		fmt.Println(pipelineURL)
+
+		if web, _ := cmd.Flags().GetBool("web"); web {
+			if err := browse(pipelineURL); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if wait, _ := cmd.Flags().GetBool("wait"); wait {
+			ciWatch(cmd, pid, true, func() (int, error) {
+				return pipeline.ID, nil
+			})
+		}
 	},
 }
 
+// ciCreateVariables returns the pipeline variables given by the --variable-file
+// and -v flags of cmd, sorted by key
+func ciCreateVariables(cmd *cobra.Command) ([]*gitlab.PipelineVariable, error) {
+	ciVars := make(map[string]string)
+	file, _ := cmd.Flags().GetString("variable-file")
+	if file != "" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		vars, err := parseDotenv(f)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s", file)
+		}
+		for _, v := range vars {
+			ciVars[v.Key] = v.Value
+		}
+	}
+	flags, err := cmd.Flags().GetStringSlice("variable")
+	if err != nil {
+		return nil, err
+	}
+	flagVars, err := parseCIVariables(flags)
+	if err != nil {
+		return nil, err
+	}
+	// The values of -v override those of the file
+	for k, v := range flagVars {
+		ciVars[k] = v
+	}
+
+	keys := make([]string, 0, len(ciVars))
+	for k := range ciVars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	list := make([]*gitlab.PipelineVariable, 0, len(keys))
+	for _, k := range keys {
+		list = append(list, &gitlab.PipelineVariable{Key: k, Value: ciVars[k]})
+	}
+	return list, nil
+}
+
 var ciTriggerCmd = &cobra.Command{
 	Use:   "trigger [branch]",
 	Short: "Trigger a CI pipeline",
@@ -70,7 +142,7 @@ lab ci trigger -p engineering/integration_tests -v foo=bar master`,
 		if err != nil {
 			log.Fatal(err)
 		}
-		pipeline, err := lab.CITrigger(pid, gitlab.RunPipelineTriggerOptions{
+		pipeline, err := labClient.CITrigger(ctx, pid, gitlab.RunPipelineTriggerOptions{
 			Ref:       &branch,
 			Token:     &token,
 			Variables: ciVars,
@@ -78,7 +150,7 @@ lab ci trigger -p engineering/integration_tests -v foo=bar master`,
 		if err != nil {
 			log.Fatal(err)
 		}
-		project, err := lab.GetProject(pid)
+		project, err := labClient.GetProject(ctx, pid)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -97,7 +169,7 @@ func getCIRunOptions(cmd *cobra.Command, args []string) (interface{}, string, er
 	}
 
 	remote := determineSourceRemote(branch)
-	rn, err := git.PathWithNameSpace(remote)
+	rn, err := projectForRemote(remote)
 	if err != nil {
 		return nil, "", err
 	}
@@ -108,7 +180,7 @@ func getCIRunOptions(cmd *cobra.Command, args []string) (interface{}, string, er
 		return nil, "", err
 	}
 	if project != "" {
-		p, err := lab.FindProject(project)
+		p, err := labClient.FindProject(ctx, project)
 		if err != nil {
 			return nil, "", err
 		}
@@ -133,6 +205,11 @@ func parseCIVariables(vars []string) (map[string]string, error) {
 func init() {
 	ciCreateCmd.MarkZshCompPositionalArgumentCustom(1, "__lab_completion_remote_branches origin")
 	ciCreateCmd.Flags().StringP("project", "p", "", "Project to create pipeline on")
+	ciCreateCmd.Flags().StringSliceP("variable", "v", []string{}, "Variables to pass to pipeline")
+	ciCreateCmd.Flags().String("variable-file", "", "dotenv file of variables to pass to pipeline")
+	ciCreateCmd.Flags().Bool("wait", false, "Follow the pipeline until it finishes. Exit code indicates pipeline status")
+	ciCreateCmd.Flags().Bool("web", false, "Open the pipeline in a browser")
+	addCIWaitFlags(ciCreateCmd)
 	ciCmd.AddCommand(ciCreateCmd)
 
 	ciTriggerCmd.MarkZshCompPositionalArgumentCustom(1, "__lab_completion_remote_branches")
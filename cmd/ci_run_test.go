@@ -1,11 +1,17 @@
 package cmd
 
 import (
+	"encoding/json"
+	"io/ioutil"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	gitlab "github.com/xanzy/go-gitlab"
 )
 
 func Test_ciRun(t *testing.T) {
@@ -146,3 +152,66 @@ func Test_getCIRunOptions(t *testing.T) {
 		})
 	}
 }
+
+func Test_ciCreateVariables(t *testing.T) {
+	skipIfLive(t)
+	t.Parallel()
+	repo := copyTestRepo(t)
+
+	// The file is exported from the variables of the upstream project,
+	// which no other test stores variables in
+	runLab(t, repo, "ci", "variable", "set", "-p", "zaquestion/test", "DEPLOY", "false")
+	runLab(t, repo, "ci", "variable", "set", "-p", "zaquestion/test", "GREETING", "hello \"world\"\n# not a comment")
+	exported := runLab(t, repo, "ci", "variable", "export", "-p", "zaquestion/test")
+	file := filepath.Join(repo, "ci.env")
+	env := "# deploy settings\nexport ENVIRONMENT=staging\n\n" + strings.Join(getAppOutput([]byte(exported)), "\n") + "\n"
+	require.NoError(t, ioutil.WriteFile(file, []byte(env), 0644))
+
+	out := runLab(t, repo, "ci", "create", "--variable-file", file, "-v", "DEPLOY=true", "-v", "DEBUG=1")
+	m := regexp.MustCompile(`pipelines/(\d+)\n`).FindStringSubmatch(out)
+	require.NotNil(t, m, out)
+
+	out = runLab(t, repo, "api", "get", "projects/lab-testing%2Ftest/pipelines/"+m[1]+"/variables")
+	var vars []*gitlab.PipelineVariable
+	require.NoError(t, json.Unmarshal([]byte(out[:strings.LastIndex(out, "]")+1]), &vars), out)
+	assert.Equal(t, []*gitlab.PipelineVariable{
+		{Key: "DEBUG", Value: "1"},
+		{Key: "DEPLOY", Value: "true"},
+		{Key: "ENVIRONMENT", Value: "staging"},
+		{Key: "GREETING", Value: "hello \"world\"\n# not a comment"},
+	}, vars)
+}
+
+func Test_ciCreateWait(t *testing.T) {
+	skipIfLive(t)
+	t.Parallel()
+	repo := copyTestRepo(t)
+	// The pipelines created by the fake never start
+	cmd := exec.Command(labBinaryPath, "ci", "create", "--wait", "--wait-timeout", "300ms", "--interval", "100ms")
+	cmd.Dir = repo
+
+	b, err := cmd.CombinedOutput()
+	require.Error(t, err)
+	exitErr, ok := err.(*exec.ExitError)
+	require.True(t, ok, err)
+	assert.Equal(t, ciExitTimeout, exitErr.ExitCode())
+	assert.Regexp(t, `^https://gitlab.com/lab-testing/test/pipelines/\d+\n`, string(b))
+	assert.Contains(t, string(b), "timed out after 300ms waiting for the pipeline")
+}
+
+func Test_ciCreateWeb(t *testing.T) {
+	skipIfLive(t)
+	oldBrowse := browse
+	defer func() { browse = oldBrowse }()
+	var opened string
+	browse = func(url string) error {
+		opened = url
+		return nil
+	}
+
+	require.NoError(t, ciCreateCmd.Flags().Set("web", "true"))
+	defer ciCreateCmd.Flags().Set("web", "false")
+	// mrtest is pushed to the fork, whose pipelines no other test looks at
+	ciCreateCmd.Run(ciCreateCmd, []string{"mrtest"})
+	require.Regexp(t, `^https://gitlab.com/lab-testing/test/pipelines/\d+$`, opened)
+}
@@ -1,15 +1,21 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	gitlab "github.com/xanzy/go-gitlab"
 	"github.com/zaquestion/lab/internal/git"
-	lab "github.com/zaquestion/lab/internal/gitlab"
+	"golang.org/x/crypto/ssh/terminal"
 )
 
 // ciStatusCmd represents the run command
@@ -17,9 +23,25 @@ var ciStatusCmd = &cobra.Command{
 	Use:     "status [branch]",
 	Aliases: []string{"run"},
 	Short:   "Textual representation of a CI pipeline",
-	Long:    ``,
+	Long: `Show the status of the jobs of the latest pipeline of a branch, or of the
+pipeline picked with --pipeline, --sha or --mr.
+
+With --wait, lab keeps checking the pipeline until it finishes, redrawing the
+jobs in place on a terminal, and exits with a code telling how it finished:
+
+  0  the pipeline succeeded
+  2  the pipeline failed
+  3  the pipeline was canceled
+  4  --wait-timeout, or the global --timeout, was reached before the
+     pipeline finished
+  5  the pipeline stopped otherwise, such as waiting on a manual job
+
+Other errors, such as failing to find the pipeline, exit with 1.`,
 	Example: `lab ci status
-lab ci status --wait`,
+lab ci status --wait
+lab ci status --wait --wait-timeout 30m --interval 10s
+lab ci status --sha HEAD~1
+lab ci status --mr 12`,
 	RunE: nil,
 	Run: func(cmd *cobra.Command, args []string) {
 		branch, err := git.CurrentBranch()
@@ -38,53 +60,214 @@ lab ci status --wait`,
 			}
 			remote = args[0]
 		}
-		rn, err := git.PathWithNameSpace(remote)
+		rn, err := projectForRemote(remote)
 		if err != nil {
 			log.Fatal(err)
 		}
-		pid := rn
 
-		w := tabwriter.NewWriter(os.Stdout, 2, 4, 1, byte(' '), 0)
-		jobs, err := lab.CIJobs(pid, branch)
+		wait, err := cmd.Flags().GetBool("wait")
 		if err != nil {
-			log.Fatal(errors.Wrap(err, "failed to find ci jobs"))
+			log.Fatal(err)
 		}
-		jobs = latestJobs(jobs)
+		ciWatch(cmd, rn, wait, func() (int, error) {
+			return ciPipelineID(cmd, rn, branch)
+		})
+	},
+}
+
+// ciWatch prints the status of the jobs of the pipeline of the project pid
+// whose ID is returned by pipelineID, which is called each time the pipeline
+// is checked. With wait, it keeps checking the pipeline every --interval of
+// cmd until it finishes or --wait-timeout or --timeout is reached, then exits
+// with a code telling how it finished.
+func ciWatch(cmd *cobra.Command, pid interface{}, wait bool, pipelineID func() (int, error)) {
+	interval, _ := cmd.Flags().GetDuration("interval")
+	waitTimeout, _ := cmd.Flags().GetDuration("wait-timeout")
+	if interval <= 0 {
+		log.Fatal("--interval must be positive")
+	}
+	var timedOut <-chan time.Time
+	if waitTimeout > 0 {
+		timedOut = time.After(waitTimeout)
+	}
 
-		if len(jobs) == 0 {
+	// On a terminal the table is redrawn in place, otherwise a new one is
+	// printed each time a job changes status
+	tty := terminal.IsTerminal(int(os.Stdout.Fd()))
+	var (
+		jobs       []*gitlab.Job
+		drawn      int
+		lastStatus string
+	)
+	draw := func(final bool) {
+		if structuredOutput() {
 			return
 		}
-
-		wait, err := cmd.Flags().GetBool("wait")
-		if err != nil {
-			log.Fatal(err)
+		var b bytes.Buffer
+		printCIStatus(&b, jobs, time.Now())
+		if final {
+			fmt.Fprintf(&b, "\nPipeline Status: %s\n", jobs[0].Pipeline.Status)
 		}
-
-		fmt.Fprintln(w, "Stage:\tName\t-\tStatus")
-		for {
-			for _, job := range jobs {
-				fmt.Fprintf(w, "%s:\t%s\t-\t%s\n", job.Stage, job.Name, job.Status)
+		if tty {
+			if drawn > 0 {
+				// move up to the previous table and clear it
+				fmt.Printf("\x1b[%dA\x1b[J", drawn)
 			}
-			if !wait {
-				break
+			drawn = strings.Count(b.String(), "\n")
+		} else {
+			status := ciJobStatuses(jobs)
+			if !final && status == lastStatus {
+				return
+			}
+			if lastStatus != "" {
+				fmt.Println()
 			}
-			if jobs[0].Pipeline.Status != "pending" &&
-				jobs[0].Pipeline.Status != "running" {
+			lastStatus = status
+		}
+		b.WriteTo(os.Stdout)
+	}
+	exitTimedOut := func(after time.Duration) {
+		if len(jobs) > 0 {
+			draw(true)
+		}
+		fmt.Fprintf(os.Stderr, "timed out after %s waiting for the pipeline\n", after)
+		os.Exit(ciExitTimeout)
+	}
+	// The requests fail once the --timeout of ctx is reached
+	checkErr := func(err error) {
+		if err == nil {
+			return
+		}
+		if wait && ctx.Err() == context.DeadlineExceeded {
+			exitTimedOut(timeout)
+		}
+		log.Fatal(err)
+	}
+
+	for {
+		id, err := pipelineID()
+		checkErr(err)
+		jobs, err = labClient.CIJobs(ctx, pid, id)
+		checkErr(errors.Wrap(err, "failed to find ci jobs"))
+		jobs = latestJobs(jobs)
+		if !wait {
+			break
+		}
+		// A pipeline which was just pushed may not have jobs yet
+		if len(jobs) > 0 {
+			if !ciPipelineRunning(jobs[0].Pipeline.Status) {
 				break
 			}
-			fmt.Fprintln(w)
+			draw(false)
 		}
 
-		fmt.Fprintf(w, "\nPipeline Status: %s\n", jobs[0].Pipeline.Status)
-		if wait && jobs[0].Pipeline.Status != "success" {
-			os.Exit(1)
+		select {
+		case <-time.After(interval):
+		case <-timedOut:
+			exitTimedOut(waitTimeout)
+		case <-ctx.Done():
+			checkErr(errors.Wrap(ctx.Err(), "stopped waiting for the pipeline"))
 		}
-		w.Flush()
-	},
+	}
+
+	if len(jobs) == 0 {
+		return
+	}
+	if structuredOutput() {
+		if err := printStructured(os.Stdout, jobs); err != nil {
+			log.Fatal(err)
+		}
+	}
+	draw(true)
+	if wait {
+		os.Exit(ciExitCode(jobs[0].Pipeline.Status))
+	}
+}
+
+// The exit codes of lab ci status --wait. 1 is left to log.Fatal so failed
+// pipelines can be told apart from errors of lab.
+const (
+	ciExitSuccess  = 0
+	ciExitFailed   = 2
+	ciExitCanceled = 3
+	ciExitTimeout  = 4
+	// ciExitOther is for pipelines which stopped otherwise, such as
+	// skipped pipelines or those waiting on a manual job
+	ciExitOther = 5
+)
+
+// ciExitCode returns the exit code of lab ci status --wait for a pipeline
+// which finished with status
+func ciExitCode(status string) int {
+	switch status {
+	case "success":
+		return ciExitSuccess
+	case "failed":
+		return ciExitFailed
+	case "canceled":
+		return ciExitCanceled
+	}
+	return ciExitOther
+}
+
+// ciPipelineRunning reports whether a pipeline with status has yet to finish
+func ciPipelineRunning(status string) bool {
+	switch status {
+	case "created", "waiting_for_resource", "preparing", "pending", "running", "scheduled":
+		return true
+	}
+	return false
+}
+
+// ciJobStatuses returns the statuses of jobs in a single string, which
+// changes whenever one of them does
+func ciJobStatuses(jobs []*gitlab.Job) string {
+	var b strings.Builder
+	for _, job := range jobs {
+		fmt.Fprintf(&b, "%d:%s,", job.ID, job.Status)
+	}
+	return b.String()
+}
+
+// printCIStatus prints a table of the jobs of a pipeline, with how long the
+// jobs which started took or have been running by now
+func printCIStatus(out io.Writer, jobs []*gitlab.Job, now time.Time) {
+	w := tabwriter.NewWriter(out, 2, 4, 1, byte(' '), 0)
+	fmt.Fprintln(w, "Stage:\tName\t-\tStatus")
+	for _, job := range jobs {
+		status := job.Status
+		if d := ciJobDuration(job, now); d > 0 {
+			status += " (" + d.String() + ")"
+		}
+		fmt.Fprintf(w, "%s:\t%s\t-\t%s\n", job.Stage, job.Name, status)
+	}
+	w.Flush()
+}
+
+// ciJobDuration returns how long a job ran, or has been running by now, to the
+// second. It is 0 for jobs which didn't start.
+func ciJobDuration(job *gitlab.Job, now time.Time) time.Duration {
+	if job.StartedAt == nil {
+		return 0
+	}
+	end := now
+	if job.FinishedAt != nil {
+		end = *job.FinishedAt
+	}
+	return end.Sub(*job.StartedAt).Round(time.Second)
+}
+
+// addCIWaitFlags adds the flags tuning how ciWatch waits for a pipeline
+func addCIWaitFlags(cmd *cobra.Command) {
+	cmd.Flags().Duration("interval", 5*time.Second, "How often to check the pipeline with --wait")
+	cmd.Flags().Duration("wait-timeout", 0, "Give up waiting after the given duration, such as 30m, with --wait")
 }
 
 func init() {
 	ciStatusCmd.MarkZshCompPositionalArgumentCustom(1, "__lab_completion_remote_branches")
 	ciStatusCmd.Flags().Bool("wait", false, "Continuously print the status and wait to exit until the pipeline finishes. Exit code indicates pipeline status")
+	addCIWaitFlags(ciStatusCmd)
+	addCIPipelineFlags(ciStatusCmd)
+	addFormatFlag(ciStatusCmd)
 	ciCmd.AddCommand(ciStatusCmd)
 }
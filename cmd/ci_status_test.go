@@ -1,23 +1,22 @@
 package cmd
 
 import (
+	"bytes"
 	"os/exec"
+	"regexp"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gitlab "github.com/xanzy/go-gitlab"
 )
 
 func Test_ciStatus(t *testing.T) {
 	t.Parallel()
 	repo := copyTestRepo(t)
-	cmd := exec.Command(labBinaryPath, "fetch", "origin")
-	cmd.Dir = repo
-	if b, err := cmd.CombinedOutput(); err != nil {
-		t.Log(string(b))
-		t.Fatal(err)
-	}
-
-	cmd = exec.Command(labBinaryPath, "checkout", "origin/ci_test_pipeline")
+	// origin/ci_test_pipeline is already fetched in the testdata repo
+	cmd := exec.Command(labBinaryPath, "checkout", "origin/ci_test_pipeline")
 	cmd.Dir = repo
 	if b, err := cmd.CombinedOutput(); err != nil {
 		t.Log(string(b))
@@ -63,3 +62,123 @@ deploy: deploy10                       - success`)
 
 	assert.Contains(t, out, "Pipeline Status: success")
 }
+
+func Test_ciStatusWait(t *testing.T) {
+	t.Parallel()
+	repo := copyTestRepo(t)
+	cmd := exec.Command(labBinaryPath, "ci", "status", "origin", "ci_test_pipeline", "--wait")
+	cmd.Dir = repo
+
+	b, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Log(string(b))
+		t.Fatal(err)
+	}
+	out := string(b)
+	assert.Contains(t, out, "deploy: deploy10                       - success\n")
+	assert.Contains(t, out, "Pipeline Status: success")
+}
+
+func Test_ciStatusWaitTimeout(t *testing.T) {
+	skipIfLive(t)
+	t.Parallel()
+	repo := copyTestRepo(t)
+	// The pipelines created by the fake never start
+	cmd := exec.Command(labBinaryPath, "ci", "create", "mrtest")
+	cmd.Dir = repo
+	b, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(b))
+	m := regexp.MustCompile(`pipelines/(\d+)\n`).FindStringSubmatch(string(b))
+	require.NotNil(t, m, string(b))
+
+	// The global --timeout of the requests is a timeout too
+	for _, timeout := range []string{"--wait-timeout", "--timeout"} {
+		out, err := runLabErr(repo, "ci", "status", "lab-testing", "mrtest", "--pipeline", m[1],
+			"--wait", timeout, "300ms", "--interval", "100ms")
+		require.Error(t, err)
+		exitErr, ok := err.(*exec.ExitError)
+		require.True(t, ok, err)
+		assert.Equal(t, ciExitTimeout, exitErr.ExitCode(), timeout)
+		assert.Contains(t, out, "timed out after 300ms waiting for the pipeline")
+	}
+}
+
+func Test_ciStatusNoPipeline(t *testing.T) {
+	skipIfLive(t)
+	t.Parallel()
+	repo := copyTestRepo(t)
+	for _, args := range [][]string{
+		{"origin", "mrtest"},
+		{"origin", "--mr", "2"},
+	} {
+		cmd := exec.Command(labBinaryPath, append([]string{"ci", "status", "--wait"}, args...)...)
+		cmd.Dir = repo
+
+		b, err := cmd.CombinedOutput()
+		require.Error(t, err)
+		exitErr, ok := err.(*exec.ExitError)
+		require.True(t, ok, err)
+		assert.Equal(t, 1, exitErr.ExitCode())
+		assert.Regexp(t, `no pipeline found for (branch mrtest|merge request !2)`, string(b))
+	}
+}
+
+func Test_ciExitCode(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, 0, ciExitCode("success"))
+	assert.Equal(t, 2, ciExitCode("failed"))
+	assert.Equal(t, 3, ciExitCode("canceled"))
+	assert.Equal(t, 5, ciExitCode("manual"))
+	assert.Equal(t, 5, ciExitCode("skipped"))
+}
+
+func Test_printCIStatus(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(-d)
+		return &t
+	}
+	jobs := []*gitlab.Job{
+		{Stage: "build", Name: "build", Status: "success", StartedAt: at(5 * time.Minute), FinishedAt: at(3*time.Minute + 30*time.Second)},
+		{Stage: "test", Name: "unit", Status: "running", StartedAt: at(42 * time.Second)},
+		{Stage: "test", Name: "integration", Status: "pending"},
+	}
+	var b bytes.Buffer
+	printCIStatus(&b, jobs, now)
+	assert.Equal(t, `Stage: Name        - Status
+build: build       - success (1m30s)
+test:  unit        - running (42s)
+test:  integration - pending
+`, b.String())
+}
+
+func Test_ciStatusPipelineFlags(t *testing.T) {
+	t.Parallel()
+	repo := copyTestRepo(t)
+
+	// ci_test_pipeline points at the checked out master
+	out := runLab(t, repo, "ci", "status", "origin", "--sha", "HEAD")
+	assert.Contains(t, out, "deploy: deploy10                       - success\n")
+
+	out, err := runLabErr(repo, "ci", "status", "origin", "--sha", "not-a-rev")
+	require.Error(t, err)
+	assert.Contains(t, out, "not-a-rev is not a commit")
+
+	out, err = runLabErr(repo, "ci", "status", "origin", "--sha", "HEAD", "--mr", "1")
+	require.Error(t, err)
+	assert.Contains(t, out, "--pipeline, --sha and --mr can't be used together")
+}
+
+func Test_ciStatusMR(t *testing.T) {
+	skipIfLive(t)
+	t.Parallel()
+	repo := copyTestRepo(t)
+	cmd := exec.Command(labBinaryPath, "ci", "status", "origin", "--mr", "1")
+	cmd.Dir = repo
+
+	b, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(b))
+	assert.Contains(t, string(b), "test:  lint - failed (1m35s)\n")
+	assert.Contains(t, string(b), "Pipeline Status: failed")
+}
@@ -7,14 +7,10 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
-	"strings"
-	"sync"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
-	"github.com/zaquestion/lab/internal/git"
-	lab "github.com/zaquestion/lab/internal/gitlab"
 )
 
 // ciLintCmd represents the lint command
@@ -22,58 +18,64 @@ var ciTraceCmd = &cobra.Command{
 	Use:     "trace [remote [[branch:]job]]",
 	Aliases: []string{"logs"},
 	Short:   "Trace the output of a ci job",
-	Long:    `If a job is not specified the latest running job or last job in the pipeline is used`,
-	Run: func(cmd *cobra.Command, args []string) {
-		var (
-			remote  string
-			jobName string
-		)
+	Long: `If a job is not specified the latest running job or last job in the pipeline is used.
 
-		branch, err := git.CurrentBranch()
+The pipeline is the latest one of the branch unless picked with --pipeline,
+--sha or --mr.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		remote, branch, jobName, err := ciJobArgs(args)
 		if err != nil {
 			log.Fatal(err)
 		}
-		if len(args) > 1 {
-			jobName = args[1]
-			if strings.Contains(args[1], ":") {
-				ps := strings.Split(args[1], ":")
-				branch, jobName = ps[0], ps[1]
-			}
-		}
-		remote = determineSourceRemote(branch)
-		if len(args) > 0 {
-			ok, err := git.IsRemote(args[0])
-			if err != nil || !ok {
-				log.Fatal(args[0], " is not a remote:", err)
-			}
-			remote = args[0]
-		}
 
-		rn, err := git.PathWithNameSpace(remote)
+		rn, err := projectForRemote(remote)
 		if err != nil {
 			log.Fatal(err)
 		}
-		project, err := lab.FindProject(rn)
+		project, err := labClient.FindProject(ctx, rn)
 		if err != nil {
 			log.Fatal(err)
 		}
-		err = doTrace(context.Background(), os.Stdout, project.ID, branch, jobName)
+		// Look the pipeline up on each check, so a push while tracing
+		// moves on to the new pipeline of the branch
+		pipelineID := func() (int, error) {
+			return ciPipelineID(cmd, project.ID, branch)
+		}
+		if _, err := pipelineID(); err != nil {
+			log.Fatal(err)
+		}
+		err = doTrace(ctx, os.Stdout, project.ID, pipelineID, jobName)
 		if err != nil {
 			log.Fatal(err)
 		}
 	},
 }
 
-func doTrace(ctx context.Context, w io.Writer, pid interface{}, branch, name string) error {
+// doTrace follows the log of a job until it finishes. The job is looked up in
+// the pipeline whose ID is returned by pipelineID each time the log is
+// checked. Canceling ctx stops following the job without error, while
+// reaching its deadline is an error.
+func doTrace(ctx context.Context, w io.Writer, pid interface{}, pipelineID func() (int, error), name string) error {
 	var (
-		once   sync.Once
+		jobID  int
 		offset int64
 	)
-	for range time.NewTicker(time.Second * 3).C {
-		if ctx.Err() == context.Canceled {
-			break
+	ticker := time.NewTicker(time.Second * 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return traceDone(ctx)
+		}
+		id, err := pipelineID()
+		if err != nil {
+			return err
+		}
+		trace, job, err := labClient.CITrace(ctx, pid, id, name)
+		if ctx.Err() != nil {
+			return traceDone(ctx)
 		}
-		trace, job, err := lab.CITrace(pid, branch, name)
 		if err != nil || job == nil || trace == nil {
 			return errors.Wrap(err, "failed to find job")
 		}
@@ -85,12 +87,14 @@ func doTrace(ctx context.Context, w io.Writer, pid interface{}, branch, name str
 			fmt.Fprintf(w, "Manual job %s not started, waiting for job to start\n", job.Name)
 			continue
 		}
-		once.Do(func() {
+		if job.ID != jobID {
+			// The first job, or the job of a newer pipeline
 			if name == "" {
 				name = job.Name
 			}
 			fmt.Fprintf(w, "Showing logs for %s job #%d\n", job.Name, job.ID)
-		})
+			jobID, offset = job.ID, 0
+		}
 		_, err = io.CopyN(ioutil.Discard, trace, offset)
 		lenT, err := io.Copy(w, trace)
 		if err != nil {
@@ -104,10 +108,18 @@ func doTrace(ctx context.Context, w io.Writer, pid interface{}, branch, name str
 			return nil
 		}
 	}
+}
+
+// traceDone returns the error a trace stops with once ctx is done
+func traceDone(ctx context.Context) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return errors.New("timed out waiting for the job to finish")
+	}
 	return nil
 }
 
 func init() {
+	addCIPipelineFlags(ciTraceCmd)
 	ciTraceCmd.MarkZshCompPositionalArgumentCustom(1, "__lab_completion_remote")
 	ciTraceCmd.MarkZshCompPositionalArgumentCustom(2, "__lab_completion_remote_branches $words[2]")
 	ciCmd.AddCommand(ciTraceCmd)
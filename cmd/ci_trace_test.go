@@ -10,14 +10,8 @@ import (
 func Test_ciTrace(t *testing.T) {
 	t.Parallel()
 	repo := copyTestRepo(t)
-	cmd := exec.Command(labBinaryPath, "fetch", "origin")
-	cmd.Dir = repo
-	if b, err := cmd.CombinedOutput(); err != nil {
-		t.Log(string(b))
-		t.Fatal(err)
-	}
-
-	cmd = exec.Command(labBinaryPath, "checkout", "origin/ci_test_pipeline")
+	// origin/ci_test_pipeline is already fetched in the testdata repo
+	cmd := exec.Command(labBinaryPath, "checkout", "origin/ci_test_pipeline")
 	cmd.Dir = repo
 	if b, err := cmd.CombinedOutput(); err != nil {
 		t.Log(string(b))
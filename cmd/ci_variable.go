@@ -0,0 +1,459 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	gitlab "github.com/xanzy/go-gitlab"
+	"github.com/zaquestion/lab/internal/git"
+	lab "github.com/zaquestion/lab/internal/gitlab"
+)
+
+var ciVariableCmd = &cobra.Command{
+	Use:     "variable",
+	Aliases: []string{"var"},
+	Short:   "Manage the CI/CD variables of a project or group",
+	Long: `Manage the CI/CD variables stored in a project, or in a group with --group.
+The project is the one of the remote given as the first argument, of
+--project, or else of the upstream remote.
+
+Variables are told apart by their key and environment scope, which is *
+unless set with --environment.`,
+}
+
+var ciVariableListCmd = &cobra.Command{
+	Use:     "list [remote]",
+	Aliases: []string{"ls"},
+	Short:   "List the CI/CD variables",
+	Long: `List the keys of the CI/CD variables along with their environment scope and
+attributes. The values are left out; use --format '{{.Key}}={{.Value}}' or
+--output to see them.`,
+	Example: `lab ci variable list
+lab ci variable list --group my-group
+lab ci variable list upstream --format '{{.Key}}={{.Value}}'`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		owner, _, err := ciVariableOwner(cmd, args)
+		if err != nil {
+			log.Fatal(err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, byte(' '), 0)
+		err = printList(os.Stdout, labClient.CIVariableListIter(ctx, owner, -1), func(v interface{}) {
+			variable := v.(*lab.CIVariable)
+			fmt.Fprintf(w, "%s\t%s\t%s\n", variable.Key, variable.EnvironmentScope, ciVariableAttributes(variable))
+		})
+		w.Flush()
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var ciVariableGetCmd = &cobra.Command{
+	Use:   "get [remote] <key>",
+	Short: "Print the value of a CI/CD variable",
+	Long: `Print the value of a CI/CD variable. When several environments have a
+variable with the key, the first one is used unless one is picked with
+--environment.`,
+	Example: `lab ci variable get DEPLOY_TOKEN
+lab ci variable get DEPLOY_TOKEN -e production`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		owner, args, err := ciVariableOwner(cmd, args)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(args) != 1 {
+			log.Fatal("the key of the variable is required")
+		}
+		env, _ := cmd.Flags().GetString("environment")
+		v, err := labClient.CIVariable(ctx, owner, args[0], env)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if v == nil {
+			log.Fatalf("variable %s not found", ciVariableName(args[0], env))
+		}
+		fmt.Print(v.Value)
+		if !strings.HasSuffix(v.Value, "\n") {
+			fmt.Println()
+		}
+	},
+}
+
+var ciVariableSetCmd = &cobra.Command{
+	Use:   "set [remote] <key> [value]",
+	Short: "Create or update a CI/CD variable",
+	Long: `Create a CI/CD variable, or update the one with the same key and environment
+scope. The value is read from stdin when not given.
+
+The attributes are replaced: a variable set without --protected is no longer
+protected. Masked values must be a single line of at least 8 characters.`,
+	Example: `lab ci variable set DEPLOY_TOKEN s3cr3t-t0k3n --protected --masked
+lab ci variable set KUBECONFIG --file -e production < kubeconfig.yml
+lab ci variable set --group my-group REGISTRY registry.example.com`,
+	Args: cobra.RangeArgs(1, 3),
+	Run: func(cmd *cobra.Command, args []string) {
+		owner, args, err := ciVariableOwner(cmd, args)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(args) == 0 || len(args) > 2 {
+			log.Fatal("expected the key of the variable and its value")
+		}
+		v := ciVariableFromFlags(cmd)
+		v.Key = args[0]
+		if len(args) > 1 {
+			v.Value = args[1]
+		} else {
+			b, err := ioutil.ReadAll(os.Stdin)
+			if err != nil {
+				log.Fatal(err)
+			}
+			v.Value = string(b)
+			// Only file variables keep the newline ending most input
+			if v.VariableType != "file" {
+				v.Value = strings.TrimSuffix(v.Value, "\n")
+			}
+		}
+		if err := ciSetVariable(owner, v); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var ciVariableDeleteCmd = &cobra.Command{
+	Use:     "delete [remote] <key>",
+	Aliases: []string{"rm"},
+	Short:   "Delete a CI/CD variable",
+	Long: `Delete a CI/CD variable. When several environments have a variable with the
+key, the first one is deleted unless one is picked with --environment.`,
+	Example: `lab ci variable delete DEPLOY_TOKEN -e production`,
+	Args:    cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		owner, args, err := ciVariableOwner(cmd, args)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(args) != 1 {
+			log.Fatal("the key of the variable is required")
+		}
+		env, _ := cmd.Flags().GetString("environment")
+		err = labClient.CIDeleteVariable(ctx, owner, args[0], env)
+		if e, ok := err.(*gitlab.ErrorResponse); ok && e.Response.StatusCode == 404 {
+			log.Fatalf("variable %s not found", ciVariableName(args[0], env))
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Deleted variable %s\n", ciVariableName(args[0], env))
+	},
+}
+
+var ciVariableExportCmd = &cobra.Command{
+	Use:   "export [remote]",
+	Short: "Export the CI/CD variables as dotenv or JSON",
+	Long: `Print the CI/CD variables as KEY=value lines, or with --as json as a JSON list
+which lab ci variable import reads back with all the attributes of the
+variables. dotenv only has room for the keys and values, so a key can only be
+exported once; pick the environment with --environment when several have it.`,
+	Example: `lab ci variable export > .env
+lab ci variable export --as json -p group/old | lab ci variable import -p group/new -`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		owner, _, err := ciVariableOwner(cmd, args)
+		if err != nil {
+			log.Fatal(err)
+		}
+		as, _ := cmd.Flags().GetString("as")
+		env, _ := cmd.Flags().GetString("environment")
+		vars, err := labClient.CIVariables(ctx, owner)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if env != "" {
+			scoped := []*lab.CIVariable{}
+			for _, v := range vars {
+				if v.EnvironmentScope == env {
+					scoped = append(scoped, v)
+				}
+			}
+			vars = scoped
+		}
+		switch as {
+		case "json":
+			b, err := json.MarshalIndent(vars, "", "  ")
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println(string(b))
+		case "dotenv":
+			if err := writeDotenv(os.Stdout, vars); err != nil {
+				log.Fatal(err)
+			}
+		default:
+			log.Fatalf("unknown format %q, must be dotenv or json", as)
+		}
+	},
+}
+
+var ciVariableImportCmd = &cobra.Command{
+	Use:   "import [remote] <file>",
+	Short: "Create or update CI/CD variables from a dotenv or JSON file",
+	Long: `Create or update the CI/CD variables of a dotenv file of KEY=value lines, or of
+a JSON list as written by lab ci variable export --as json. The file is read
+from stdin when it is -.
+
+The format is guessed from the .json extension or a leading [ unless given with
+--as. The attributes of dotenv variables are taken from the flags, while JSON
+variables keep their own.`,
+	Example: `lab ci variable import .env --protected -e staging
+lab ci variable import variables.json --group my-group`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		owner, args, err := ciVariableOwner(cmd, args)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(args) != 1 {
+			log.Fatal("the file to import is required")
+		}
+		as, _ := cmd.Flags().GetString("as")
+
+		var b []byte
+		if args[0] == "-" {
+			b, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			b, err = ioutil.ReadFile(args[0])
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if as == "" {
+			as = "dotenv"
+			if filepath.Ext(args[0]) == ".json" || strings.HasPrefix(strings.TrimSpace(string(b)), "[") {
+				as = "json"
+			}
+		}
+
+		var vars []*lab.CIVariable
+		switch as {
+		case "json":
+			if err := json.Unmarshal(b, &vars); err != nil {
+				log.Fatal(errors.Wrapf(err, "failed to read %s", args[0]))
+			}
+			for _, v := range vars {
+				if v.VariableType == "" {
+					v.VariableType = "env_var"
+				}
+				if v.EnvironmentScope == "" {
+					v.EnvironmentScope = "*"
+				}
+			}
+		case "dotenv":
+			vars, err = parseDotenv(strings.NewReader(string(b)))
+			if err != nil {
+				log.Fatal(errors.Wrapf(err, "failed to read %s", args[0]))
+			}
+			attrs := ciVariableFromFlags(cmd)
+			for _, v := range vars {
+				v.VariableType = attrs.VariableType
+				v.Protected = attrs.Protected
+				v.Masked = attrs.Masked
+				v.EnvironmentScope = attrs.EnvironmentScope
+			}
+		default:
+			log.Fatalf("unknown format %q, must be dotenv or json", as)
+		}
+
+		for _, v := range vars {
+			if err := ciSetVariable(owner, v); err != nil {
+				log.Fatal(err)
+			}
+		}
+	},
+}
+
+// ciVariableOwner returns the project or group the variable commands work on,
+// along with args without the remote, if any
+func ciVariableOwner(cmd *cobra.Command, args []string) (lab.CIVariableOwner, []string, error) {
+	group, _ := cmd.Flags().GetString("group")
+	project, _ := cmd.Flags().GetString("project")
+	if group != "" && project != "" {
+		return lab.CIVariableOwner{}, nil, errors.New("--group and --project can't be used together")
+	}
+	if group != "" {
+		return lab.CIVariableOwner{ID: group, Group: true}, args, nil
+	}
+	if project != "" {
+		return lab.CIVariableOwner{ID: project}, args, nil
+	}
+
+	remote := forkedFromRemote
+	if len(args) > 0 {
+		ok, err := git.IsRemote(args[0])
+		if err != nil {
+			return lab.CIVariableOwner{}, nil, err
+		}
+		if ok {
+			remote, args = args[0], args[1:]
+		}
+	}
+	rn, err := projectForRemote(remote)
+	if err != nil {
+		return lab.CIVariableOwner{}, nil, err
+	}
+	return lab.CIVariableOwner{ID: rn}, args, nil
+}
+
+// ciVariableFromFlags returns a variable with the attributes given by the
+// flags of set and import
+func ciVariableFromFlags(cmd *cobra.Command) *lab.CIVariable {
+	v := &lab.CIVariable{VariableType: "env_var"}
+	v.Protected, _ = cmd.Flags().GetBool("protected")
+	v.Masked, _ = cmd.Flags().GetBool("masked")
+	if file, _ := cmd.Flags().GetBool("file"); file {
+		v.VariableType = "file"
+	}
+	v.EnvironmentScope, _ = cmd.Flags().GetString("environment")
+	return v
+}
+
+// ciSetVariable creates or updates v and says which it did
+func ciSetVariable(owner lab.CIVariableOwner, v *lab.CIVariable) error {
+	created, err := labClient.CISetVariable(ctx, owner, v)
+	if err != nil {
+		return errors.Wrapf(err, "failed to set variable %s", v.Key)
+	}
+	action := "Updated"
+	if created {
+		action = "Created"
+	}
+	fmt.Printf("%s variable %s\n", action, ciVariableName(v.Key, v.EnvironmentScope))
+	return nil
+}
+
+// ciVariableName returns key, along with the environment scope env unless it
+// is empty or all environments
+func ciVariableName(key, env string) string {
+	if env == "" || env == "*" {
+		return key
+	}
+	return fmt.Sprintf("%s (%s)", key, env)
+}
+
+// ciVariableAttributes returns the attributes of v shown by lab ci variable
+// list, or - when it has none
+func ciVariableAttributes(v *lab.CIVariable) string {
+	var attrs []string
+	if v.Protected {
+		attrs = append(attrs, "protected")
+	}
+	if v.Masked {
+		attrs = append(attrs, "masked")
+	}
+	if v.VariableType == "file" {
+		attrs = append(attrs, "file")
+	}
+	return orDash(strings.Join(attrs, ","))
+}
+
+// parseDotenv reads the KEY=value lines of a dotenv file. Blank lines and
+// comments are skipped, the keys may be preceded by export and the values may
+// be quoted. Double quoted values are unescaped, so they may hold newlines.
+func parseDotenv(r io.Reader) ([]*lab.CIVariable, error) {
+	var vars []*lab.CIVariable
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		parts := strings.SplitN(line, "=", 2)
+		key := strings.TrimSpace(parts[0])
+		if len(parts) < 2 || key == "" {
+			return nil, errors.Errorf("line %d: expected KEY=value", n)
+		}
+		value := strings.TrimSpace(parts[1])
+		switch {
+		case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, errors.Errorf("line %d: invalid quoted value", n)
+			}
+			value = unquoted
+		case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+			value = value[1 : len(value)-1]
+		}
+		vars = append(vars, &lab.CIVariable{Key: key, Value: value})
+	}
+	return vars, scanner.Err()
+}
+
+// writeDotenv writes vars as the KEY=value lines of a dotenv file, sorted by
+// key. The values which parseDotenv wouldn't read back as is are quoted.
+func writeDotenv(w io.Writer, vars []*lab.CIVariable) error {
+	sorted := make([]*lab.CIVariable, len(vars))
+	copy(sorted, vars)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Key < sorted[j].Key
+	})
+	for i, v := range sorted {
+		if i > 0 && sorted[i-1].Key == v.Key {
+			return errors.Errorf("%s is set for several environments, pick one with --environment", v.Key)
+		}
+	}
+	for _, v := range sorted {
+		value := v.Value
+		if value != strings.TrimSpace(value) || strings.ContainsAny(value, "\"'\\\n\r\t#") {
+			value = strconv.Quote(value)
+		}
+		if _, err := fmt.Fprintf(w, "%s=%s\n", v.Key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func init() {
+	ciVariableCmd.PersistentFlags().StringP("project", "p", "", "Project the variables are stored in, instead of the one of the remote")
+	ciVariableCmd.PersistentFlags().StringP("group", "g", "", "Group the variables are stored in, instead of a project")
+
+	for _, cmd := range []*cobra.Command{ciVariableGetCmd, ciVariableDeleteCmd} {
+		cmd.Flags().StringP("environment", "e", "", "Environment scope of the variable")
+	}
+	for _, cmd := range []*cobra.Command{ciVariableSetCmd, ciVariableImportCmd} {
+		cmd.Flags().Bool("protected", false, "Only expose the variables to protected branches and tags")
+		cmd.Flags().Bool("masked", false, "Mask the values in job logs")
+		cmd.Flags().Bool("file", false, "Write the values to files, exposing their paths instead")
+		cmd.Flags().StringP("environment", "e", "*", "Environment scope of the variables")
+	}
+	ciVariableExportCmd.Flags().StringP("environment", "e", "", "Only export the variables of the given environment scope")
+	ciVariableExportCmd.Flags().String("as", "dotenv", "Format of the export: dotenv or json")
+	ciVariableImportCmd.Flags().String("as", "", "Format of the file: dotenv or json")
+	ciVariableExportCmd.MarkFlagCustom("as", "(dotenv json)")
+	ciVariableImportCmd.MarkFlagCustom("as", "(dotenv json)")
+
+	addFormatFlag(ciVariableListCmd)
+	for _, cmd := range []*cobra.Command{
+		ciVariableListCmd, ciVariableGetCmd, ciVariableSetCmd,
+		ciVariableDeleteCmd, ciVariableExportCmd, ciVariableImportCmd,
+	} {
+		cmd.MarkZshCompPositionalArgumentCustom(1, "__lab_completion_remote")
+		ciVariableCmd.AddCommand(cmd)
+	}
+	ciCmd.AddCommand(ciVariableCmd)
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	lab "github.com/zaquestion/lab/internal/gitlab"
+)
+
+func Test_ciVariable(t *testing.T) {
+	skipIfLive(t)
+	t.Parallel()
+	repo := copyTestRepo(t)
+	run := func(stdin string, args ...string) (string, error) {
+		cmd := exec.Command(labBinaryPath, append([]string{"ci", "variable"}, args...)...)
+		cmd.Dir = repo
+		cmd.Stdin = strings.NewReader(stdin)
+		b, err := cmd.CombinedOutput()
+		return string(b), err
+	}
+	mustRun := func(stdin string, args ...string) []string {
+		t.Helper()
+		out, err := run(stdin, args...)
+		require.NoError(t, err, out)
+		return getAppOutput([]byte(out))
+	}
+
+	// The variables are stored in the fork so they don't leak into the
+	// tests of the upstream project
+	assert.Equal(t, []string{"Created variable DEPLOY_TOKEN"},
+		mustRun("", "set", "lab-testing", "DEPLOY_TOKEN", "s3cr3t-t0k3n", "--protected", "--masked"))
+	assert.Equal(t, []string{"Created variable DEPLOY_TOKEN (production)"},
+		mustRun("prod-t0k3n\n", "set", "lab-testing", "DEPLOY_TOKEN", "-e", "production"))
+	assert.Equal(t, []string{"Created variable KUBECONFIG"},
+		mustRun("apiVersion: v1\n", "set", "lab-testing", "KUBECONFIG", "--file"))
+	assert.Equal(t, []string{"Updated variable KUBECONFIG"},
+		mustRun("apiVersion: v2\n", "set", "lab-testing", "KUBECONFIG", "--file"))
+
+	assert.Equal(t, []string{
+		"DEPLOY_TOKEN  *           protected,masked",
+		"DEPLOY_TOKEN  production  -",
+		"KUBECONFIG    *           file",
+	}, mustRun("", "list", "lab-testing"))
+
+	assert.Equal(t, []string{"s3cr3t-t0k3n"}, mustRun("", "get", "lab-testing", "DEPLOY_TOKEN"))
+	assert.Equal(t, []string{"prod-t0k3n"}, mustRun("", "get", "lab-testing", "DEPLOY_TOKEN", "-e", "production"))
+	assert.Equal(t, []string{"apiVersion: v2"}, mustRun("", "get", "lab-testing", "KUBECONFIG"))
+
+	out, err := run("", "set", "lab-testing", "SHORT", "abc", "--masked")
+	require.Error(t, err)
+	assert.Contains(t, out, "failed to set variable SHORT")
+	assert.Contains(t, out, "value is invalid")
+
+	out, err = run("", "export", "lab-testing")
+	require.Error(t, err)
+	assert.Contains(t, out, "DEPLOY_TOKEN is set for several environments, pick one with --environment")
+	assert.Equal(t, []string{
+		`DEPLOY_TOKEN=s3cr3t-t0k3n`,
+		`KUBECONFIG="apiVersion: v2\n"`,
+	}, mustRun("", "export", "lab-testing", "-e", "*"))
+
+	// Copy the variables to the upstream project's group
+	exported := strings.Join(mustRun("", "export", "lab-testing", "--as", "json"), "\n")
+	assert.Equal(t, []string{
+		"Created variable DEPLOY_TOKEN",
+		"Created variable DEPLOY_TOKEN (production)",
+		"Created variable KUBECONFIG",
+	}, mustRun(exported, "import", "--group", "lab-testers", "-"))
+	assert.Equal(t, []string{
+		"DEPLOY_TOKEN  *           protected,masked",
+		"DEPLOY_TOKEN  production  -",
+		"KUBECONFIG    *           file",
+	}, mustRun("", "list", "-g", "lab-testers"))
+
+	dotenv := filepath.Join(repo, "staging.env")
+	require.NoError(t, ioutil.WriteFile(dotenv, []byte("# staging\nexport DEPLOY_TOKEN='staging-t0k3n'\n"), 0644))
+	assert.Equal(t, []string{"Created variable DEPLOY_TOKEN (staging)"},
+		mustRun("", "import", "lab-testing", dotenv, "--protected", "-e", "staging"))
+	assert.Equal(t, []string{"staging-t0k3n"}, mustRun("", "get", "-p", "lab-testing/test", "DEPLOY_TOKEN", "-e", "staging"))
+	assert.Equal(t, []string{
+		"DEPLOY_TOKEN * s3cr3t-t0k3n",
+		"DEPLOY_TOKEN production prod-t0k3n",
+		"KUBECONFIG * apiVersion: v2",
+		"",
+		"DEPLOY_TOKEN staging staging-t0k3n",
+	}, mustRun("", "list", "lab-testing", "--format", "{{.Key}} {{.EnvironmentScope}} {{.Value}}"))
+
+	for _, env := range []string{"staging", "production"} {
+		assert.Equal(t, []string{"Deleted variable DEPLOY_TOKEN (" + env + ")"},
+			mustRun("", "delete", "lab-testing", "DEPLOY_TOKEN", "-e", env))
+	}
+	assert.Equal(t, []string{"Deleted variable DEPLOY_TOKEN"}, mustRun("", "rm", "lab-testing", "DEPLOY_TOKEN"))
+	out, err = run("", "delete", "lab-testing", "DEPLOY_TOKEN")
+	require.Error(t, err)
+	assert.Contains(t, out, "variable DEPLOY_TOKEN not found")
+	assert.Equal(t, []string{"KUBECONFIG  *  file"}, mustRun("", "list", "lab-testing"))
+}
+
+func Test_parseDotenv(t *testing.T) {
+	t.Parallel()
+	vars, err := parseDotenv(strings.NewReader(`# comment
+PLAIN=value
+export EXPORTED = spaced out
+SINGLE='#not a comment'
+DOUBLE="line one\nline two"
+EMPTY=
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []*lab.CIVariable{
+		{Key: "PLAIN", Value: "value"},
+		{Key: "EXPORTED", Value: "spaced out"},
+		{Key: "SINGLE", Value: "#not a comment"},
+		{Key: "DOUBLE", Value: "line one\nline two"},
+		{Key: "EMPTY", Value: ""},
+	}, vars)
+
+	_, err = parseDotenv(strings.NewReader("OK=1\nnot a variable\n"))
+	assert.EqualError(t, err, "line 2: expected KEY=value")
+}
+
+func Test_writeDotenv(t *testing.T) {
+	t.Parallel()
+	vars := []*lab.CIVariable{
+		{Key: "B", Value: "line one\nline two"},
+		{Key: "A", Value: "plain"},
+		{Key: "C", Value: " padded"},
+	}
+	var b bytes.Buffer
+	require.NoError(t, writeDotenv(&b, vars))
+	assert.Equal(t, "A=plain\nB=\"line one\\nline two\"\nC=\" padded\"\n", b.String())
+
+	parsed, err := parseDotenv(&b)
+	require.NoError(t, err)
+	assert.Equal(t, []*lab.CIVariable{vars[1], vars[0], vars[2]}, parsed)
+}
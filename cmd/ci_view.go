@@ -20,19 +20,21 @@ import (
 	"github.com/xanzy/go-gitlab"
 
 	"github.com/zaquestion/lab/internal/git"
-	lab "github.com/zaquestion/lab/internal/gitlab"
 )
 
 var (
 	projectID int
-	branch    string
+	// pipelineID returns the ID of the pipeline shown, which is looked up
+	// again on each refresh
+	pipelineID func() (int, error)
 )
 
 // ciViewCmd represents the ci command
 var ciViewCmd = &cobra.Command{
 	Use:   "view [remote [branch/tag]]",
 	Short: "View, run, trace, and/or cancel CI jobs current pipeline",
-	Long: `Supports viewing, running, tracing, and canceling jobs
+	Long: `Supports viewing, running, tracing, and canceling jobs of the latest pipeline
+of the branch, or of the pipeline picked with --pipeline, --sha or --mr.
 
 'r', 'p' to run/retry/play a job -- Tab navigates modal and Enter to confirm
 't' to toggle trace/logs (runs in background, so you can jump in and out)
@@ -49,7 +51,7 @@ Feedback Encouraged!: https://github.com/zaquestion/lab/issues`,
 			remote string
 			err    error
 		)
-		branch, err = git.CurrentBranch()
+		branch, err := git.CurrentBranch()
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -68,15 +70,21 @@ Feedback Encouraged!: https://github.com/zaquestion/lab/issues`,
 
 		// See if we're in a git repo or if global is set to determine
 		// if this should be a personal snippet
-		rn, err := git.PathWithNameSpace(remote)
+		rn, err := projectForRemote(remote)
 		if err != nil {
 			log.Fatal(err)
 		}
-		project, err := lab.FindProject(rn)
+		project, err := labClient.FindProject(ctx, rn)
 		if err != nil {
 			log.Fatal(err)
 		}
 		projectID = project.ID
+		pipelineID = func() (int, error) {
+			return ciPipelineID(cmd, project.ID, branch)
+		}
+		if _, err := pipelineID(); err != nil {
+			log.Fatal(err)
+		}
 		root := tview.NewPages()
 		root.SetBorderPadding(1, 1, 2, 2)
 
@@ -85,7 +93,7 @@ Feedback Encouraged!: https://github.com/zaquestion/lab/issues`,
 
 		var navi navigator
 		a.SetInputCapture(inputCapture(a, root, navi))
-		go updateJobs(a, jobsCh, project.ID, branch)
+		go updateJobs(a, jobsCh, project.ID, pipelineID)
 		go refreshScreen(a, root)
 		if err := a.SetRoot(root, true).SetBeforeDrawFunc(jobsView(a, jobsCh, root)).SetAfterDrawFunc(connectJobsView(a)).Run(); err != nil {
 			log.Fatal(err)
@@ -114,7 +122,7 @@ func inputCapture(a *tview.Application, root *tview.Pages, navi navigator) func(
 		}
 		switch event.Rune() {
 		case 'c':
-			job, err := lab.CICancel(projectID, curJob.ID)
+			job, err := labClient.CICancel(ctx, projectID, curJob.ID)
 			if err != nil {
 				a.Stop()
 				log.Fatal(err)
@@ -140,7 +148,7 @@ func inputCapture(a *tview.Application, root *tview.Pages, navi navigator) func(
 					root.RemovePage("logs-" + curJob.Name)
 					a.Draw()
 
-					job, err := lab.CIPlayOrRetry(projectID, curJob.ID, curJob.Status)
+					job, err := labClient.CIPlayOrRetry(ctx, projectID, curJob.ID, curJob.Status)
 					if err != nil {
 						a.Stop()
 						log.Fatal(err)
@@ -162,9 +170,9 @@ func inputCapture(a *tview.Application, root *tview.Pages, navi navigator) func(
 			return nil
 		case 'T':
 			a.Suspend(func() {
-				ctx, cancel := context.WithCancel(context.Background())
+				ctx, cancel := context.WithCancel(ctx)
 				go func() {
-					err := doTrace(ctx, os.Stdout, projectID, branch, curJob.Name)
+					err := doTrace(ctx, os.Stdout, projectID, pipelineID, curJob.Name)
 					if err != nil {
 						a.Stop()
 						log.Fatal(err)
@@ -323,7 +331,7 @@ func jobsView(app *tview.Application, jobsCh chan []*gitlab.Job, root *tview.Pag
 				tv.SetBorderPadding(0, 0, 1, 1).SetBorder(true)
 
 				go func() {
-					err := doTrace(context.Background(), vtclean.NewWriter(tview.ANSIIWriter(tv), true), projectID, branch, curJob.Name)
+					err := doTrace(ctx, vtclean.NewWriter(tview.ANSIIWriter(tv), true), projectID, pipelineID, curJob.Name)
 					if err != nil {
 						app.Stop()
 						log.Fatal(err)
@@ -471,20 +479,30 @@ func refreshScreen(app *tview.Application, root *tview.Pages) {
 	}
 }
 
-func updateJobs(app *tview.Application, jobsCh chan []*gitlab.Job, pid interface{}, branch string) {
+func updateJobs(app *tview.Application, jobsCh chan []*gitlab.Job, pid interface{}, pipelineID func() (int, error)) {
 	defer recoverPanic(app)
 	for {
 		if modalVisible {
 			time.Sleep(time.Second * 1)
 			continue
 		}
-		jobs, err := lab.CIJobs(pid, branch)
+		id, err := pipelineID()
+		if err != nil {
+			app.Stop()
+			log.Fatal(err)
+		}
+		jobs, err := labClient.CIJobs(ctx, pid, id)
 		if len(jobs) == 0 || err != nil {
 			app.Stop()
 			log.Fatal(errors.Wrap(err, "failed to find ci jobs"))
 		}
 		jobsCh <- latestJobs(jobs)
-		time.Sleep(time.Second * 5)
+		select {
+		case <-time.After(time.Second * 5):
+		case <-ctx.Done():
+			app.Stop()
+			log.Fatal(ctx.Err())
+		}
 	}
 }
 
@@ -626,6 +644,7 @@ func latestJobs(jobs []*gitlab.Job) []*gitlab.Job {
 
 func init() {
 	ciViewCmd.MarkZshCompPositionalArgumentCustom(1, "__lab_completion_remote")
+	addCIPipelineFlags(ciViewCmd)
 	ciViewCmd.MarkZshCompPositionalArgumentCustom(2, "__lab_completion_remote_branches $words[2]")
 	ciCmd.AddCommand(ciViewCmd)
 }
@@ -21,7 +21,7 @@ var cloneCmd = &cobra.Command{
 - namespace/repo
 - namespace/group/repo`,
 	Run: func(cmd *cobra.Command, args []string) {
-		project, err := gitlab.FindProject(args[0])
+		project, err := labClient.FindProject(ctx, args[0])
 		if err == gitlab.ErrProjectNotFound {
 			err = git.New(append([]string{"clone"}, args...)...).Run()
 			if err != nil {
@@ -44,15 +44,19 @@ var cloneCmd = &cobra.Command{
 		// Clone project was a fork belonging to the user; user is
 		// treating forks as origin. Add upstream as remoted pointing
 		// to forked from repo
+		user, err := labClient.User(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
 		if project.ForkedFromProject != nil &&
-			strings.Contains(project.PathWithNamespace, gitlab.User()) {
+			strings.Contains(project.PathWithNamespace, user) {
 			var dir string
 			if len(args) > 1 {
 				dir = args[1]
 			} else {
 				dir = project.Name
 			}
-			ffProject, err := gitlab.FindProject(project.ForkedFromProject.PathWithNamespace)
+			ffProject, err := labClient.FindProject(ctx, project.ForkedFromProject.PathWithNamespace)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -9,6 +9,7 @@ import (
 )
 
 func Test_clone(t *testing.T) {
+	skipUnlessLive(t)
 	t.Parallel()
 	repo := copyTestRepo(t)
 	cmd := exec.Command(labBinaryPath, "clone", "test")
@@ -7,7 +7,6 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/tcnksm/go-gitconfig"
 	"github.com/zaquestion/lab/internal/git"
-	lab "github.com/zaquestion/lab/internal/gitlab"
 )
 
 // forkCmd represents the fork command
@@ -26,8 +25,12 @@ var forkCmd = &cobra.Command{
 }
 
 func forkFromOrigin(cmd *cobra.Command, args []string) {
-	if _, err := gitconfig.Local("remote." + lab.User() + ".url"); err == nil {
-		log.Fatalf("remote: %s already exists", lab.User())
+	user, err := labClient.User(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := gitconfig.Local("remote." + user + ".url"); err == nil {
+		log.Fatalf("remote: %s already exists", user)
 	}
 	if _, err := gitconfig.Local("remote.upstream.url"); err == nil {
 		log.Fatal("remote: upstream already exists")
@@ -46,11 +49,11 @@ func forkFromOrigin(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	project, err := git.PathWithNameSpace("origin")
+	project, err := projectForRemote("origin")
 	if err != nil {
 		log.Fatal(err)
 	}
-	forkRemoteURL, err := lab.Fork(project)
+	forkRemoteURL, err := labClient.Fork(ctx, project)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -62,15 +65,18 @@ func forkFromOrigin(cmd *cobra.Command, args []string) {
 	}
 }
 func forkToUpstream(cmd *cobra.Command, args []string) {
-	_, err := lab.Fork(args[0])
+	_, err := labClient.Fork(ctx, args[0])
 	if err != nil {
 		log.Fatal(err)
 	}
 	cloneCmd.Run(nil, []string{strings.Split(args[0], "/")[1]})
 }
 func determineForkRemote(project string) string {
-	name := lab.User()
-	if strings.Split(project, "/")[0] == lab.User() {
+	name, err := labClient.User(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if strings.Split(project, "/")[0] == name {
 		// #78 allow upstream remote to be added when "origin" is
 		// referring to the user fork (and the fork already exists)
 		name = "upstream"
@@ -9,10 +9,10 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
-	lab "github.com/zaquestion/lab/internal/gitlab"
 )
 
 func Test_fork(t *testing.T) {
+	skipUnlessLive(t)
 	t.Parallel()
 
 	repo := copyTestRepo(t)
@@ -55,11 +55,11 @@ func Test_fork(t *testing.T) {
 	// Failing to find the project will fail the test and is a legit
 	// failure case since its the only thing asserting the project exists
 	// (was forked)
-	p, err := lab.FindProject("fork_test")
+	p, err := labClient.FindProject(ctx, "fork_test")
 	if err != nil {
 		t.Fatal(errors.Wrap(err, "failed to find project for cleanup"))
 	}
-	err = lab.ProjectDelete(p.ID)
+	err = labClient.ProjectDelete(ctx, p.ID)
 	if err != nil {
 		t.Fatal(errors.Wrap(err, "failed to delete project during cleanup"))
 	}
@@ -6,7 +6,6 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/zaquestion/lab/internal/browser"
-	"github.com/zaquestion/lab/internal/gitlab"
 )
 
 var browse = browser.Open
@@ -22,7 +21,7 @@ var issueBrowseCmd = &cobra.Command{
 			log.Fatal(err)
 		}
 
-		project, err := gitlab.FindProject(rn)
+		project, err := labClient.FindProject(ctx, rn)
 		if err != nil {
 			log.Fatal(err)
 		}
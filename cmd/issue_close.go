@@ -5,7 +5,6 @@ import (
 	"log"
 
 	"github.com/spf13/cobra"
-	lab "github.com/zaquestion/lab/internal/gitlab"
 )
 
 var issueCloseCmd = &cobra.Command{
@@ -20,12 +19,12 @@ var issueCloseCmd = &cobra.Command{
 			log.Fatal(err)
 		}
 
-		p, err := lab.FindProject(rn)
+		p, err := labClient.FindProject(ctx, rn)
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		err = lab.IssueClose(p.ID, int(id))
+		err = labClient.IssueClose(ctx, p.ID, int(id))
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -44,7 +44,12 @@ var issueCreateCmd = &cobra.Command{
 				remote = args[0]
 			}
 		}
-		rn, err := git.PathWithNameSpace(remote)
+		rn, err := projectForRemote(remote)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		assigneeIDs, err := getUserIDs(rn, assignees)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -58,12 +63,7 @@ var issueCreateCmd = &cobra.Command{
 			log.Fatal("aborting issue due to empty issue msg")
 		}
 
-		assigneeIDs := make([]int, len(assignees))
-		for i, a := range assignees {
-			assigneeIDs[i] = *getAssigneeID(a)
-		}
-
-		issueURL, err := lab.IssueCreate(rn, &gitlab.CreateIssueOptions{
+		issueURL, err := labClient.IssueCreate(ctx, rn, &gitlab.CreateIssueOptions{
 			Title:       &title,
 			Description: &body,
 			Labels:      gitlab.Labels(labels),
@@ -127,7 +127,7 @@ func issueText() (string, error) {
 func init() {
 	issueCreateCmd.Flags().StringSliceP("message", "m", []string{}, "Use the given <msg>; multiple -m are concatenated as separate paragraphs")
 	issueCreateCmd.Flags().StringSliceP("label", "l", []string{}, "Set the given label(s) on the created issue")
-	issueCreateCmd.Flags().StringSliceP("assignees", "a", []string{}, "Set assignees by username")
+	issueCreateCmd.Flags().StringSliceP("assignees", "a", []string{}, "Set assignees by username, or @group for the members of a group")
 
 	issueCreateCmd.MarkZshCompPositionalArgumentCustom(1, "__lab_completion_remote")
 	issueCmd.AddCommand(issueCreateCmd)
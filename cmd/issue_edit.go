@@ -13,7 +13,6 @@ import (
 	"github.com/spf13/pflag"
 	gitlab "github.com/xanzy/go-gitlab"
 	"github.com/zaquestion/lab/internal/git"
-	lab "github.com/zaquestion/lab/internal/gitlab"
 )
 
 var issueEditCmd = &cobra.Command{
@@ -35,17 +34,17 @@ lab issue edit <id> -l newlabel --unlabel oldlabel # relabel issue`,
 		}
 
 		// get existing issue
-		issue, err := lab.IssueGet(rn, int(issueNum))
+		issue, err := labClient.IssueGet(ctx, rn, int(issueNum))
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		labels, labelsChanged, err := issueEditGetLabels(issue, cmd.Flags())
+		labels, labelsChanged, err := editGetLabels(issue.Labels, cmd.Flags())
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		assigneeIDs, assigneesChanged, err := issueEditGetAssignees(issue, cmd.Flags())
+		assigneeIDs, assigneesChanged, err := issueEditGetAssignees(rn, issue, cmd.Flags())
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -77,7 +76,7 @@ lab issue edit <id> -l newlabel --unlabel oldlabel # relabel issue`,
 			opts.AssigneeIDs = assigneeIDs
 		}
 
-		issueURL, err := lab.IssueUpdate(rn, int(issueNum), opts)
+		issueURL, err := labClient.IssueUpdate(ctx, rn, int(issueNum), opts)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -85,10 +84,21 @@ lab issue edit <id> -l newlabel --unlabel oldlabel # relabel issue`,
 	},
 }
 
-// issueEditGetLabels returns a string slice of issues based on the current
-// issue labels and flags from the command line, and a bool indicating whether
-// the labels have changed
-func issueEditGetLabels(issue *gitlab.Issue, flags *pflag.FlagSet) ([]string, bool, error) {
+// editFlagsChanged reports whether any of the flags names was given. Unlike
+// NFlag, it ignores global flags such as --timeout.
+func editFlagsChanged(flags *pflag.FlagSet, names []string) bool {
+	for _, name := range names {
+		if flags.Changed(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// editGetLabels returns a string slice of labels based on the current labels
+// and flags from the command line, and a bool indicating whether the labels
+// have changed
+func editGetLabels(current []string, flags *pflag.FlagSet) ([]string, bool, error) {
 	// get the labels to add
 	labels, err := flags.GetStringSlice("label")
 	if err != nil {
@@ -102,52 +112,59 @@ func issueEditGetLabels(issue *gitlab.Issue, flags *pflag.FlagSet) ([]string, bo
 	}
 
 	// add the new labels to the current labels, then remove the "unlabels"
-	labels = difference(union(issue.Labels, labels), unlabels)
+	labels = difference(union(current, labels), unlabels)
 
-	return labels, !same(issue.Labels, labels), nil
+	return labels, !same(current, labels), nil
 }
 
 // issueEditGetAssignees returns an int slice of assignee IDs based on the
 // current issue assignees and flags from the command line, and a bool
 // indicating whether the assignees have changed
-func issueEditGetAssignees(issue *gitlab.Issue, flags *pflag.FlagSet) ([]int, bool, error) {
+func issueEditGetAssignees(project string, issue *gitlab.Issue, flags *pflag.FlagSet) ([]int, bool, error) {
 	currentAssignees := make([]string, len(issue.Assignees))
 	if len(issue.Assignees) > 0 && issue.Assignees[0].Username != "" {
 		for i, a := range issue.Assignees {
 			currentAssignees[i] = a.Username
 		}
 	}
+	return editGetUsers(project, currentAssignees, flags, "assign", "unassign")
+}
 
-	// get the assignees to add
-	assignees, err := flags.GetStringSlice("assign")
+// editGetUsers returns an int slice of user IDs based on the current
+// usernames and the usernames to add and remove given by the addFlag and
+// removeFlag flags, and a bool indicating whether the users have changed.
+// Unknown users are looked up among the members of project for suggestions.
+func editGetUsers(project string, current []string, flags *pflag.FlagSet, addFlag, removeFlag string) ([]int, bool, error) {
+	// get the users to add
+	users, err := flags.GetStringSlice(addFlag)
 	if err != nil {
 		return []int{}, false, err
 	}
 
-	// get the assignees to remove
-	unassignees, err := flags.GetStringSlice("unassign")
+	// get the users to remove
+	removed, err := flags.GetStringSlice(removeFlag)
 	if err != nil {
 		return []int{}, false, err
 	}
 
-	// add the new assignees to the current assignees, then remove the "unassignees"
-	assignees = difference(union(currentAssignees, assignees), unassignees)
-	assigneesChanged := !same(currentAssignees, assignees)
+	// add the new users to the current users, then remove the others
+	users = difference(union(current, users), removed)
+	usersChanged := !same(current, users)
 
-	// turn the new assignee list into a list of assignee IDs
-	var assigneeIDs []int
-	if assigneesChanged && len(assignees) == 0 {
-		// if we're removing all assignees, we have to use []int{0}
+	// turn the new user list into a list of user IDs
+	var userIDs []int
+	if usersChanged && len(users) == 0 {
+		// if we're removing all users, we have to use []int{0}
 		// see https://github.com/xanzy/go-gitlab/issues/427
-		assigneeIDs = []int{0}
+		userIDs = []int{0}
 	} else {
-		assigneeIDs = make([]int, len(assignees))
-		for i, a := range assignees {
-			assigneeIDs[i] = *getAssigneeID(a)
+		userIDs, err = getUserIDs(project, users)
+		if err != nil {
+			return []int{}, false, err
 		}
 	}
 
-	return assigneeIDs, assigneesChanged, nil
+	return userIDs, usersChanged, nil
 }
 
 // issueEditGetTitleDescription returns a title and description for an issue
@@ -175,7 +192,7 @@ func issueEditGetTitleDescription(issue *gitlab.Issue, flags *pflag.FlagSet) (st
 
 	// if other flags were given (eg label), then skip the editor and return
 	// what we already have
-	if flags.NFlag() != 0 {
+	if editFlagsChanged(flags, []string{"label", "unlabel", "assign", "unassign"}) {
 		return title, body, nil
 	}
 
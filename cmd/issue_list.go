@@ -3,10 +3,10 @@ package cmd
 import (
 	"fmt"
 	"log"
+	"os"
 
 	"github.com/spf13/cobra"
 	gitlab "github.com/xanzy/go-gitlab"
-	lab "github.com/zaquestion/lab/internal/gitlab"
 )
 
 var (
@@ -49,13 +49,14 @@ lab issue list remote "search terms"  # search "remote" for issues with "search
 		if issueAll {
 			num = -1
 		}
-		issues, err := lab.IssueList(rn, opts, num)
+		it := labClient.IssueListIter(ctx, rn, opts, num)
+		err = printList(os.Stdout, it, func(v interface{}) {
+			issue := v.(*gitlab.Issue)
+			fmt.Printf("#%d %s\n", issue.IID, issue.Title)
+		})
 		if err != nil {
 			log.Fatal(err)
 		}
-		for _, issue := range issues {
-			fmt.Printf("#%d %s\n", issue.IID, issue.Title)
-		}
 	},
 }
 
@@ -73,6 +74,8 @@ func init() {
 		&issueAll, "all", "a", false,
 		"List all issues on the project")
 
+	addFormatFlag(issueListCmd)
+
 	issueListCmd.MarkZshCompPositionalArgumentCustom(1, "__lab_completion_remote")
 	issueListCmd.MarkFlagCustom("state", "(opened closed)")
 	issueCmd.AddCommand(issueListCmd)
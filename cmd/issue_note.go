@@ -12,7 +12,6 @@ import (
 	"github.com/spf13/cobra"
 	gitlab "github.com/xanzy/go-gitlab"
 	"github.com/zaquestion/lab/internal/git"
-	lab "github.com/zaquestion/lab/internal/gitlab"
 )
 
 var issueCreateNoteCmd = &cobra.Command{
@@ -41,7 +40,7 @@ var issueCreateNoteCmd = &cobra.Command{
 			log.Fatal("aborting note due to empty note msg")
 		}
 
-		noteURL, err := lab.IssueCreateNote(rn, int(issueNum), &gitlab.CreateIssueNoteOptions{
+		noteURL, err := labClient.IssueCreateNote(ctx, rn, int(issueNum), &gitlab.CreateIssueNoteOptions{
 			Body: &body,
 		})
 		if err != nil {
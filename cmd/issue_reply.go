@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/spf13/cobra"
+	gitlab "github.com/xanzy/go-gitlab"
+	"github.com/zaquestion/lab/internal/git"
+)
+
+var issueReplyCmd = &cobra.Command{
+	Use:   "reply [remote] <id> <discussion-id>",
+	Short: "Reply to a discussion on an issue",
+	Long: `Reply to a discussion on an issue. The IDs of the discussions are shown by
+lab issue show --comments, and may be shortened as long as they stay unique.`,
+	Example: `lab issue reply 1 8f5c2a1b -m "done"  # reply to discussion 8f5c2a1b of issue 1
+lab issue reply 1 8f5c2a1b             # write the reply in $EDITOR`,
+	Args: cobra.RangeArgs(2, 3),
+	Run: func(cmd *cobra.Command, args []string) {
+		rn, issueNum, err := parseArgs(args[:len(args)-1])
+		if err != nil {
+			log.Fatal(err)
+		}
+		msgs, err := cmd.Flags().GetStringSlice("message")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		discussions, err := labClient.IssueListDiscussions(ctx, rn, int(issueNum))
+		if err != nil {
+			log.Fatal(err)
+		}
+		discussion, err := findDiscussion(discussions, args[len(args)-1])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		body, err := replyMsg(msgs, discussion)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if body == "" {
+			log.Fatal("aborting reply due to empty reply msg")
+		}
+
+		noteURL, err := labClient.IssueAddDiscussionNote(ctx, rn, int(issueNum), discussion.ID, body)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(noteURL)
+	},
+}
+
+// replyMsg returns the reply given with -m, or written in the editor below
+// the discussion being replied to
+func replyMsg(msgs []string, discussion *gitlab.Discussion) (string, error) {
+	if len(msgs) > 0 {
+		return strings.Join(msgs, "\n\n"), nil
+	}
+
+	cc := git.CommentChar()
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "\n%s Write a reply to this discussion. Commented lines are discarded.\n", cc)
+	for _, note := range discussion.Notes {
+		if note.System {
+			continue
+		}
+		fmt.Fprintf(&b, "%s\n%s %s wrote:\n", cc, cc, note.Author.Username)
+		for _, l := range strings.Split(note.Body, "\n") {
+			fmt.Fprintf(&b, "%s > %s\n", cc, l)
+		}
+	}
+	return git.EditFile("REPLY", b.String())
+}
+
+func init() {
+	issueReplyCmd.Flags().StringSliceP("message", "m", []string{}, "Use the given <msg>; multiple -m are concatenated as separate paragraphs")
+	issueReplyCmd.MarkZshCompPositionalArgumentCustom(1, "__lab_completion_remote")
+	issueReplyCmd.MarkZshCompPositionalArgumentCustom(2, "__lab_completion_issue $words[2]")
+	issueCmd.AddCommand(issueReplyCmd)
+}
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_issueReply(t *testing.T) {
+	skipIfLive(t)
+	t.Parallel()
+	repo := copyTestRepo(t)
+	id := startDiscussion(t, repo, "issues/1", "can someone reproduce this?")
+
+	cmd := exec.Command(labBinaryPath, "issue", "reply", "1", id, "-m", "I can")
+	cmd.Dir = repo
+	b, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Log(string(b))
+		t.Fatal(err)
+	}
+	require.Regexp(t, `/issues/1#note_\d+`, string(b))
+
+	cmd = exec.Command(labBinaryPath, "issue", "show", "1", "--comments")
+	cmd.Dir = repo
+	b, err = cmd.CombinedOutput()
+	if err != nil {
+		t.Log(string(b))
+		t.Fatal(err)
+	}
+	require.Contains(t, string(b), "\n    can someone reproduce this?\n")
+	require.Contains(t, string(b), "\n    I can\n")
+}
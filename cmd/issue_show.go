@@ -3,12 +3,15 @@ package cmd
 import (
 	"fmt"
 	"log"
+	"os"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	gitlab "github.com/xanzy/go-gitlab"
-	lab "github.com/zaquestion/lab/internal/gitlab"
 )
 
 var issueShowCmd = &cobra.Command{
@@ -23,25 +26,47 @@ var issueShowCmd = &cobra.Command{
 			log.Fatal(err)
 		}
 
-		issue, err := lab.IssueGet(rn, int(issueNum))
+		issue, err := labClient.IssueGet(ctx, rn, int(issueNum))
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		printIssue(issue, rn)
-
 		showComments, _ := cmd.Flags().GetBool("comments")
+
+		var discussions []*gitlab.Discussion
 		if showComments {
-			discussions, err := lab.IssueListDiscussions(rn, int(issueNum))
+			discussions, err = labClient.IssueListDiscussions(ctx, rn, int(issueNum))
 			if err != nil {
 				log.Fatal(err)
 			}
+		}
 
-			printDiscussions(discussions)
+		if structuredOutput() {
+			var v interface{} = issue
+			if showComments {
+				v = issueWithDiscussions{Issue: issue, Discussions: discussions}
+			}
+			if err := printStructured(os.Stdout, v); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+
+		printIssue(issue, rn)
+		if showComments {
+			printDiscussions(discussions, nil)
 		}
 	},
 }
 
+// issueWithDiscussions is printed by issue show --comments with --output or
+// --format. It is a single {"issue": ..., "discussions": [...]} object, while
+// templates can use the fields of the issue directly along with .Discussions.
+type issueWithDiscussions struct {
+	*gitlab.Issue `json:"issue"`
+	Discussions   []*gitlab.Discussion `json:"discussions"`
+}
+
 func printIssue(issue *gitlab.Issue, project string) {
 	milestone := "None"
 	timestats := "None"
@@ -91,7 +116,10 @@ WebURL: %s
 	)
 }
 
-func printDiscussions(discussions []*gitlab.Discussion) {
+// printDiscussions prints the discussions of an issue or merge request. The
+// diffs of a merge request, keyed by file path, are used to show the lines
+// the threads on its diff were started on.
+func printDiscussions(discussions []*gitlab.Discussion, diffs map[string]string) {
 	// for available fields, see
 	// https://godoc.org/github.com/xanzy/go-gitlab#Note
 	// https://godoc.org/github.com/xanzy/go-gitlab#Discussion
@@ -105,34 +133,149 @@ func printDiscussions(discussions []*gitlab.Discussion) {
 
 			indentHeader, indentNote := "", ""
 			commented := "commented"
+			var hunk []string
 
 			if !discussion.IndividualNote {
 				indentNote = "    "
 
 				if i == 0 {
 					commented = "started a discussion"
+					if pos := note.Position; pos != nil {
+						commented += " on " + notePositionString(pos)
+						hunk = diffHunk(diffs[pos.NewPath], pos.OldLine, pos.NewLine, 4)
+					}
+					if resolvable, resolved := discussionResolved(discussion); resolved {
+						commented += fmt.Sprintf(" (resolved by %s)", discussion.Notes[len(discussion.Notes)-1].ResolvedBy.Username)
+					} else if resolvable {
+						commented += " (unresolved)"
+					}
 				} else {
 					indentHeader = "    "
 				}
 			}
 
+			// the ID is shown on the first note, for replying to the
+			// discussion and resolving it
+			id := ""
+			if i == 0 {
+				id = " [" + shortDiscussionID(discussion.ID) + "]"
+			}
+
 			fmt.Printf(`
 %s-----------------------------------
-%s%s %s at %s
-
-%s%s
+%s%s %s at %s%s
 `,
 				indentHeader,
-				indentHeader, note.Author.Username, commented, time.Time(*note.CreatedAt).String(),
-				indentNote, note.Body)
+				indentHeader, note.Author.Username, commented, time.Time(*note.CreatedAt).String(), id)
+			if len(hunk) > 0 {
+				fmt.Printf("\n%s%s\n", indentNote, strings.Join(hunk, "\n"+indentNote))
+			}
+			fmt.Printf("\n%s%s\n", indentNote, note.Body)
+		}
+	}
+}
+
+// discussionIDLen is the length discussion IDs are shortened to when
+// printed, like commit hashes
+const discussionIDLen = 8
+
+// shortDiscussionID returns the first discussionIDLen characters of id
+func shortDiscussionID(id string) string {
+	if len(id) > discussionIDLen {
+		return id[:discussionIDLen]
+	}
+	return id
+}
+
+// findDiscussion returns the discussion whose ID starts with id
+func findDiscussion(discussions []*gitlab.Discussion, id string) (*gitlab.Discussion, error) {
+	var found *gitlab.Discussion
+	for _, d := range discussions {
+		if !strings.HasPrefix(d.ID, id) {
+			continue
+		}
+		if found != nil {
+			return nil, errors.Errorf("discussion %s is ambiguous, give more characters of its ID", id)
+		}
+		found = d
+	}
+	if found == nil {
+		return nil, errors.Errorf("discussion %s not found", id)
+	}
+	return found, nil
+}
+
+// discussionResolved reports whether any of the notes of a discussion can be
+// resolved, and whether all of those have been
+func discussionResolved(discussion *gitlab.Discussion) (resolvable bool, resolved bool) {
+	resolved = true
+	for _, note := range discussion.Notes {
+		if note.Resolvable {
+			resolvable = true
+			resolved = resolved && note.Resolved
+		}
+	}
+	return resolvable, resolvable && resolved
+}
+
+// notePositionString describes the line of a diff a note is on
+func notePositionString(pos *gitlab.NotePosition) string {
+	if pos.NewLine == 0 {
+		return fmt.Sprintf("%s, old line %d", pos.OldPath, pos.OldLine)
+	}
+	return fmt.Sprintf("%s, line %d", pos.NewPath, pos.NewLine)
+}
+
+var hunkHeaderRe = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+(\d+)(?:,\d+)? @@`)
+
+// diffHunk returns the header of the hunk of a unified diff holding a line,
+// followed by up to context lines of the hunk ending at that line. Lines
+// still in the new file are found by newLine, and removed ones by oldLine
+// when newLine is 0. Nothing is returned when the line isn't in the diff.
+func diffHunk(diff string, oldLine, newLine, context int) []string {
+	var header string
+	var lines []string
+	var oldN, newN int
+	for _, l := range strings.Split(diff, "\n") {
+		if m := hunkHeaderRe.FindStringSubmatch(l); m != nil {
+			header, lines = l, nil
+			oldN, _ = strconv.Atoi(m[1])
+			newN, _ = strconv.Atoi(m[2])
+			continue
+		}
+		if header == "" || l == "" || l[0] == '\\' {
+			continue
+		}
+
+		var found bool
+		switch l[0] {
+		case '+':
+			found = newLine != 0 && newN == newLine
+			newN++
+		case '-':
+			found = newLine == 0 && oldN == oldLine
+			oldN++
+		default:
+			found = (newLine != 0 && newN == newLine) || (newLine == 0 && oldN == oldLine)
+			oldN++
+			newN++
+		}
+		lines = append(lines, l)
+		if found {
+			if len(lines) > context {
+				lines = lines[len(lines)-context:]
+			}
+			return append([]string{header}, lines...)
 		}
 	}
+	return nil
 }
 
 func init() {
 	issueShowCmd.MarkZshCompPositionalArgumentCustom(1, "__lab_completion_remote")
 	issueShowCmd.MarkZshCompPositionalArgumentCustom(2, "__lab_completion_issue $words[2]")
 	issueShowCmd.MarkZshCompPositionalArgumentCustom(1, "__lab_completion_issue")
+	addFormatFlag(issueShowCmd)
 	issueShowCmd.Flags().BoolP("comments", "c", false, "Show comments for the issue")
 	issueCmd.AddCommand(issueShowCmd)
 }
@@ -1,10 +1,14 @@
 package cmd
 
 import (
+	"encoding/json"
 	"os/exec"
+	"strings"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	gitlab "github.com/xanzy/go-gitlab"
 )
 
 func Test_issueShow(t *testing.T) {
@@ -37,3 +41,31 @@ WebURL: https://gitlab.com/zaquestion/test/issues/1
 
 	require.Contains(t, string(b), `commented at`)
 }
+
+func Test_issueShowCommentsOutput(t *testing.T) {
+	skipIfLive(t)
+	t.Parallel()
+	repo := copyTestRepo(t)
+	cmd := exec.Command(labBinaryPath, "issue", "show", "1", "--comments", "-o", "json")
+	cmd.Dir = repo
+
+	b, err := cmd.Output()
+	require.NoError(t, err)
+	// A single document holds the issue and its discussions
+	var out struct {
+		Issue       *gitlab.Issue        `json:"issue"`
+		Discussions []*gitlab.Discussion `json:"discussions"`
+	}
+	dec := json.NewDecoder(strings.NewReader(strings.Join(getAppOutput(b), "\n")))
+	require.NoError(t, dec.Decode(&out))
+	assert.False(t, dec.More(), string(b))
+	assert.Equal(t, 1, out.Issue.IID)
+	assert.NotEmpty(t, out.Discussions)
+
+	cmd = exec.Command(labBinaryPath, "issue", "show", "1", "--comments",
+		"--format", "#{{.IID}} {{.Title}} {{if .Discussions}}has comments{{end}}")
+	cmd.Dir = repo
+	b, err = cmd.Output()
+	require.NoError(t, err)
+	assert.Equal(t, "#1 test issue for lab list has comments", getAppOutput(b)[0])
+}
@@ -3,10 +3,10 @@ package cmd
 import (
 	"fmt"
 	"log"
+	"os"
 	"strings"
 
 	"github.com/spf13/cobra"
-	lab "github.com/zaquestion/lab/internal/gitlab"
 )
 
 var labelListCmd = &cobra.Command{
@@ -26,18 +26,29 @@ lab label list remote "search term"  # search "remote" for labels with "search t
 
 		labelSearch = strings.ToLower(labelSearch)
 
-		labels, err := lab.LabelList(rn)
+		labels, err := labClient.LabelList(ctx, rn)
 		if err != nil {
 			log.Fatal(err)
 		}
 
+		matches := labels[:0]
 		for _, label := range labels {
 			// GitLab API has no search for labels, so we do it ourselves
 			if labelSearch != "" &&
 				!(strings.Contains(strings.ToLower(label.Name), labelSearch) || strings.Contains(strings.ToLower(label.Description), labelSearch)) {
 				continue
 			}
+			matches = append(matches, label)
+		}
+
+		if structuredOutput() {
+			if err := printStructured(os.Stdout, matches); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
 
+		for _, label := range matches {
 			description := ""
 			if label.Description != "" {
 				description = " - " + label.Description
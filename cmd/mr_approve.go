@@ -5,7 +5,6 @@ import (
 	"log"
 
 	"github.com/spf13/cobra"
-	lab "github.com/zaquestion/lab/internal/gitlab"
 )
 
 var mrApproveCmd = &cobra.Command{
@@ -20,12 +19,12 @@ var mrApproveCmd = &cobra.Command{
 			log.Fatal(err)
 		}
 
-		p, err := lab.FindProject(rn)
+		p, err := labClient.FindProject(ctx, rn)
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		err = lab.MRApprove(p.ID, int(id))
+		err = labClient.MRApprove(ctx, p.ID, int(id))
 		if err != nil {
 			log.Fatal(err)
 		}
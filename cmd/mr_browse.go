@@ -9,7 +9,6 @@ import (
 	"github.com/spf13/cobra"
 	gitlab "github.com/xanzy/go-gitlab"
 	git "github.com/zaquestion/lab/internal/git"
-	lab "github.com/zaquestion/lab/internal/gitlab"
 )
 
 var mrBrowseCmd = &cobra.Command{
@@ -23,7 +22,7 @@ var mrBrowseCmd = &cobra.Command{
 			log.Fatal(err)
 		}
 
-		host := lab.Host()
+		host := labClient.Host()
 		hostURL, err := url.Parse(host)
 		if err != nil {
 			log.Fatal(err)
@@ -36,7 +35,7 @@ var mrBrowseCmd = &cobra.Command{
 			if err != nil {
 				log.Fatal(err)
 			}
-			mrs, err := lab.MRList(rn, gitlab.ListProjectMergeRequestsOptions{
+			mrs, err := labClient.MRList(ctx, rn, gitlab.ListProjectMergeRequestsOptions{
 				ListOptions: gitlab.ListOptions{
 					PerPage: 10,
 				},
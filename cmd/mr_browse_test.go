@@ -12,7 +12,7 @@ func Test_mrBrowseWithParameter(t *testing.T) {
 	defer func() { browse = oldBrowse }()
 
 	browse = func(url string) error {
-		require.Equal(t, "https://gitlab.com/zaquestion/test/merge_requests/1", url)
+		require.Equal(t, labClient.Host()+"/zaquestion/test/merge_requests/1", url)
 		return nil
 	}
 
@@ -39,7 +39,7 @@ func Test_mrBrowseCurrent(t *testing.T) {
 	defer func() { browse = oldBrowse }()
 
 	browse = func(url string) error {
-		require.Equal(t, "https://gitlab.com/zaquestion/test/merge_requests/1", url)
+		require.Equal(t, labClient.Host()+"/zaquestion/test/merge_requests/1", url)
 		return nil
 	}
 
@@ -8,7 +8,6 @@ import (
 	gitconfig "github.com/tcnksm/go-gitconfig"
 	gitlab "github.com/xanzy/go-gitlab"
 	"github.com/zaquestion/lab/internal/git"
-	lab "github.com/zaquestion/lab/internal/gitlab"
 )
 
 // mrCheckoutConfig holds configuration values for calls to lab mr checkout
@@ -33,7 +32,7 @@ var checkoutCmd = &cobra.Command{
 			log.Fatal(err)
 		}
 
-		mrs, err := lab.MRList(rn, gitlab.ListProjectMergeRequestsOptions{
+		mrs, err := labClient.MRList(ctx, rn, gitlab.ListProjectMergeRequestsOptions{
 			IIDs: []int{int(mrID)},
 		}, 1)
 		if err != nil {
@@ -58,7 +57,7 @@ var checkoutCmd = &cobra.Command{
 			// Check if remote already exists
 			if _, err := gitconfig.Local("remote." + mr.Author.Username + ".url"); err != nil {
 				// Find and create remote
-				mrProject, err := lab.GetProject(mr.SourceProjectID)
+				mrProject, err := labClient.GetProject(ctx, mr.SourceProjectID)
 				if err != nil {
 					log.Fatal(err)
 				}
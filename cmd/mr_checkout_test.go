@@ -8,6 +8,7 @@ import (
 )
 
 func Test_mrCheckoutCmdRun(t *testing.T) {
+	skipUnlessLive(t)
 	t.Parallel()
 	repo := copyTestRepo(t)
 
@@ -41,6 +42,7 @@ func Test_mrCheckoutCmdRun(t *testing.T) {
 }
 
 func Test_mrCheckoutCmd_track(t *testing.T) {
+	skipUnlessLive(t)
 	t.Parallel()
 	repo := copyTestRepo(t)
 
@@ -83,6 +85,7 @@ func Test_mrCheckoutCmd_track(t *testing.T) {
 }
 
 func Test_mrCheckoutCmdRunWithDifferentName(t *testing.T) {
+	skipUnlessLive(t)
 	t.Parallel()
 	repo := copyTestRepo(t)
 
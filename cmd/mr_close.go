@@ -5,7 +5,6 @@ import (
 	"log"
 
 	"github.com/spf13/cobra"
-	lab "github.com/zaquestion/lab/internal/gitlab"
 )
 
 var mrCloseCmd = &cobra.Command{
@@ -20,12 +19,12 @@ var mrCloseCmd = &cobra.Command{
 			log.Fatal(err)
 		}
 
-		p, err := lab.FindProject(rn)
+		p, err := labClient.FindProject(ctx, rn)
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		err = lab.MRClose(p.ID, int(id))
+		err = labClient.MRClose(ctx, p.ID, int(id))
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -24,19 +24,22 @@ var mrCreateCmd = &cobra.Command{
 	Use:     "create [remote [branch]]",
 	Aliases: []string{"new"},
 	Short:   "Open a merge request on GitLab",
-	Long:    `Creates a merge request (MR created on origin master by default)`,
-	Args:    cobra.MaximumNArgs(2),
-	Run:     runMRCreate,
+	Long: `Creates a merge request into the default branch of the project forked
+from, or into the branch set with git config lab.targetbranch.`,
+	Args: cobra.MaximumNArgs(2),
+	Run:  runMRCreate,
 }
 
 func init() {
 	mrCreateCmd.Flags().StringSliceP("message", "m", []string{}, "Use the given <msg>; multiple -m are concatenated as separate paragraphs")
-	mrCreateCmd.Flags().StringP("assignee", "a", "", "Set assignee by username")
+	mrCreateCmd.Flags().StringSliceP("assignee", "a", []string{}, "Set assignees by username, or @group for the members of a group; can be specified multiple times")
+	mrCreateCmd.Flags().StringSlice("reviewer", []string{}, "Request reviews by username, or @group for the members of a group; can be specified multiple times")
 	mrCreateCmd.Flags().StringSliceP("label", "l", []string{}, "Add label <label>; can be specified multiple times for multiple labels")
 	mrCreateCmd.Flags().BoolP("remove-source-branch", "d", false, "Remove source branch from remote after merge")
 	mrCreateCmd.Flags().BoolP("squash", "s", false, "Squash commits when merging")
 	mrCreateCmd.Flags().Bool("allow-collaboration", false, "Allow commits from other members")
 	mrCreateCmd.Flags().Int("milestone", -1, "Set milestone by milestone ID")
+	mrCreateCmd.Flags().Bool("draft", false, "Open the merge request as a draft, prefixing the title with \"Draft:\"")
 	mergeRequestCmd.Flags().AddFlagSet(mrCreateCmd.Flags())
 
 	mrCreateCmd.MarkZshCompPositionalArgumentCustom(1, "__lab_completion_remote")
@@ -44,23 +47,95 @@ func init() {
 	mrCmd.AddCommand(mrCreateCmd)
 }
 
-// getAssignee returns the assigneeID for use with other GitLab API calls.
-// NOTE: It is also used by issue_create.go
-func getAssigneeID(assignee string) *int {
-	if assignee == "" {
-		return nil
+// getUserIDs returns the IDs of users, given by username, for use with other
+// GitLab API calls. An @name which isn't a user stands for the members of the
+// group name. Unknown users are an error, which suggests the member of project
+// with the closest username.
+// NOTE: It is also used by issue_create.go and issue_edit.go
+func getUserIDs(project string, users []string) ([]int, error) {
+	ids := []int{}
+	seen := make(map[int]bool)
+	add := func(id int) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
 	}
-	if assignee[0] == '@' {
-		assignee = assignee[1:]
+	for _, name := range users {
+		username := strings.TrimPrefix(name, "@")
+		id, err := labClient.UserIDFromUsername(ctx, username)
+		if err != nil {
+			return nil, err
+		}
+		if id >= 0 {
+			add(id)
+			continue
+		}
+		if strings.HasPrefix(name, "@") {
+			members, err := labClient.GroupMembers(ctx, username)
+			if err != nil {
+				return nil, err
+			}
+			if members != nil {
+				for _, m := range members {
+					add(m.ID)
+				}
+				continue
+			}
+		}
+		if suggestion := suggestUsername(project, username); suggestion != "" {
+			return nil, errors.Errorf("user %s not found, did you mean %s?", username, suggestion)
+		}
+		return nil, errors.Errorf("user %s not found", username)
 	}
-	assigneeID, err := lab.UserIDFromUsername(assignee)
+	return ids, nil
+}
+
+// suggestUsername returns the username of the member of project closest to
+// username, or "" when none is close enough to be a typo
+func suggestUsername(project, username string) string {
+	members, err := labClient.ProjectMembers(ctx, project)
 	if err != nil {
-		return nil
+		return ""
+	}
+	username = strings.ToLower(username)
+	best, bestDist := "", len(username)/3+1
+	for _, m := range members {
+		candidate := strings.ToLower(m.Username)
+		if strings.HasPrefix(candidate, username) {
+			return m.Username
+		}
+		if d := editDistance(username, candidate); d < bestDist {
+			best, bestDist = m.Username, d
+		}
 	}
-	if assigneeID == -1 {
-		return nil
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
 	}
-	return gitlab.Int(assigneeID)
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cur[j] = prev[j-1]
+			if a[i-1] != b[j-1] {
+				cur[j]++
+			}
+			if prev[j]+1 < cur[j] {
+				cur[j] = prev[j] + 1
+			}
+			if cur[j-1]+1 < cur[j] {
+				cur[j] = cur[j-1] + 1
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
 }
 
 func runMRCreate(cmd *cobra.Command, args []string) {
@@ -68,7 +143,11 @@ func runMRCreate(cmd *cobra.Command, args []string) {
 	if err != nil {
 		log.Fatal(err)
 	}
-	assignee, err := cmd.Flags().GetString("assignee")
+	assignees, err := cmd.Flags().GetStringSlice("assignee")
+	if err != nil {
+		log.Fatal(err)
+	}
+	reviewers, err := cmd.Flags().GetStringSlice("reviewer")
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -78,16 +157,16 @@ func runMRCreate(cmd *cobra.Command, args []string) {
 	}
 
 	sourceRemote := determineSourceRemote(branch)
-	sourceProjectName, err := git.PathWithNameSpace(sourceRemote)
+	sourceProjectName, err := projectForRemote(sourceRemote)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	p, err := lab.FindProject(sourceProjectName)
+	p, err := labClient.FindProject(ctx, sourceProjectName)
 	if err != nil {
 		log.Fatal(err)
 	}
-	if !lab.BranchPushed(p.ID, branch) {
+	if !labClient.BranchPushed(ctx, p.ID, branch) {
 		log.Fatalf("aborting MR, source branch %s not present on remote %s. did you forget to push?", branch, sourceRemote)
 	}
 
@@ -99,28 +178,41 @@ func runMRCreate(cmd *cobra.Command, args []string) {
 			log.Fatal(errors.Wrapf(err, "%s is not a valid remote", targetRemote))
 		}
 	}
-	targetProjectName, err := git.PathWithNameSpace(targetRemote)
+	targetProjectName, err := projectForRemote(targetRemote)
 	if err != nil {
 		log.Fatal(err)
 	}
-	targetProject, err := lab.FindProject(targetProjectName)
+	targetProject, err := labClient.FindProject(ctx, targetProjectName)
 	if err != nil {
 		log.Fatal(err)
 	}
-	targetBranch := "master"
+	targetBranch := defaultTargetBranch(targetProject)
 	if len(args) > 1 {
 		targetBranch = args[1]
-		if !lab.BranchPushed(targetProject.ID, targetBranch) {
+	}
+	if targetBranch != targetProject.DefaultBranch {
+		if !labClient.BranchPushed(ctx, targetProject.ID, targetBranch) {
 			log.Fatalf("aborting MR, target branch %s not present on remote %s. did you forget to push?", targetBranch, targetRemote)
 		}
 	}
 
+	// resolve the users before the message is typed, which would be lost
+	// to a typo in a username
+	assigneeIDs, err := getUserIDs(targetProjectName, assignees)
+	if err != nil {
+		log.Fatal(err)
+	}
+	reviewerIDs, err := getUserIDs(targetProjectName, reviewers)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	var title, body string
 
 	if len(msgs) > 0 {
 		title, body = msgs[0], strings.Join(msgs[1:], "\n\n")
 	} else {
-		msg, err := mrText(targetBranch, branch, sourceRemote, forkedFromRemote)
+		msg, err := mrText(targetBranch, branch, sourceRemote, targetRemote)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -152,19 +244,25 @@ func runMRCreate(cmd *cobra.Command, args []string) {
 	if title == "" {
 		log.Fatal("aborting MR due to empty MR msg")
 	}
+	if draft, _ := cmd.Flags().GetBool("draft"); draft {
+		title = mrDraftTitle(title, true)
+	}
 
-	mrURL, err := lab.MRCreate(sourceProjectName, &gitlab.CreateMergeRequestOptions{
-		SourceBranch:       &branch,
-		TargetBranch:       gitlab.String(targetBranch),
-		TargetProjectID:    &targetProject.ID,
-		Title:              &title,
-		Description:        &body,
-		AssigneeID:         getAssigneeID(assignee),
-		RemoveSourceBranch: &removeSourceBranch,
-		Squash:             &squash,
-		AllowCollaboration: &allowCollaboration,
-		Labels:             gitlab.Labels(labels),
-		MilestoneID:        milestone,
+	mrURL, err := labClient.MRCreate(ctx, sourceProjectName, &lab.MRCreateOptions{
+		CreateMergeRequestOptions: gitlab.CreateMergeRequestOptions{
+			SourceBranch:       &branch,
+			TargetBranch:       gitlab.String(targetBranch),
+			TargetProjectID:    &targetProject.ID,
+			Title:              &title,
+			Description:        &body,
+			RemoveSourceBranch: &removeSourceBranch,
+			Squash:             &squash,
+			AllowCollaboration: &allowCollaboration,
+			Labels:             gitlab.Labels(labels),
+			MilestoneID:        milestone,
+		},
+		AssigneeIDs: assigneeIDs,
+		ReviewerIDs: reviewerIDs,
 	})
 	if err != nil {
 		// FIXME: not exiting fatal here to allow code coverage to
@@ -175,6 +273,29 @@ func runMRCreate(cmd *cobra.Command, args []string) {
 	fmt.Println(mrURL + "/diffs")
 }
 
+// targetBranchConfig returns the branch set with git config lab.targetbranch
+// for merge requests to target, or "" when none is set
+func targetBranchConfig() string {
+	branch, err := gitconfig.Local("lab.targetbranch")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(branch)
+}
+
+// defaultTargetBranch returns the branch merge requests into p target unless
+// told otherwise: the one set with lab.targetbranch, or else the default
+// branch of p
+func defaultTargetBranch(p *gitlab.Project) string {
+	if branch := targetBranchConfig(); branch != "" {
+		return branch
+	}
+	if p.DefaultBranch != "" {
+		return p.DefaultBranch
+	}
+	return "master"
+}
+
 func determineSourceRemote(branch string) string {
 	// Check if the branch is being tracked
 	r, err := gitconfig.Local("branch." + branch + ".remote")
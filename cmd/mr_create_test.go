@@ -1,8 +1,11 @@
 package cmd
 
 import (
+	"os/exec"
+	"strings"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -28,3 +31,124 @@ I am the default merge request template for lab
 # 54fd49a (Zaq? Wiedmann`)
 
 }
+
+func Test_mrCreateTargetBranchConfig(t *testing.T) {
+	skipIfLive(t)
+	repo := copyTestRepo(t)
+	for _, args := range [][]string{
+		{"checkout", "mrtest"},
+		{"config", "lab.targetbranch", "needs/encode"},
+	} {
+		git := exec.Command("git", args...)
+		git.Dir = repo
+		if b, err := git.CombinedOutput(); err != nil {
+			t.Log(string(b))
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command(labBinaryPath, "mr", "create", "origin", "-m", "into the configured branch")
+	cmd.Dir = repo
+	b, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Log(string(b))
+		t.Fatal(err)
+	}
+	out := string(b)
+	i := strings.Index(out, "/diffs\n")
+	require.True(t, i > 0, out)
+	mrID := out[strings.LastIndex(out[:i], "/")+1 : i]
+
+	cmd = exec.Command(labBinaryPath, "mr", "show", mrID)
+	cmd.Dir = repo
+	b, err = cmd.CombinedOutput()
+	if err != nil {
+		t.Log(string(b))
+		t.Fatal(err)
+	}
+	require.Contains(t, string(b), "Branches: mrtest->needs/encode\n")
+
+	cmd = exec.Command(labBinaryPath, "mr", "close", mrID)
+	cmd.Dir = repo
+	if b, err := cmd.CombinedOutput(); err != nil {
+		t.Log(string(b))
+		t.Fatal(err)
+	}
+}
+
+func Test_mrCreateAssigneesReviewers(t *testing.T) {
+	skipIfLive(t)
+	repo := copyTestRepo(t)
+	git := exec.Command("git", "checkout", "mrtest")
+	git.Dir = repo
+	if b, err := git.CombinedOutput(); err != nil {
+		t.Log(string(b))
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(labBinaryPath, "mr", "create", "origin", "merged",
+		"-m", "assigned to a group", "-a", "@lab-testers", "-a", "lab-testing",
+		"--reviewer", "zaquestion")
+	cmd.Dir = repo
+	b, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Log(string(b))
+		t.Fatal(err)
+	}
+	out := string(b)
+	i := strings.Index(out, "/diffs\n")
+	require.True(t, i > 0, out)
+	mrID := out[strings.LastIndex(out[:i], "/")+1 : i]
+
+	cmd = exec.Command(labBinaryPath, "mr", "show", mrID)
+	cmd.Dir = repo
+	b, err = cmd.CombinedOutput()
+	if err != nil {
+		t.Log(string(b))
+		t.Fatal(err)
+	}
+	require.Contains(t, string(b), "Assignee: zaquestion, lab-testing\n")
+	require.Contains(t, string(b), "Reviewers: zaquestion\n")
+
+	cmd = exec.Command(labBinaryPath, "mr", "close", mrID)
+	cmd.Dir = repo
+	if b, err := cmd.CombinedOutput(); err != nil {
+		t.Log(string(b))
+		t.Fatal(err)
+	}
+}
+
+func Test_mrCreateUnknownUser(t *testing.T) {
+	skipIfLive(t)
+	repo := copyTestRepo(t)
+	cmd := exec.Command(labBinaryPath, "mr", "create", "origin",
+		"-m", "never created", "--reviewer", "zaqestion")
+	cmd.Dir = repo
+	b, err := cmd.CombinedOutput()
+	require.Error(t, err)
+	require.Contains(t, string(b), "user zaqestion not found, did you mean zaquestion?")
+
+	cmd = exec.Command(labBinaryPath, "mr", "create", "origin",
+		"-m", "never created", "-a", "@nobody-at-all")
+	cmd.Dir = repo
+	b, err = cmd.CombinedOutput()
+	require.Error(t, err)
+	require.Contains(t, string(b), "user nobody-at-all not found\n")
+}
+
+func Test_editDistance(t *testing.T) {
+	t.Parallel()
+	for _, test := range []struct {
+		a, b string
+		dist int
+	}{
+		{"", "", 0},
+		{"zaq", "", 3},
+		{"zaquestion", "zaquestion", 0},
+		{"zaqestion", "zaquestion", 1},
+		{"lab-tseting", "lab-testing", 2},
+		{"kitten", "sitting", 3},
+	} {
+		assert.Equal(t, test.dist, editDistance(test.a, test.b), "%s, %s", test.a, test.b)
+	}
+}
@@ -0,0 +1,314 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	gitlab "github.com/xanzy/go-gitlab"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+var mrDiffCmd = &cobra.Command{
+	Use:   "diff [remote] <id>",
+	Short: "Show the changes of a merge request",
+	Long: `Show the changes of a merge request as a unified diff, colored and paged
+through $PAGER when printing to a terminal.
+
+Each push to the source branch makes a new version of the merge request,
+numbered from 1 as listed by --versions. --from shows what changed since a
+version, up to the latest one or the one given with --to.`,
+	Example: `lab mr diff 1                  # show the changes of MR 1
+lab mr diff 1 --stat           # list the changed files
+lab mr diff 1 --versions       # list the versions of MR 1
+lab mr diff 1 --from 2         # show what changed since version 2
+lab mr diff 1 --from 2 --to 3  # show what changed between versions 2 and 3`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		rn, mrNum, err := parseArgs(args)
+		if err != nil {
+			log.Fatal(err)
+		}
+		stat, _ := cmd.Flags().GetBool("stat")
+		listVersions, _ := cmd.Flags().GetBool("versions")
+		from, _ := cmd.Flags().GetInt("from")
+		to, _ := cmd.Flags().GetInt("to")
+
+		var diffs []*gitlab.Diff
+		switch {
+		case listVersions:
+			versions, err := labClient.MRVersions(ctx, rn, int(mrNum))
+			if err != nil {
+				log.Fatal(err)
+			}
+			printMRVersions(os.Stdout, versions)
+			return
+		case from > 0 || to > 0:
+			diffs, err = mrVersionDiffs(rn, int(mrNum), from, to)
+			if err != nil {
+				log.Fatal(err)
+			}
+		default:
+			mr, err := labClient.MRChanges(ctx, rn, int(mrNum))
+			if err != nil {
+				log.Fatal(err)
+			}
+			diffs = mrChangesDiffs(mr)
+		}
+
+		useColor := terminal.IsTerminal(int(os.Stdout.Fd()))
+		w, done := startPager()
+		defer done()
+		if stat {
+			printDiffStat(w, diffs)
+			return
+		}
+		for _, d := range diffs {
+			printDiff(w, d, useColor)
+		}
+	},
+}
+
+// mrChangesDiffs returns the changes of a merge request retrieved with
+// MRChanges as diffs
+func mrChangesDiffs(mr *gitlab.MergeRequest) []*gitlab.Diff {
+	diffs := make([]*gitlab.Diff, 0, len(mr.Changes))
+	for _, c := range mr.Changes {
+		diffs = append(diffs, &gitlab.Diff{
+			OldPath:     c.OldPath,
+			NewPath:     c.NewPath,
+			AMode:       c.AMode,
+			BMode:       c.BMode,
+			Diff:        c.Diff,
+			NewFile:     c.NewFile,
+			RenamedFile: c.RenamedFile,
+			DeletedFile: c.DeletedFile,
+		})
+	}
+	return diffs
+}
+
+// mrVersionDiffs returns the diffs between two versions of a merge request,
+// numbered from 1 for the oldest. A from of 0 gives the whole diff of the
+// version to, and a to of 0 stands for the latest version.
+func mrVersionDiffs(project string, mrNum, from, to int) ([]*gitlab.Diff, error) {
+	versions, err := labClient.MRVersions(ctx, project, mrNum)
+	if err != nil {
+		return nil, err
+	}
+	// version returns the nth version, counting from the oldest one,
+	// which comes last
+	version := func(n int) (*gitlab.MergeRequestDiffVersion, error) {
+		if n < 1 || n > len(versions) {
+			return nil, errors.Errorf("version %d not found, merge request %d has %d versions", n, mrNum, len(versions))
+		}
+		return versions[len(versions)-n], nil
+	}
+	if to == 0 {
+		to = len(versions)
+	}
+	toVersion, err := version(to)
+	if err != nil {
+		return nil, err
+	}
+	if from == 0 {
+		v, err := labClient.MRVersion(ctx, project, mrNum, toVersion.ID)
+		if err != nil {
+			return nil, err
+		}
+		return v.Diffs, nil
+	}
+	fromVersion, err := version(from)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := labClient.FindProject(ctx, project)
+	if err != nil {
+		return nil, err
+	}
+	cmp, err := labClient.Compare(ctx, p.ID, fromVersion.HeadCommitSHA, toVersion.HeadCommitSHA)
+	if err != nil {
+		return nil, err
+	}
+	return cmp.Diffs, nil
+}
+
+// printMRVersions lists the versions of a merge request, the latest first as
+// returned by GitLab, numbering them from the oldest
+func printMRVersions(w io.Writer, versions []*gitlab.MergeRequestDiffVersion) {
+	for i, v := range versions {
+		created := ""
+		if v.CreatedAt != nil {
+			created = v.CreatedAt.Format(time.RFC3339)
+		}
+		head := v.HeadCommitSHA
+		if len(head) > 8 {
+			head = head[:8]
+		}
+		fmt.Fprintf(w, "%d %s %s\n", len(versions)-i, head, created)
+	}
+}
+
+// printDiff prints the diff of a file in the format of git diff, coloring it
+// when asked to
+func printDiff(w io.Writer, d *gitlab.Diff, useColor bool) {
+	meta := func(s string) string { return s }
+	hunk, added, removed := meta, meta, meta
+	if useColor {
+		meta = func(s string) string { return color("bold", s) }
+		hunk = func(s string) string { return color("cyan", s) }
+		added = func(s string) string { return color("green", s) }
+		removed = func(s string) string { return color("red", s) }
+	}
+
+	fmt.Fprintln(w, meta(fmt.Sprintf("diff --git a/%s b/%s", d.OldPath, d.NewPath)))
+	oldPath, newPath := "a/"+d.OldPath, "b/"+d.NewPath
+	switch {
+	case d.NewFile:
+		fmt.Fprintln(w, meta("new file mode "+d.BMode))
+		oldPath = "/dev/null"
+	case d.DeletedFile:
+		fmt.Fprintln(w, meta("deleted file mode "+d.AMode))
+		newPath = "/dev/null"
+	case d.RenamedFile:
+		fmt.Fprintln(w, meta("rename from "+d.OldPath))
+		fmt.Fprintln(w, meta("rename to "+d.NewPath))
+	}
+	if d.Diff == "" {
+		return
+	}
+	fmt.Fprintln(w, meta("--- "+oldPath))
+	fmt.Fprintln(w, meta("+++ "+newPath))
+
+	for _, l := range strings.Split(strings.TrimSuffix(d.Diff, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(l, "@@"):
+			l = hunk(l)
+		case strings.HasPrefix(l, "+"):
+			l = added(l)
+		case strings.HasPrefix(l, "-"):
+			l = removed(l)
+		}
+		fmt.Fprintln(w, l)
+	}
+}
+
+// diffStatWidth is the widest the +/- graph of --stat gets
+const diffStatWidth = 50
+
+// printDiffStat prints the number of lines changed in each file, like git
+// diff --stat
+func printDiffStat(w io.Writer, diffs []*gitlab.Diff) {
+	type stat struct {
+		path           string
+		added, removed int
+	}
+	var (
+		stats                    []stat
+		nameWidth, maxChanges    int
+		totalAdded, totalRemoved int
+	)
+	for _, d := range diffs {
+		s := stat{path: d.NewPath}
+		if d.RenamedFile {
+			s.path = d.OldPath + " => " + d.NewPath
+		}
+		for _, l := range strings.Split(d.Diff, "\n") {
+			switch {
+			case strings.HasPrefix(l, "@@"):
+			case strings.HasPrefix(l, "+"):
+				s.added++
+			case strings.HasPrefix(l, "-"):
+				s.removed++
+			}
+		}
+		if len(s.path) > nameWidth {
+			nameWidth = len(s.path)
+		}
+		if s.added+s.removed > maxChanges {
+			maxChanges = s.added + s.removed
+		}
+		totalAdded += s.added
+		totalRemoved += s.removed
+		stats = append(stats, s)
+	}
+
+	countWidth := len(fmt.Sprint(maxChanges))
+	for _, s := range stats {
+		added, removed := s.added, s.removed
+		if maxChanges > diffStatWidth {
+			added = added * diffStatWidth / maxChanges
+			removed = removed * diffStatWidth / maxChanges
+		}
+		fmt.Fprintf(w, " %-*s | %*d %s%s\n", nameWidth, s.path, countWidth, s.added+s.removed,
+			strings.Repeat("+", added), strings.Repeat("-", removed))
+	}
+	fmt.Fprintf(w, " %d %s changed", len(stats), pluralize(len(stats), "file", "files"))
+	if totalAdded > 0 {
+		fmt.Fprintf(w, ", %d %s(+)", totalAdded, pluralize(totalAdded, "insertion", "insertions"))
+	}
+	if totalRemoved > 0 {
+		fmt.Fprintf(w, ", %d %s(-)", totalRemoved, pluralize(totalRemoved, "deletion", "deletions"))
+	}
+	fmt.Fprintln(w)
+}
+
+func pluralize(n int, singular, plural string) string {
+	if n == 1 {
+		return singular
+	}
+	return plural
+}
+
+// startPager pipes the output to $PAGER, or less, when printing to a
+// terminal. It returns the writer to print to and a function which waits for
+// the pager to be closed.
+func startPager() (io.Writer, func()) {
+	if !terminal.IsTerminal(int(os.Stdout.Fd())) {
+		return os.Stdout, func() {}
+	}
+	pager := strings.Fields(os.Getenv("PAGER"))
+	if len(pager) == 0 {
+		pager = []string{"less"}
+	}
+	if pager[0] == "cat" {
+		return os.Stdout, func() {}
+	}
+
+	cmd := exec.Command(pager[0], pager[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if os.Getenv("LESS") == "" {
+		// Like git, quit when the output fits on the screen and keep
+		// the colors
+		cmd.Env = append(os.Environ(), "LESS=FRX")
+	}
+	w, err := cmd.StdinPipe()
+	if err != nil {
+		return os.Stdout, func() {}
+	}
+	if err := cmd.Start(); err != nil {
+		return os.Stdout, func() {}
+	}
+	return w, func() {
+		w.Close()
+		cmd.Wait()
+	}
+}
+
+func init() {
+	mrDiffCmd.Flags().Bool("stat", false, "Show the number of changed lines of each file instead of the diff")
+	mrDiffCmd.Flags().Bool("versions", false, "List the versions of the merge request")
+	mrDiffCmd.Flags().Int("from", 0, "Show the changes made since the given version")
+	mrDiffCmd.Flags().Int("to", 0, "Show the changes up to the given version instead of the latest")
+	mrDiffCmd.MarkZshCompPositionalArgumentCustom(1, "__lab_completion_remote")
+	mrDiffCmd.MarkZshCompPositionalArgumentCustom(2, "__lab_completion_merge_request $words[2]")
+	mrCmd.AddCommand(mrDiffCmd)
+}
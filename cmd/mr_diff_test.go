@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_mrDiff(t *testing.T) {
+	skipIfLive(t)
+	t.Parallel()
+	repo := copyTestRepo(t)
+	cmd := exec.Command(labBinaryPath, "mr", "diff", "2")
+	cmd.Dir = repo
+
+	b, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Log(string(b))
+		t.Fatal(err)
+	}
+	out := string(b)
+	require.Contains(t, out, `diff --git a/README.md b/README.md
+--- a/README.md
++++ b/README.md
+@@ -1,4 +1,5 @@
+`)
+	require.Contains(t, out, "\n+- open issues and merge requests are for supporting integration tests\n")
+	require.Contains(t, out, `diff --git a/.gitlab-ci.yml b/.gitlab-ci.yml
+new file mode 100644
+--- /dev/null
++++ b/.gitlab-ci.yml
+`)
+}
+
+func Test_mrDiffStat(t *testing.T) {
+	skipIfLive(t)
+	t.Parallel()
+	repo := copyTestRepo(t)
+	cmd := exec.Command(labBinaryPath, "mr", "diff", "2", "--stat")
+	cmd.Dir = repo
+
+	b, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Log(string(b))
+		t.Fatal(err)
+	}
+	require.Equal(t, ` .gitlab-ci.yml | 32 ++++++++++++++++++++++++++++++++
+ README.md      |  3 ++-
+ 2 files changed, 34 insertions(+), 1 deletion(-)`, strings.Join(getAppOutput(b), "\n"))
+}
+
+func Test_mrDiffVersions(t *testing.T) {
+	skipIfLive(t)
+	t.Parallel()
+	repo := copyTestRepo(t)
+	cmd := exec.Command(labBinaryPath, "mr", "diff", "2", "--versions")
+	cmd.Dir = repo
+
+	b, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Log(string(b))
+		t.Fatal(err)
+	}
+	out := getAppOutput(b)
+	require.Len(t, out, 2)
+	require.Regexp(t, `^2 381f2b12 `, out[0])
+	require.Regexp(t, `^1 e31383eb `, out[1])
+}
+
+func Test_mrDiffFromVersion(t *testing.T) {
+	skipIfLive(t)
+	t.Parallel()
+	repo := copyTestRepo(t)
+	cmd := exec.Command(labBinaryPath, "mr", "diff", "2", "--from", "1")
+	cmd.Dir = repo
+
+	b, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Log(string(b))
+		t.Fatal(err)
+	}
+	out := string(b)
+	require.Contains(t, out, "diff --git a/.gitlab-ci.yml b/.gitlab-ci.yml")
+	require.NotContains(t, out, "README.md")
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+	gitlab "github.com/xanzy/go-gitlab"
+	lab "github.com/zaquestion/lab/internal/gitlab"
+)
+
+var mrDraftCmd = &cobra.Command{
+	Use:   "draft [remote] <id>",
+	Short: "Mark a merge request as a draft",
+	Long: `Mark a merge request as a draft by prefixing its title with "Draft:", which
+keeps it from being merged until it is marked as ready.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		mrSetDraft(args, true)
+	},
+}
+
+var mrReadyCmd = &cobra.Command{
+	Use:   "ready [remote] <id>",
+	Short: "Mark a draft merge request as ready",
+	Long: `Mark a draft merge request as ready by removing the draft markers, such as
+"Draft:" or "WIP:", from its title.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		mrSetDraft(args, false)
+	},
+}
+
+// mrSetDraft marks the merge request given by args as a draft, or as ready
+// when draft is false
+func mrSetDraft(args []string, draft bool) {
+	rn, mrNum, err := parseArgs(args)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	mr, err := labClient.MRGet(ctx, rn, int(mrNum))
+	if err != nil {
+		log.Fatal(err)
+	}
+	state := "ready"
+	if draft {
+		state = "a draft"
+	}
+	title := mrDraftTitle(mr.Title, draft)
+	if title == mr.Title {
+		fmt.Printf("Merge Request #%d is already %s\n", mrNum, state)
+		return
+	}
+
+	_, err = labClient.MRUpdate(ctx, rn, int(mrNum), &lab.MRUpdateOptions{
+		UpdateMergeRequestOptions: gitlab.UpdateMergeRequestOptions{
+			Title: &title,
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Merge Request #%d marked as %s\n", mrNum, state)
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{mrDraftCmd, mrReadyCmd} {
+		cmd.MarkZshCompPositionalArgumentCustom(1, "__lab_completion_remote")
+		cmd.MarkZshCompPositionalArgumentCustom(2, "__lab_completion_merge_request $words[2]")
+		mrCmd.AddCommand(cmd)
+	}
+}
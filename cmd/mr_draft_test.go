@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_mrDraftReady(t *testing.T) {
+	skipIfLive(t)
+	repo := copyTestRepo(t)
+
+	git := exec.Command("git", "checkout", "mrtest")
+	git.Dir = repo
+	if b, err := git.CombinedOutput(); err != nil {
+		t.Log(string(b))
+		t.Fatal(err)
+	}
+
+	out := runLab(t, repo, "mr", "create", "origin", "mrtest2", "--draft", "-m", "early mr")
+	i := strings.Index(out, "/diffs\n")
+	require.True(t, i > 0, out)
+	mrID := out[strings.LastIndex(out[:i], "/")+1 : i]
+	defer runLab(t, repo, "mr", "close", mrID)
+
+	require.Contains(t, runLab(t, repo, "mr", "show", mrID), "#"+mrID+" Draft: early mr")
+	require.Equal(t, []string{"#" + mrID + " [draft] early mr"},
+		getAppOutput([]byte(runLab(t, repo, "mr", "list", "--draft", "--search", "early mr"))))
+
+	require.Contains(t, runLab(t, repo, "mr", "ready", mrID), "Merge Request #"+mrID+" marked as ready\n")
+	require.Contains(t, runLab(t, repo, "mr", "show", mrID), "#"+mrID+" early mr")
+	require.Contains(t, runLab(t, repo, "mr", "ready", mrID), "Merge Request #"+mrID+" is already ready\n")
+
+	require.Contains(t, runLab(t, repo, "mr", "draft", mrID), "Merge Request #"+mrID+" marked as a draft\n")
+	require.Contains(t, runLab(t, repo, "mr", "show", mrID), "#"+mrID+" Draft: early mr")
+}
@@ -0,0 +1,281 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"runtime"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	gitlab "github.com/xanzy/go-gitlab"
+	"github.com/zaquestion/lab/internal/git"
+	lab "github.com/zaquestion/lab/internal/gitlab"
+)
+
+var mrEditCmd = &cobra.Command{
+	Use:     "edit [remote] <id>",
+	Aliases: []string{"update"},
+	Short:   "Edit or update a merge request",
+	Long:    ``,
+	Example: `lab mr edit <id>                                # update MR via $EDITOR
+lab mr update <id>                              # same as above
+lab mr edit <id> -m "new title"                 # update title
+lab mr edit <id> -m "new title" -m "new desc"   # update title & description
+lab mr edit <id> -l newlabel --unlabel oldlabel # relabel MR
+lab mr edit <id> --review someone               # request a review
+lab mr edit <id> --target-branch develop        # retarget MR
+lab mr edit <id> --ready                        # mark MR as ready`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// get remote and MR from cmd arguments
+		rn, mrNum, err := parseArgs(args)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		// get existing MR
+		mr, err := labClient.MRGet(ctx, rn, int(mrNum))
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		labels, labelsChanged, err := editGetLabels(mr.Labels, cmd.Flags())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		assigneeIDs, assigneesChanged, err := editGetUsers(rn, mrAssignees(mr), cmd.Flags(), "assign", "unassign")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		reviewerIDs, reviewersChanged, err := editGetUsers(rn, usernames(mr.Reviewers), cmd.Flags(), "review", "unreview")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		title, body, err := mrEditGetTitleDescription(mr, cmd.Flags())
+		if err != nil {
+			_, f, l, _ := runtime.Caller(0)
+			log.Fatal(f+":"+strconv.Itoa(l)+" ", err)
+		}
+
+		draft, _ := cmd.Flags().GetBool("draft")
+		ready, _ := cmd.Flags().GetBool("ready")
+		if draft && ready {
+			log.Fatal("--draft and --ready can't be used together")
+		}
+		if draft || ready {
+			title = mrDraftTitle(title, draft)
+		}
+		if strings.TrimSpace(title) == "" {
+			log.Fatal("aborting: empty MR title")
+		}
+
+		opts := &lab.MRUpdateOptions{
+			UpdateMergeRequestOptions: gitlab.UpdateMergeRequestOptions{
+				Title:       &title,
+				Description: &body,
+			},
+		}
+		changed := title != mr.Title || body != mr.Description
+
+		if labelsChanged {
+			mrLabels := gitlab.Labels(labels)
+			opts.Labels = &mrLabels
+			changed = true
+		}
+
+		if assigneesChanged {
+			opts.AssigneeIDs = mrUserIDs(assigneeIDs)
+			changed = true
+		}
+
+		if reviewersChanged {
+			opts.ReviewerIDs = mrUserIDs(reviewerIDs)
+			changed = true
+		}
+
+		targetBranch, _ := cmd.Flags().GetString("target-branch")
+		if targetBranch != "" && targetBranch != mr.TargetBranch {
+			opts.TargetBranch = &targetBranch
+			changed = true
+		}
+
+		milestoneID, _ := cmd.Flags().GetInt("milestone")
+		currentMilestoneID := 0
+		if mr.Milestone != nil {
+			currentMilestoneID = mr.Milestone.ID
+		}
+		if milestoneID >= 0 && milestoneID != currentMilestoneID {
+			opts.MilestoneID = &milestoneID
+			changed = true
+		}
+
+		if cmd.Flags().Changed("squash") {
+			squash, _ := cmd.Flags().GetBool("squash")
+			if squash != mr.Squash {
+				opts.Squash = &squash
+				changed = true
+			}
+		}
+
+		if cmd.Flags().Changed("remove-source-branch") {
+			removeSourceBranch, _ := cmd.Flags().GetBool("remove-source-branch")
+			if removeSourceBranch != mr.ForceRemoveSourceBranch {
+				opts.RemoveSourceBranch = &removeSourceBranch
+				changed = true
+			}
+		}
+
+		if !changed {
+			log.Fatal("aborting: no changes")
+		}
+
+		mrURL, err := labClient.MRUpdate(ctx, rn, int(mrNum), opts)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(mrURL)
+	},
+}
+
+// mrAssignees returns the usernames of the assignees of mr, falling back on
+// the single assignee returned by older versions of GitLab
+func mrAssignees(mr *lab.MergeRequest) []string {
+	if len(mr.Assignees) > 0 {
+		return usernames(mr.Assignees)
+	}
+	if mr.Assignee.Username != "" {
+		return []string{mr.Assignee.Username}
+	}
+	return []string{}
+}
+
+// draftPrefixes are the title prefixes GitLab takes to mean a merge request
+// is a draft
+var draftPrefixes = []string{"draft:", "[draft]", "(draft)", "wip:", "[wip]"}
+
+// mrDraftTitle returns title marked as a draft, or with the draft markers
+// removed when draft is false
+func mrDraftTitle(title string, draft bool) string {
+	for stripped := false; !stripped; {
+		stripped = true
+		for _, prefix := range draftPrefixes {
+			if strings.HasPrefix(strings.ToLower(title), prefix) {
+				title = strings.TrimSpace(title[len(prefix):])
+				stripped = false
+			}
+		}
+	}
+	if draft {
+		return "Draft: " + title
+	}
+	return title
+}
+
+// mrEditFlags are the flags of mr edit which change a merge request without
+// going through the editor
+var mrEditFlags = []string{
+	"label", "unlabel", "assign", "unassign", "review", "unreview",
+	"target-branch", "milestone", "squash", "remove-source-branch", "draft", "ready",
+}
+
+// mrEditGetTitleDescription returns a title and description for a merge
+// request based on the current title and description and various flags from
+// the command line
+func mrEditGetTitleDescription(mr *lab.MergeRequest, flags *pflag.FlagSet) (string, string, error) {
+	title, body := mr.Title, mr.Description
+
+	// get all of the "message" flags
+	msgs, err := flags.GetStringSlice("message")
+	if err != nil {
+		return "", "", err
+	}
+
+	if len(msgs) > 0 {
+		title = msgs[0]
+
+		if len(msgs) > 1 {
+			body = strings.Join(msgs[1:], "\n\n")
+		}
+
+		// we have everything we need
+		return title, body, nil
+	}
+
+	// if other flags were given (eg label), then skip the editor and return
+	// what we already have
+	if editFlagsChanged(flags, mrEditFlags) {
+		return title, body, nil
+	}
+
+	text, err := mrEditText(title, body)
+	if err != nil {
+		return "", "", err
+	}
+	return git.Edit("MR_EDIT", text)
+}
+
+// mrEditText returns a merge request editing template that is suitable for
+// loading into an editor
+func mrEditText(title string, body string) (string, error) {
+	const tmpl = `{{.InitMsg}}
+
+{{.CommentChar}} Edit the title and/or description of this merge request. The
+{{.CommentChar}} first block of text is the title and the rest is the description.`
+
+	msg := &struct {
+		InitMsg     string
+		CommentChar string
+	}{
+		InitMsg:     title + "\n\n" + body,
+		CommentChar: git.CommentChar(),
+	}
+
+	t, err := template.New("tmpl").Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+
+	var b bytes.Buffer
+	err = t.Execute(&b, msg)
+	if err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+// mrUserIDs returns the user IDs of editGetUsers for MRUpdateOptions, which
+// removes all the users with an empty list rather than the []int{0} go-gitlab
+// needs
+func mrUserIDs(ids []int) *[]int {
+	if len(ids) == 1 && ids[0] == 0 {
+		ids = []int{}
+	}
+	return &ids
+}
+
+func init() {
+	mrEditCmd.Flags().StringSliceP("message", "m", []string{}, "Use the given <msg>; multiple -m are concatenated as separate paragraphs")
+	mrEditCmd.Flags().StringSliceP("label", "l", []string{}, "Add the given label(s) to the merge request")
+	mrEditCmd.Flags().StringSliceP("unlabel", "", []string{}, "Remove the given label(s) from the merge request")
+	mrEditCmd.Flags().StringSliceP("assign", "a", []string{}, "Add an assignee by username")
+	mrEditCmd.Flags().StringSliceP("unassign", "", []string{}, "Remove an assignee by username")
+	mrEditCmd.Flags().StringSlice("review", []string{}, "Request a review from a user by username")
+	mrEditCmd.Flags().StringSlice("unreview", []string{}, "Remove a reviewer by username")
+	mrEditCmd.Flags().String("target-branch", "", "Change the branch the merge request is merged into")
+	mrEditCmd.Flags().Int("milestone", -1, "Set milestone by milestone ID, 0 removes the milestone")
+	mrEditCmd.Flags().BoolP("squash", "s", false, "Squash commits when merging; --squash=false to stop squashing")
+	mrEditCmd.Flags().BoolP("remove-source-branch", "d", false, "Remove source branch from remote after merge; --remove-source-branch=false to keep it")
+	mrEditCmd.Flags().Bool("draft", false, "Mark the merge request as a draft")
+	mrEditCmd.Flags().Bool("ready", false, "Mark the merge request as ready, removing the draft marker")
+	mrEditCmd.MarkZshCompPositionalArgumentCustom(1, "__lab_completion_remote")
+	mrEditCmd.MarkZshCompPositionalArgumentCustom(2, "__lab_completion_merge_request $words[2]")
+	mrCmd.AddCommand(mrEditCmd)
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mrEditCmdTestShowMR returns the `lab mr show` output for the given MR
+func mrEditCmdTestShowMR(t *testing.T, dir string, mrNum string) string {
+	cmd := exec.Command(labBinaryPath, "mr", "show", "lab-testing", mrNum)
+	cmd.Dir = dir
+
+	b, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Log(string(b))
+		t.Fatal(err)
+	}
+
+	return string(b)
+}
+
+func Test_mrEditCmd(t *testing.T) {
+	repo := copyTestRepo(t)
+
+	// update the MR
+	cmd := exec.Command(labBinaryPath, "mr", "edit", "lab-testing", "1",
+		"-l", "critical", "--review", "lab-testing", "--draft")
+	cmd.Dir = repo
+
+	b, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Log(string(b))
+		t.Fatal(err)
+	}
+	require.Contains(t, string(b), "/lab-testing/test/merge_requests/1")
+
+	mrShowOutput := mrEditCmdTestShowMR(t, repo, "1")
+	require.Contains(t, mrShowOutput, "#1 Draft: test mr for lab note")
+	require.Contains(t, mrShowOutput, "Reviewers: lab-testing")
+	require.Contains(t, mrShowOutput, "Labels: critical")
+
+	// undo the changes
+	cmd = exec.Command(labBinaryPath, "mr", "edit", "lab-testing", "1",
+		"--unlabel", "critical", "--unreview", "lab-testing", "--ready")
+	cmd.Dir = repo
+
+	b, err = cmd.CombinedOutput()
+	if err != nil {
+		t.Log(string(b))
+		t.Fatal(err)
+	}
+
+	mrShowOutput = mrEditCmdTestShowMR(t, repo, "1")
+	require.Contains(t, mrShowOutput, "#1 test mr for lab note")
+	require.Contains(t, mrShowOutput, "Reviewers: None")
+	require.Contains(t, mrShowOutput, "Labels: None")
+}
+
+func Test_mrEditNoChanges(t *testing.T) {
+	repo := copyTestRepo(t)
+	cmd := exec.Command(labBinaryPath, "mr", "edit", "lab-testing", "1",
+		"--target-branch", "master")
+	cmd.Dir = repo
+
+	b, err := cmd.CombinedOutput()
+	require.Error(t, err)
+	require.Contains(t, string(b), "aborting: no changes")
+}
+
+func Test_mrDraftTitle(t *testing.T) {
+	tests := []struct {
+		title, draft, ready string
+	}{
+		{"title", "Draft: title", "title"},
+		{"Draft: title", "Draft: title", "title"},
+		{"WIP: title", "Draft: title", "title"},
+		{"[Draft] WIP: title", "Draft: title", "title"},
+		{"draft:title", "Draft: title", "title"},
+		{"Drafting the title", "Draft: Drafting the title", "Drafting the title"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.draft, mrDraftTitle(test.title, true))
+		assert.Equal(t, test.ready, mrDraftTitle(test.title, false))
+	}
+}
+
+func Test_mrUserIDs(t *testing.T) {
+	assert.Equal(t, []int{}, *mrUserIDs([]int{0}))
+	assert.Equal(t, []int{1, 2}, *mrUserIDs([]int{1, 2}))
+}
+
+func Test_editFlagsChanged(t *testing.T) {
+	t.Parallel()
+	flags := pflag.NewFlagSet("edit", pflag.ContinueOnError)
+	flags.Duration("timeout", 0, "")
+	flags.StringP("output", "o", "", "")
+	flags.StringSliceP("label", "l", []string{}, "")
+	flags.Bool("draft", false, "")
+
+	// Global flags don't replace the editor
+	require.NoError(t, flags.Parse([]string{"--timeout", "30s", "-o", "json"}))
+	assert.False(t, editFlagsChanged(flags, mrEditFlags))
+
+	require.NoError(t, flags.Parse([]string{"--draft"}))
+	assert.True(t, editFlagsChanged(flags, mrEditFlags))
+}
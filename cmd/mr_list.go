@@ -3,7 +3,10 @@ package cmd
 import (
 	"fmt"
 	"log"
+	"os"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	gitlab "github.com/xanzy/go-gitlab"
 	lab "github.com/zaquestion/lab/internal/gitlab"
@@ -15,6 +18,19 @@ var (
 	mrTargetBranch string
 	mrNumRet       int
 	mrAll          bool
+	mrAuthor       string
+	mrAssignee     string
+	mrReviewer     string
+	mrMine         bool
+	mrMilestone    string
+	mrDraft        bool
+	mrNotDraft     bool
+	mrSearch       string
+	mrCreatedAfter string
+	mrUpdatedAfter string
+	mrOrderBy      string
+	mrSort         string
+	mrColumns      []string
 )
 
 // listCmd represents the list command
@@ -22,33 +38,183 @@ var listCmd = &cobra.Command{
 	Use:     "list [remote]",
 	Aliases: []string{"ls"},
 	Short:   "List merge requests",
-	Long:    ``,
-	Args:    cobra.MaximumNArgs(1),
+	Long: `List merge requests, the most recently updated first. Drafts are marked
+with [draft].
+
+--columns adds tab separated columns after the title: the author, the status
+of the latest pipeline and the approvals given and required. The pipeline and
+approvals take an extra request for each merge request.`,
+	Example: `lab mr list --mine                       # list the MRs assigned to you
+lab mr list --reviewer zaq --not-draft   # list the MRs waiting for zaq's review
+lab mr list --search lint -s merged      # search the merged MRs
+lab mr list --updated-after 2019-01-01 --order-by created_at --sort asc
+lab mr list --columns author,pipeline,approvals`,
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		rn, _, err := parseArgs(args)
 		if err != nil {
 			log.Fatal(err)
 		}
 
+		opts, err := mrListOptions()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, c := range mrColumns {
+			if _, ok := mrListColumns[c]; !ok {
+				log.Fatalf("unknown column %q, the columns are author, pipeline and approvals", c)
+			}
+		}
+
 		num := mrNumRet
 		if mrAll {
 			num = -1
 		}
-		mrs, err := lab.MRList(rn, gitlab.ListProjectMergeRequestsOptions{
+		it := labClient.MRListIter(ctx, rn, opts, num)
+		err = printList(os.Stdout, it, func(v interface{}) {
+			mr := v.(*gitlab.MergeRequest)
+			fmt.Printf("#%d %s", mr.IID, mrListTitle(mr))
+			for _, c := range mrColumns {
+				fmt.Printf("\t%s", mrListColumns[c](rn, mr))
+			}
+			fmt.Println()
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+// mrListOptions returns the filters given on the command line
+func mrListOptions() (lab.MRListOptions, error) {
+	opts := lab.MRListOptions{
+		ListProjectMergeRequestsOptions: gitlab.ListProjectMergeRequestsOptions{
 			ListOptions: gitlab.ListOptions{
 				PerPage: mrNumRet,
 			},
 			Labels:       mrLabels,
 			State:        &mrState,
 			TargetBranch: &mrTargetBranch,
-			OrderBy:      gitlab.String("updated_at"),
-		}, num)
+			OrderBy:      &mrOrderBy,
+		},
+	}
+
+	if mrMine && mrAssignee != "" {
+		return opts, errors.New("--mine and --assignee can't be used together")
+	}
+	if mrMine {
+		user, err := labClient.User(ctx)
+		if err != nil {
+			return opts, err
+		}
+		mrAssignee = user
+	}
+	for _, f := range []struct {
+		username string
+		id       **int
+	}{
+		{mrAuthor, &opts.AuthorID},
+		{mrAssignee, &opts.AssigneeID},
+		{mrReviewer, &opts.ReviewerID},
+	} {
+		if f.username == "" {
+			continue
+		}
+		id, err := labClient.UserIDFromUsername(ctx, f.username)
+		if err != nil {
+			return opts, err
+		}
+		if id < 0 {
+			return opts, errors.Errorf("user %s not found", f.username)
+		}
+		*f.id = &id
+	}
+
+	if mrDraft && mrNotDraft {
+		return opts, errors.New("--draft and --not-draft can't be used together")
+	}
+	if mrDraft {
+		opts.WIP = gitlab.String("yes")
+	}
+	if mrNotDraft {
+		opts.WIP = gitlab.String("no")
+	}
+
+	if mrMilestone != "" {
+		opts.Milestone = &mrMilestone
+	}
+	if mrSearch != "" {
+		opts.Search = &mrSearch
+	}
+	if mrSort != "" {
+		opts.Sort = &mrSort
+	}
+	for _, f := range []struct {
+		name, value string
+		t           **time.Time
+	}{
+		{"created-after", mrCreatedAfter, &opts.CreatedAfter},
+		{"updated-after", mrUpdatedAfter, &opts.UpdatedAfter},
+	} {
+		if f.value == "" {
+			continue
+		}
+		t, err := parseDate(f.value)
+		if err != nil {
+			return opts, errors.Wrapf(err, "invalid --%s", f.name)
+		}
+		*f.t = &t
+	}
+	return opts, nil
+}
+
+// mrListTitle returns the title of mr, with the draft markers, whichever
+// GitLab understood, replaced by a single [draft]
+func mrListTitle(mr *gitlab.MergeRequest) string {
+	if !mr.WorkInProgress {
+		return mr.Title
+	}
+	return "[draft] " + mrDraftTitle(mr.Title, false)
+}
+
+// parseDate parses a date such as 2019-01-31, or a time in RFC 3339 format
+func parseDate(s string) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, errors.Errorf("%q is neither a date such as 2019-01-31 nor a RFC 3339 time", s)
+	}
+	return t, nil
+}
+
+// mrListColumns are the optional columns of mr list, by name
+var mrListColumns = map[string]func(project string, mr *gitlab.MergeRequest) string{
+	"author": func(project string, mr *gitlab.MergeRequest) string {
+		return mr.Author.Username
+	},
+	// The pipeline and approvals are only returned for a single merge
+	// request
+	"pipeline": func(project string, mr *gitlab.MergeRequest) string {
+		full, err := labClient.MRGet(ctx, project, mr.IID)
 		if err != nil {
 			log.Fatal(err)
 		}
-		for _, mr := range mrs {
-			fmt.Printf("#%d %s\n", mr.IID, mr.Title)
+		if full.Pipeline.Status == "" {
+			return "no pipeline"
 		}
+		return full.Pipeline.Status
+	},
+	"approvals": func(project string, mr *gitlab.MergeRequest) string {
+		approvals, err := labClient.MRApprovals(ctx, project, mr.IID)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if approvals.ApprovalsRequired > 0 {
+			return fmt.Sprintf("%d/%d approvals", len(approvals.ApprovedBy), approvals.ApprovalsRequired)
+		}
+		return fmt.Sprintf("%d approvals", len(approvals.ApprovedBy))
 	},
 }
 
@@ -65,8 +231,25 @@ func init() {
 		&mrTargetBranch, "target-branch", "t", "",
 		"filter merge requests by target branch")
 	listCmd.Flags().BoolVarP(&mrAll, "all", "a", false, "List all MRs on the project")
+	listCmd.Flags().StringVar(&mrAuthor, "author", "", "filter merge requests by author username")
+	listCmd.Flags().StringVar(&mrAssignee, "assignee", "", "filter merge requests by assignee username")
+	listCmd.Flags().StringVar(&mrReviewer, "reviewer", "", "filter merge requests by reviewer username")
+	listCmd.Flags().BoolVar(&mrMine, "mine", false, "list the merge requests assigned to you")
+	listCmd.Flags().StringVar(&mrMilestone, "milestone", "", "filter merge requests by milestone title")
+	listCmd.Flags().BoolVar(&mrDraft, "draft", false, "list draft merge requests only")
+	listCmd.Flags().BoolVar(&mrNotDraft, "not-draft", false, "leave out draft merge requests")
+	listCmd.Flags().StringVar(&mrSearch, "search", "", "search the titles and descriptions of merge requests")
+	listCmd.Flags().StringVar(&mrCreatedAfter, "created-after", "", "list merge requests created after a date, such as 2019-01-31")
+	listCmd.Flags().StringVar(&mrUpdatedAfter, "updated-after", "", "list merge requests updated after a date, such as 2019-01-31")
+	listCmd.Flags().StringVar(&mrOrderBy, "order-by", "updated_at", "order merge requests by created_at or updated_at")
+	listCmd.Flags().StringVar(&mrSort, "sort", "", "sort merge requests in asc or desc order")
+	listCmd.Flags().StringSliceVar(&mrColumns, "columns", []string{}, "add columns to the list: author, pipeline, approvals")
+
+	addFormatFlag(listCmd)
 
 	listCmd.MarkZshCompPositionalArgumentCustom(1, "__lab_completion_remote")
 	listCmd.MarkFlagCustom("state", "(opened closed merged)")
+	listCmd.MarkFlagCustom("order-by", "(created_at updated_at)")
+	listCmd.MarkFlagCustom("sort", "(asc desc)")
 	mrCmd.AddCommand(listCmd)
 }
@@ -119,3 +119,74 @@ func Test_mrListByTargetBranch(t *testing.T) {
 	mrs := strings.Split(string(b), "\n")
 	require.Equal(t, "#1 Test MR for lab list", mrs[0])
 }
+
+func Test_mrListReviewer(t *testing.T) {
+	skipIfLive(t)
+	t.Parallel()
+	repo := copyTestRepo(t)
+	cmd := exec.Command(labBinaryPath, "mr", "list", "--reviewer", "lab-testing", "--not-draft")
+	cmd.Dir = repo
+
+	b, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Log(string(b))
+		t.Fatal(err)
+	}
+
+	mrs := getAppOutput(b)
+	require.Equal(t, []string{"#3 for testings filtering with labels and lists"}, mrs)
+}
+
+func Test_mrListMine(t *testing.T) {
+	t.Parallel()
+	repo := copyTestRepo(t)
+	// lab-testing is the user of the token
+	mine := getAppOutput([]byte(runLab(t, repo, "mr", "list", "-s", "all", "--mine")))
+	require.Equal(t, getAppOutput([]byte(runLab(t, repo, "mr", "list", "-s", "all", "--assignee", "lab-testing"))), mine)
+	require.NotEqual(t, getAppOutput([]byte(runLab(t, repo, "mr", "list", "-s", "all", "--assignee", "zaquestion"))), mine)
+}
+
+func Test_mrListSearch(t *testing.T) {
+	t.Parallel()
+	repo := copyTestRepo(t)
+	cmd := exec.Command(labBinaryPath, "mr", "list", "--search", "lab list", "--author", "zaquestion",
+		"--created-after", "2018-01-01", "--order-by", "created_at", "--sort", "asc")
+	cmd.Dir = repo
+
+	b, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Log(string(b))
+		t.Fatal(err)
+	}
+
+	mrs := getAppOutput(b)
+	require.Equal(t, "#1 Test MR for lab list", mrs[0])
+}
+
+func Test_mrListUnknownAuthor(t *testing.T) {
+	t.Parallel()
+	repo := copyTestRepo(t)
+	cmd := exec.Command(labBinaryPath, "mr", "list", "--author", "not-a-lab-user")
+	cmd.Dir = repo
+
+	b, err := cmd.CombinedOutput()
+	require.Error(t, err)
+	require.Contains(t, string(b), "user not-a-lab-user not found")
+}
+
+func Test_mrListColumns(t *testing.T) {
+	skipIfLive(t)
+	t.Parallel()
+	repo := copyTestRepo(t)
+	cmd := exec.Command(labBinaryPath, "mr", "list", "-l", "confirmed", "--columns", "author,pipeline,approvals")
+	cmd.Dir = repo
+
+	b, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Log(string(b))
+		t.Fatal(err)
+	}
+
+	mrs := getAppOutput(b)
+	require.Equal(t, "#3 for testings filtering with labels and lists\tzaquestion\tno pipeline\t1/1 approvals", mrs[0])
+}
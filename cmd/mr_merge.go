@@ -1,9 +1,15 @@
 package cmd
 
 import (
+	"fmt"
 	"log"
+	"strings"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	gitlab "github.com/xanzy/go-gitlab"
+	"github.com/zaquestion/lab/internal/git"
 	lab "github.com/zaquestion/lab/internal/gitlab"
 )
 
@@ -11,27 +17,192 @@ var mrMergeCmd = &cobra.Command{
 	Use:     "merge [remote] <id>",
 	Aliases: []string{"delete"},
 	Short:   "Merge an open merge request",
-	Long:    `If the pipeline for the mr is still running, lab sets merge on success`,
-	Args:    cobra.MinimumNArgs(1),
+	Long: `If the pipeline for the mr is still running, lab sets merge on success,
+unless --immediate is given.
+
+With --wait, lab follows the merge request until it is merged, printing the
+status of its pipeline every --interval, and exits with an error when merging
+is blocked or --wait-timeout is reached.`,
+	Example: `lab mr merge 1                              # merge MR 1 once its pipeline succeeds
+lab mr merge 1 --immediate                  # merge MR 1 now
+lab mr merge 1 --squash -d                  # squash and remove the source branch
+lab mr merge 1 --sha HEAD                   # merge only if nothing was pushed since
+lab mr merge 1 --wait --interval 30s        # wait for MR 1 to be merged`,
+	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		rn, id, err := parseArgs(args)
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		p, err := lab.FindProject(rn)
-		if err != nil {
-			log.Fatal(err)
+		opts := &lab.MRMergeOptions{}
+		if immediate, _ := cmd.Flags().GetBool("immediate"); !immediate {
+			opts.MergeWhenPipelineSucceeds = gitlab.Bool(true)
+		}
+		if cmd.Flags().Changed("squash") {
+			squash, _ := cmd.Flags().GetBool("squash")
+			opts.Squash = &squash
+		}
+		if msg, _ := cmd.Flags().GetString("squash-message"); msg != "" {
+			opts.Squash = gitlab.Bool(true)
+			opts.SquashCommitMessage = &msg
+		}
+		if msg, _ := cmd.Flags().GetString("merge-commit-message"); msg != "" {
+			opts.MergeCommitMessage = &msg
+		}
+		if sha, _ := cmd.Flags().GetString("sha"); sha != "" {
+			// Commits the repository doesn't have, such as those
+			// pushed by others, are passed on as they are
+			if commit, err := git.RevParse(sha); err == nil {
+				sha = commit
+			}
+			opts.SHA = &sha
+		}
+		if cmd.Flags().Changed("remove-source-branch") {
+			removeSourceBranch, _ := cmd.Flags().GetBool("remove-source-branch")
+			opts.ShouldRemoveSourceBranch = &removeSourceBranch
 		}
 
-		err = lab.MRMerge(p.ID, int(id))
+		mr, err := labClient.MRMerge(ctx, rn, int(id), opts)
 		if err != nil {
+			// GitLab answers 405 without saying why merging is
+			// blocked, so look it up
+			if current, getErr := labClient.MRGet(ctx, rn, int(id)); getErr == nil {
+				if reason := mrBlockedReason(current); reason != "" {
+					log.Fatalf("Merge Request #%d can't be merged: %s", id, reason)
+				}
+			}
 			log.Fatal(err)
 		}
+		if mr.State == "merged" {
+			fmt.Printf("Merge Request #%d merged\n", id)
+			return
+		}
+		autoMerge := opts.MergeWhenPipelineSucceeds != nil
+		if autoMerge {
+			fmt.Printf("Merge Request #%d will be merged when the pipeline succeeds\n", id)
+		} else {
+			// GitLab may still be merging in the background
+			fmt.Printf("Merge Request #%d is %s, merge status: %s\n", id, mr.State, strings.Replace(mr.MergeStatus, "_", " ", -1))
+		}
+
+		if wait, _ := cmd.Flags().GetBool("wait"); wait {
+			interval, _ := cmd.Flags().GetDuration("interval")
+			timeout, _ := cmd.Flags().GetDuration("wait-timeout")
+			if err := mrMergeWait(rn, int(id), autoMerge, interval, timeout); err != nil {
+				log.Fatal(err)
+			}
+			fmt.Printf("Merge Request #%d merged\n", id)
+		}
 	},
 }
 
+// mrMergeWait waits until the merge request is merged, checking it every
+// interval and printing the status of its pipeline as it changes. It returns
+// an error as soon as the merge request can't be merged, or once timeout is
+// reached unless it is 0. With autoMerge, the merge request was set to be
+// merged when its pipeline succeeds, so a failed pipeline or the merge being
+// called off are errors too.
+func mrMergeWait(project string, mrNum int, autoMerge bool, interval, timeout time.Duration) error {
+	if interval <= 0 {
+		return errors.New("--interval must be positive")
+	}
+	var timedOut <-chan time.Time
+	if timeout > 0 {
+		timedOut = time.After(timeout)
+	}
+	lastStatus := ""
+	for {
+		mr, err := labClient.MRGet(ctx, project, mrNum)
+		if err != nil {
+			return err
+		}
+		switch mr.State {
+		case "merged":
+			return nil
+		case "closed":
+			return errors.Errorf("Merge Request #%d was closed", mrNum)
+		}
+		if reason := mrBlockedReason(mr); reason != "" {
+			return errors.Errorf("Merge Request #%d can't be merged: %s", mrNum, reason)
+		}
+
+		if mr.Pipeline.ID != 0 {
+			status := fmt.Sprintf("Pipeline #%d %s", mr.Pipeline.ID, mr.Pipeline.Status)
+			if status != lastStatus {
+				fmt.Println(status)
+				lastStatus = status
+			}
+			switch mr.Pipeline.Status {
+			case "failed", "canceled":
+				if autoMerge {
+					return errors.Errorf("Merge Request #%d can't be merged: pipeline #%d %s", mrNum, mr.Pipeline.ID, mr.Pipeline.Status)
+				}
+			}
+		}
+		if autoMerge && !mr.MergeWhenPipelineSucceeds {
+			return errors.Errorf("Merge Request #%d is no longer set to be merged", mrNum)
+		}
+
+		select {
+		case <-time.After(interval):
+		case <-timedOut:
+			return errors.Errorf("timed out after %s waiting for Merge Request #%d to be merged", timeout, mrNum)
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "stopped waiting for the merge request")
+		}
+	}
+}
+
+// mrBlockedReasons describe the detailed merge statuses which block merging
+// until someone acts. Other statuses, such as "ci_still_running", clear by
+// themselves.
+var mrBlockedReasons = map[string]string{
+	"conflict":                 "it has conflicts",
+	"discussions_not_resolved": "it has unresolved threads",
+	"draft_status":             "it is a draft",
+	"not_approved":             "it is missing approvals",
+	"requested_changes":        "a reviewer requested changes",
+	"need_rebase":              "it needs to be rebased",
+	"merge_request_blocked":    "it is blocked by another merge request",
+	"not_open":                 "it is not open",
+	"status_checks_must_pass":  "its external status checks must pass",
+	"jira_association_missing": "its title or description must reference a Jira issue",
+}
+
+// mrBlockedReason returns why a merge request can't be merged, or "" when
+// nothing blocks it
+func mrBlockedReason(mr *lab.MergeRequest) string {
+	switch mr.DetailedMergeStatus {
+	case "", "mergeable", "checking", "unchecked", "preparing", "approvals_syncing", "ci_still_running", "ci_must_pass":
+	default:
+		if reason, ok := mrBlockedReasons[mr.DetailedMergeStatus]; ok {
+			return reason
+		}
+		return strings.Replace(mr.DetailedMergeStatus, "_", " ", -1)
+	}
+
+	// GitLab versions before 15.6 only report some of the reasons
+	switch {
+	case mr.HasConflicts:
+		return mrBlockedReasons["conflict"]
+	case mr.WorkInProgress:
+		return mrBlockedReasons["draft_status"]
+	case mr.BlockingDiscussionsResolved != nil && !*mr.BlockingDiscussionsResolved:
+		return mrBlockedReasons["discussions_not_resolved"]
+	}
+	return ""
+}
+
 func init() {
+	mrMergeCmd.Flags().BoolP("squash", "s", false, "Squash the commits into one when merging; --squash=false to keep them")
+	mrMergeCmd.Flags().String("squash-message", "", "Use the given message for the squashed commit, implies --squash")
+	mrMergeCmd.Flags().String("merge-commit-message", "", "Use the given message for the merge commit")
+	mrMergeCmd.Flags().String("sha", "", "Merge only if the HEAD of the source branch is the given commit, such as HEAD")
+	mrMergeCmd.Flags().BoolP("remove-source-branch", "d", false, "Remove the source branch once merged; --remove-source-branch=false to keep it")
+	mrMergeCmd.Flags().Bool("immediate", false, "Merge now rather than when the pipeline succeeds")
+	mrMergeCmd.Flags().Bool("wait", false, "Wait until the merge request is merged, printing the status of its pipeline")
+	addCIWaitFlags(mrMergeCmd)
 	mrMergeCmd.MarkZshCompPositionalArgumentCustom(1, "__lab_completion_remote")
 	mrMergeCmd.MarkZshCompPositionalArgumentCustom(2, "__lab_completion_merge_request $words[2]")
 	mrCmd.AddCommand(mrMergeCmd)
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gitlab "github.com/xanzy/go-gitlab"
+	"github.com/zaquestion/lab/internal/git"
+	lab "github.com/zaquestion/lab/internal/gitlab"
+	"github.com/zaquestion/lab/internal/gitlabtest"
+)
+
+func Test_mrMerge(t *testing.T) {
+	skipIfLive(t)
+	t.Parallel()
+	repo := copyTestRepo(t)
+
+	var mr struct {
+		IID                      int    `json:"iid"`
+		State                    string `json:"state"`
+		Squash                   bool   `json:"squash"`
+		ShouldRemoveSourceBranch bool   `json:"should_remove_source_branch"`
+	}
+
+	out := runLab(t, repo, "api", "post", "projects/:id/merge_requests",
+		"-f", "source_branch=merged", "-f", "target_branch=mrtest2", "-f", "title=Draft: merge options")
+	require.NoError(t, json.Unmarshal([]byte(strings.Join(getAppOutput([]byte(out)), "\n")), &mr))
+	id := strconv.Itoa(mr.IID)
+
+	out, err := runLabErr(repo, "mr", "merge", id)
+	require.Error(t, err)
+	assert.Contains(t, out, "Merge Request #"+id+" can't be merged: it is a draft")
+
+	runLab(t, repo, "mr", "edit", id, "--ready")
+
+	out, err = runLabErr(repo, "mr", "merge", id, "--sha", "9bb1cea7cd73afe2dbea016203b30955128ad477")
+	require.Error(t, err)
+	assert.Contains(t, out, "SHA does not match HEAD of source branch")
+
+	out = runLab(t, repo, "mr", "merge", id, "--squash-message", "merge options", "-d", "--immediate", "--wait")
+	assert.Equal(t, []string{"Merge Request #" + id + " merged"}, getAppOutput([]byte(out)))
+
+	out = runLab(t, repo, "api", "get", "projects/:id/merge_requests/"+id)
+	require.NoError(t, json.Unmarshal([]byte(strings.Join(getAppOutput([]byte(out)), "\n")), &mr))
+	assert.Equal(t, "merged", mr.State)
+	assert.True(t, mr.Squash)
+	assert.True(t, mr.ShouldRemoveSourceBranch)
+}
+
+func Test_mrMergeWait(t *testing.T) {
+	skipIfLive(t)
+	// The pipelines of a server of its own are run in-process
+	srv := gitlabtest.NewServer()
+	defer srv.Close()
+	srv.AddUser("lab-testing", "merge-wait")
+	project := srv.AddProject(&gitlab.Project{PathWithNamespace: "zaquestion/test"})
+	oldClient := labClient
+	defer func() { labClient = oldClient }()
+	labClient = lab.NewClient(lab.Config{Host: srv.URL, User: "lab-testing", Token: "merge-wait"})
+
+	head, err := git.RevParse("HEAD")
+	require.NoError(t, err)
+	// addMR adds a merge request whose pipeline is running, and sets the
+	// status of the pipeline to status a little later
+	addMR := func(branch, status string) (int, int) {
+		pl := srv.AddPipeline(project.ID, &gitlab.Pipeline{Ref: branch, SHA: head, Status: "running"})
+		mr := &gitlab.MergeRequest{Title: branch, SourceBranch: branch, TargetBranch: "master", SHA: head}
+		mr.Pipeline.ID = pl.ID
+		mr.Pipeline.Status = pl.Status
+		srv.AddMergeRequest(project.ID, mr)
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			srv.SetPipelineStatus(project.ID, pl.ID, status)
+		}()
+		return mr.IID, pl.ID
+	}
+
+	t.Run("success", func(t *testing.T) {
+		id, _ := addMR("passes", "success")
+		for name, value := range map[string]string{"wait": "true", "interval": "10ms", "sha": "HEAD"} {
+			require.NoError(t, mrMergeCmd.Flags().Set(name, value))
+		}
+		defer func() {
+			mrMergeCmd.Flags().Set("wait", "false")
+			mrMergeCmd.Flags().Set("interval", "5s")
+			mrMergeCmd.Flags().Set("sha", "")
+		}()
+		mrMergeCmd.Run(mrMergeCmd, []string{strconv.Itoa(id)})
+
+		mr, err := labClient.MRGet(ctx, project.PathWithNamespace, id)
+		require.NoError(t, err)
+		assert.Equal(t, "merged", mr.State)
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		id, pipelineID := addMR("fails", "failed")
+		mr, err := labClient.MRMerge(ctx, project.PathWithNamespace, id, &lab.MRMergeOptions{
+			MergeWhenPipelineSucceeds: gitlab.Bool(true),
+		})
+		require.NoError(t, err)
+		require.True(t, mr.MergeWhenPipelineSucceeds)
+
+		err = mrMergeWait(project.PathWithNamespace, id, true, 10*time.Millisecond, time.Minute)
+		require.Error(t, err)
+		assert.Equal(t, "Merge Request #"+strconv.Itoa(id)+" can't be merged: pipeline #"+strconv.Itoa(pipelineID)+" failed", err.Error())
+	})
+
+	t.Run("immediate", func(t *testing.T) {
+		// The failed pipeline doesn't stop the merge, which GitLab
+		// finishes in the background
+		id, _ := addMR("immediate", "failed")
+		go func() {
+			time.Sleep(200 * time.Millisecond)
+			labClient.MRMerge(ctx, project.PathWithNamespace, id, &lab.MRMergeOptions{})
+		}()
+
+		err := mrMergeWait(project.PathWithNamespace, id, false, 10*time.Millisecond, time.Minute)
+		require.NoError(t, err)
+	})
+}
@@ -12,7 +12,6 @@ import (
         "github.com/spf13/cobra"
         gitlab "github.com/xanzy/go-gitlab"
         "github.com/zaquestion/lab/internal/git"
-        lab "github.com/zaquestion/lab/internal/gitlab"
 )
 
 var mrCreateNoteCmd = &cobra.Command{
@@ -41,7 +40,7 @@ var mrCreateNoteCmd = &cobra.Command{
                         log.Fatal("aborting note due to empty note msg")
                 }
 
-                noteURL, err := lab.MRCreateNote(rn, int(mrNum), &gitlab.CreateMergeRequestNoteOptions{
+                noteURL, err := labClient.MRCreateNote(ctx, rn, int(mrNum), &gitlab.CreateMergeRequestNoteOptions{
                         Body: &body,
                 })
                 if err != nil {
@@ -4,7 +4,6 @@ import (
 	"log"
 
 	"github.com/spf13/cobra"
-	lab "github.com/zaquestion/lab/internal/gitlab"
 )
 
 var mrRebaseCmd = &cobra.Command{
@@ -18,12 +17,12 @@ var mrRebaseCmd = &cobra.Command{
 			log.Fatal(err)
 		}
 
-		p, err := lab.FindProject(rn)
+		p, err := labClient.FindProject(ctx, rn)
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		err = lab.MRRebase(p.ID, int(id))
+		err = labClient.MRRebase(ctx, p.ID, int(id))
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+var mrReplyCmd = &cobra.Command{
+	Use:   "reply [remote] <id> <discussion-id>",
+	Short: "Reply to a discussion on a merge request",
+	Long: `Reply to a discussion on a merge request, such as a thread on its diff.
+The IDs of the discussions are shown by lab mr show --comments, and may be
+shortened as long as they stay unique.`,
+	Example: `lab mr reply 1 8f5c2a1b -m "fixed"            # reply to discussion 8f5c2a1b of MR 1
+lab mr reply 1 8f5c2a1b -m "fixed" --resolve  # reply and resolve the thread
+lab mr reply 1 8f5c2a1b                       # write the reply in $EDITOR`,
+	Args: cobra.RangeArgs(2, 3),
+	Run: func(cmd *cobra.Command, args []string) {
+		rn, mrNum, err := parseArgs(args[:len(args)-1])
+		if err != nil {
+			log.Fatal(err)
+		}
+		msgs, err := cmd.Flags().GetStringSlice("message")
+		if err != nil {
+			log.Fatal(err)
+		}
+		resolve, _ := cmd.Flags().GetBool("resolve")
+
+		discussions, err := labClient.MRListDiscussions(ctx, rn, int(mrNum))
+		if err != nil {
+			log.Fatal(err)
+		}
+		discussion, err := findDiscussion(discussions, args[len(args)-1])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		body, err := replyMsg(msgs, discussion)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if body == "" {
+			log.Fatal("aborting reply due to empty reply msg")
+		}
+
+		noteURL, err := labClient.MRAddDiscussionNote(ctx, rn, int(mrNum), discussion.ID, body)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(noteURL)
+
+		if resolve {
+			if err := labClient.MRResolveDiscussion(ctx, rn, int(mrNum), discussion.ID, true); err != nil {
+				log.Fatal(err)
+			}
+			fmt.Printf("Discussion %s resolved\n", shortDiscussionID(discussion.ID))
+		}
+	},
+}
+
+func init() {
+	mrReplyCmd.Flags().StringSliceP("message", "m", []string{}, "Use the given <msg>; multiple -m are concatenated as separate paragraphs")
+	mrReplyCmd.Flags().Bool("resolve", false, "Resolve the discussion after replying")
+	mrReplyCmd.MarkZshCompPositionalArgumentCustom(1, "__lab_completion_remote")
+	mrReplyCmd.MarkZshCompPositionalArgumentCustom(2, "__lab_completion_merge_request $words[2]")
+	mrCmd.AddCommand(mrReplyCmd)
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// startDiscussion starts a thread using lab api and returns its short ID
+func startDiscussion(t *testing.T, repo, noteable, body string) string {
+	cmd := exec.Command(labBinaryPath, "api", "post", "projects/:id/"+noteable+"/discussions", "-f", "body="+body)
+	cmd.Dir = repo
+	b, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var d struct {
+		ID string `json:"id"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(strings.Join(getAppOutput(b), "\n")), &d))
+	require.NotEmpty(t, d.ID)
+	return shortDiscussionID(d.ID)
+}
+
+func Test_mrReplyResolve(t *testing.T) {
+	skipIfLive(t)
+	t.Parallel()
+	repo := copyTestRepo(t)
+	id := startDiscussion(t, repo, "merge_requests/1", "is this tested?")
+
+	out := runLab(t, repo, "mr", "show", "1", "--comments")
+	require.Regexp(t, `lab-testing started a discussion \(unresolved\) at .* \[`+id+`\]`, out)
+
+	out = runLab(t, repo, "mr", "reply", "1", id, "-m", "yes in mr_reply_test.go")
+	require.Regexp(t, `/merge_requests/1#note_\d+`, out)
+
+	out = runLab(t, repo, "mr", "resolve", "1", id)
+	require.Contains(t, out, "Discussion "+id+" resolved")
+
+	out = runLab(t, repo, "mr", "show", "1", "--comments")
+	require.Contains(t, out, "lab-testing started a discussion (resolved by lab-testing) at")
+	require.Contains(t, out, "\n    yes in mr_reply_test.go\n")
+
+	out = runLab(t, repo, "mr", "unresolve", "1", id)
+	require.Contains(t, out, "Discussion "+id+" unresolved")
+
+	out = runLab(t, repo, "mr", "reply", "1", id, "-m", "thanks", "--resolve")
+	require.Contains(t, out, "Discussion "+id+" resolved")
+}
+
+func Test_mrReplyUnknownDiscussion(t *testing.T) {
+	skipIfLive(t)
+	t.Parallel()
+	repo := copyTestRepo(t)
+	cmd := exec.Command(labBinaryPath, "mr", "reply", "1", "xyz", "-m", "hello")
+	cmd.Dir = repo
+
+	b, _ := cmd.CombinedOutput()
+	require.Contains(t, string(b), "discussion xyz not found")
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+var mrResolveCmd = &cobra.Command{
+	Use:   "resolve [remote] <id> <discussion-id>",
+	Short: "Resolve a discussion on a merge request",
+	Long: `Resolve a discussion on a merge request. The IDs of the discussions are
+shown by lab mr show --comments, and may be shortened as long as they stay
+unique.`,
+	Args: cobra.RangeArgs(2, 3),
+	Run: func(cmd *cobra.Command, args []string) {
+		mrResolve(args, true)
+	},
+}
+
+var mrUnresolveCmd = &cobra.Command{
+	Use:   "unresolve [remote] <id> <discussion-id>",
+	Short: "Reopen a resolved discussion on a merge request",
+	Long: `Reopen a resolved discussion on a merge request. The IDs of the discussions
+are shown by lab mr show --comments, and may be shortened as long as they stay
+unique.`,
+	Args: cobra.RangeArgs(2, 3),
+	Run: func(cmd *cobra.Command, args []string) {
+		mrResolve(args, false)
+	},
+}
+
+// mrResolve resolves or unresolves the discussion given by the last of args
+func mrResolve(args []string, resolved bool) {
+	rn, mrNum, err := parseArgs(args[:len(args)-1])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	discussions, err := labClient.MRListDiscussions(ctx, rn, int(mrNum))
+	if err != nil {
+		log.Fatal(err)
+	}
+	discussion, err := findDiscussion(discussions, args[len(args)-1])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := labClient.MRResolveDiscussion(ctx, rn, int(mrNum), discussion.ID, resolved); err != nil {
+		log.Fatal(err)
+	}
+	if resolved {
+		fmt.Printf("Discussion %s resolved\n", shortDiscussionID(discussion.ID))
+	} else {
+		fmt.Printf("Discussion %s unresolved\n", shortDiscussionID(discussion.ID))
+	}
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{mrResolveCmd, mrUnresolveCmd} {
+		cmd.MarkZshCompPositionalArgumentCustom(1, "__lab_completion_remote")
+		cmd.MarkZshCompPositionalArgumentCustom(2, "__lab_completion_merge_request $words[2]")
+		mrCmd.AddCommand(cmd)
+	}
+}
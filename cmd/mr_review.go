@@ -0,0 +1,255 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	gitlab "github.com/xanzy/go-gitlab"
+	"github.com/zaquestion/lab/internal/git"
+)
+
+var mrReviewCmd = &cobra.Command{
+	Use:   "review [remote] <id>",
+	Short: "Review a merge request, commenting on the lines of its diff",
+	Long: `Open the diff of a merge request in $EDITOR to review it. The diff is
+quoted with "> ", and the text typed below a quoted line is posted as a thread
+on that line. Text typed above the diff is posted as a general comment.
+
+--approve approves the merge request once the comments are posted, and
+--unapprove withdraws your approval, if any, such as when the comments ask for
+changes. Neither happens when a comment fails to be posted.`,
+	Example: `lab mr review 1              # comment on the diff of MR 1
+lab mr review 1 --approve    # comment and approve
+lab mr review 1 --unapprove  # comment and withdraw your approval`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		rn, mrNum, err := parseArgs(args)
+		if err != nil {
+			log.Fatal(err)
+		}
+		approve, _ := cmd.Flags().GetBool("approve")
+		unapprove, _ := cmd.Flags().GetBool("unapprove")
+		if approve && unapprove {
+			log.Fatal("--approve and --unapprove can't be used together")
+		}
+
+		mr, err := labClient.MRChanges(ctx, rn, int(mrNum))
+		if err != nil {
+			log.Fatal(err)
+		}
+		diffs := mrChangesDiffs(mr)
+
+		text, err := git.EditFile("MR_REVIEW", mrReviewText(mr, diffs))
+		if err != nil {
+			log.Fatal(err)
+		}
+		summary, comments, err := parseMRReview(text, diffs)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if summary == "" && len(comments) == 0 && !approve && !unapprove {
+			log.Fatal("aborting review: no comments")
+		}
+
+		// Posting goes on after a failure, so that the comments which
+		// weren't posted can be told apart from those which were
+		var failed []string
+		for _, c := range comments {
+			body := c.Body()
+			noteURL, err := labClient.MRCreateDiscussion(ctx, rn, int(mrNum), &gitlab.CreateMergeRequestDiscussionOptions{
+				Body: &body,
+				Position: &gitlab.NotePosition{
+					BaseSHA:      mr.DiffRefs.BaseSha,
+					StartSHA:     mr.DiffRefs.StartSha,
+					HeadSHA:      mr.DiffRefs.HeadSha,
+					PositionType: "text",
+					OldPath:      c.diff.OldPath,
+					NewPath:      c.diff.NewPath,
+					OldLine:      c.oldLine,
+					NewLine:      c.newLine,
+				},
+			})
+			if err != nil {
+				failed = append(failed, fmt.Sprintf("the comment on %s: %v\n%s", c.Location(), err, c.Body()))
+				continue
+			}
+			fmt.Println(noteURL)
+		}
+		if summary != "" {
+			noteURL, err := labClient.MRCreateNote(ctx, rn, int(mrNum), &gitlab.CreateMergeRequestNoteOptions{
+				Body: &summary,
+			})
+			if err != nil {
+				failed = append(failed, fmt.Sprintf("the general comment: %v\n%s", err, summary))
+			} else {
+				fmt.Println(noteURL)
+			}
+		}
+		if len(failed) > 0 {
+			log.Fatalf("failed to post %d of the comments, the others are above:\n\n%s",
+				len(failed), strings.Join(failed, "\n\n"))
+		}
+
+		if !approve && !unapprove {
+			return
+		}
+		p, err := labClient.FindProject(ctx, rn)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if approve {
+			if err := labClient.MRApprove(ctx, p.ID, int(mrNum)); err != nil {
+				log.Fatal(err)
+			}
+			fmt.Printf("Merge Request #%d approved\n", mrNum)
+			return
+		}
+		if err := labClient.MRUnapprove(ctx, p.ID, int(mrNum)); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Merge Request #%d unapproved\n", mrNum)
+	},
+}
+
+// mrReviewText returns the diffs of a merge request quoted for commenting in
+// an editor
+func mrReviewText(mr *gitlab.MergeRequest, diffs []*gitlab.Diff) string {
+	cc := git.CommentChar()
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s Reviewing !%d: %s\n", cc, mr.IID, mr.Title)
+	fmt.Fprintf(&b, "%s\n", cc)
+	fmt.Fprintf(&b, "%s Write comments below the quoted lines of the diff they are about, and\n", cc)
+	fmt.Fprintf(&b, "%s general comments above the diff. Leave the quoted lines as they are,\n", cc)
+	fmt.Fprintf(&b, "%s they tell which line a comment is about. Commented lines are discarded.\n\n", cc)
+
+	var diff bytes.Buffer
+	for _, d := range diffs {
+		printDiff(&diff, d, false)
+	}
+	for _, l := range strings.Split(strings.TrimSuffix(diff.String(), "\n"), "\n") {
+		fmt.Fprintf(&b, "> %s\n", l)
+	}
+	return b.String()
+}
+
+// reviewComment is a comment on a line of the diff of a merge request. Added
+// lines only have a newLine, removed lines only an oldLine.
+type reviewComment struct {
+	diff             *gitlab.Diff
+	oldLine, newLine int
+	lines            []string
+}
+
+// Body returns the text of the comment
+func (c *reviewComment) Body() string {
+	return strings.Join(c.lines, "\n")
+}
+
+// Location returns the file and line the comment is on
+func (c *reviewComment) Location() string {
+	if c.newLine == 0 {
+		return fmt.Sprintf("removed line %s:%d", c.diff.OldPath, c.oldLine)
+	}
+	return fmt.Sprintf("line %s:%d", c.diff.NewPath, c.newLine)
+}
+
+// parseMRReview splits a review written in the text of mrReviewText into the
+// general comment typed above the diff and the comments on its lines
+func parseMRReview(text string, diffs []*gitlab.Diff) (string, []*reviewComment, error) {
+	var (
+		summary  []string
+		comments []*reviewComment
+		// comment is the comment being typed, nil after a quoted line
+		comment *reviewComment
+
+		file             = -1
+		inHunk           bool
+		oldLine, newLine int
+		oldNext, newNext int
+	)
+	for _, l := range strings.Split(text, "\n") {
+		if !strings.HasPrefix(l, ">") {
+			if file < 0 {
+				summary = append(summary, l)
+				continue
+			}
+			if strings.TrimSpace(l) == "" && comment == nil {
+				continue
+			}
+			switch {
+			case comment != nil:
+				comment.lines = append(comment.lines, l)
+			case oldLine == 0 && newLine == 0:
+				return "", nil, errors.Errorf("comment %q is not below a line of the diff of %s", l, diffs[file].NewPath)
+			default:
+				comment = &reviewComment{diff: diffs[file], oldLine: oldLine, newLine: newLine, lines: []string{l}}
+				comments = append(comments, comment)
+			}
+			continue
+		}
+
+		comment = nil
+		// Editors may strip the space after the ">" of empty context lines
+		l = strings.TrimPrefix(l[1:], " ")
+		switch {
+		case strings.HasPrefix(l, "diff --git "):
+			file++
+			if file >= len(diffs) {
+				return "", nil, errors.New("the quoted diff was changed: more files than in the merge request")
+			}
+			inHunk, oldLine, newLine = false, 0, 0
+		case file < 0:
+			return "", nil, errors.Errorf("the quoted diff was changed: %q is not in a file", l)
+		case strings.HasPrefix(l, "@@"):
+			m := hunkHeaderRe.FindStringSubmatch(l)
+			if m == nil {
+				return "", nil, errors.Errorf("the quoted diff was changed: invalid hunk header %q", l)
+			}
+			oldNext, _ = strconv.Atoi(m[1])
+			newNext, _ = strconv.Atoi(m[2])
+			inHunk, oldLine, newLine = true, 0, 0
+		case !inHunk:
+			// the header of the file diff
+		case strings.HasPrefix(l, "+"):
+			oldLine, newLine = 0, newNext
+			newNext++
+		case strings.HasPrefix(l, "-"):
+			oldLine, newLine = oldNext, 0
+			oldNext++
+		case strings.HasPrefix(l, "\\"):
+			// "\ No newline at end of file" is about the previous line
+		default:
+			oldLine, newLine = oldNext, newNext
+			oldNext++
+			newNext++
+		}
+	}
+	for _, c := range comments {
+		c.lines = trimBlankLines(c.lines)
+	}
+	return strings.Join(trimBlankLines(summary), "\n"), comments, nil
+}
+
+// trimBlankLines removes the blank lines at the start and the end of lines
+func trimBlankLines(lines []string) []string {
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func init() {
+	mrReviewCmd.Flags().Bool("approve", false, "Approve the merge request after posting the comments")
+	mrReviewCmd.Flags().Bool("unapprove", false, "Withdraw your approval after posting the comments")
+	mrReviewCmd.MarkZshCompPositionalArgumentCustom(1, "__lab_completion_remote")
+	mrReviewCmd.MarkZshCompPositionalArgumentCustom(2, "__lab_completion_merge_request $words[2]")
+	mrCmd.AddCommand(mrReviewCmd)
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+func Test_mrReview(t *testing.T) {
+	skipIfLive(t)
+	t.Parallel()
+	repo := copyTestRepo(t)
+
+	// The editor adds a general comment and one on the added line of
+	// README.md
+	editor := filepath.Join(repo, "review.sh")
+	err := ioutil.WriteFile(editor, []byte(`{ echo "a couple of nits"; sed '/^> +- open issues/a\
+should this end with a full stop?' "$1"; } > "$1.tmp" && mv "$1.tmp" "$1"
+`), 0755)
+	require.NoError(t, err)
+
+	cmd := exec.Command(labBinaryPath, "mr", "review", "2", "--approve")
+	cmd.Dir = repo
+	cmd.Env = append(os.Environ(), "GIT_EDITOR=sh "+editor)
+	b, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Log(string(b))
+		t.Fatal(err)
+	}
+	out := getAppOutput(b)
+	require.Len(t, out, 3)
+	require.Regexp(t, `/merge_requests/2#note_\d+$`, out[0])
+	require.Regexp(t, `/merge_requests/2#note_\d+$`, out[1])
+	require.Equal(t, "Merge Request #2 approved", out[2])
+
+	cmd = exec.Command(labBinaryPath, "mr", "show", "2", "--comments")
+	cmd.Dir = repo
+	b, err = cmd.CombinedOutput()
+	if err != nil {
+		t.Log(string(b))
+		t.Fatal(err)
+	}
+	require.Contains(t, string(b), `lab-testing started a discussion on README.md, line 5 (unresolved) at`)
+	require.Contains(t, string(b), "\n    should this end with a full stop?\n")
+	require.Contains(t, string(b), "a couple of nits")
+}
+
+const testReadmeDiff = `@@ -1,4 +1,5 @@
+ Test
+ ==
+ 
+-Repo for testing [lab](https://github.com/zaquestion/lab)
+\ No newline at end of file
++Repo for testing [lab](https://github.com/zaquestion/lab)
++- open issues and merge requests are for supporting integration tests
+\ No newline at end of file
+`
+
+func Test_parseMRReview(t *testing.T) {
+	readme := &gitlab.Diff{OldPath: "README.md", NewPath: "README.md", Diff: testReadmeDiff}
+	ci := &gitlab.Diff{OldPath: ".gitlab-ci.yml", NewPath: ".gitlab-ci.yml", NewFile: true, BMode: "100644", Diff: "@@ -0,0 +1,2 @@\n+image: busybox:latest\n+\n"}
+	diffs := []*gitlab.Diff{ci, readme}
+	// Drop the instructions, as git.EditFile does
+	var lines []string
+	for _, l := range strings.Split(mrReviewText(&gitlab.MergeRequest{IID: 2, Title: "test"}, diffs), "\n") {
+		if !strings.HasPrefix(l, "#") {
+			lines = append(lines, l)
+		}
+	}
+	text := strings.Join(lines, "\n")
+
+	summary, comments, err := parseMRReview(text, diffs)
+	require.NoError(t, err)
+	assert.Equal(t, "", summary)
+	assert.Empty(t, comments)
+
+	text = "\nlooks good\n\nbut\n\n" + text
+	text = insertAfter(t, text, "> +image: busybox:latest\n", "pin the version\nof busybox\n")
+	text = insertAfter(t, text, ">  Test\n", "title?\n")
+	text = insertAfter(t, text, "> -Repo for testing [lab](https://github.com/zaquestion/lab)\n", "removed\n")
+	text = insertAfter(t, text, "> +- open issues and merge requests are for supporting integration tests\n", "added\n")
+
+	summary, comments, err = parseMRReview(text, diffs)
+	require.NoError(t, err)
+	assert.Equal(t, "looks good\n\nbut", summary)
+	require.Len(t, comments, 4)
+	for i, want := range []struct {
+		diff             *gitlab.Diff
+		oldLine, newLine int
+		body             string
+	}{
+		{ci, 0, 1, "pin the version\nof busybox"},
+		{readme, 1, 1, "title?"},
+		{readme, 4, 0, "removed"},
+		{readme, 0, 5, "added"},
+	} {
+		assert.Equal(t, want.diff, comments[i].diff)
+		assert.Equal(t, want.oldLine, comments[i].oldLine, "old line of comment %d", i)
+		assert.Equal(t, want.newLine, comments[i].newLine, "new line of comment %d", i)
+		assert.Equal(t, want.body, comments[i].Body())
+	}
+	assert.Equal(t, "removed line README.md:4", comments[2].Location())
+	assert.Equal(t, "line README.md:5", comments[3].Location())
+
+	text = insertAfter(t, text, "> +++ b/README.md\n", "not on a line\n")
+	_, _, err = parseMRReview(text, diffs)
+	require.Error(t, err)
+}
+
+// insertAfter returns text with s inserted after the line
+func insertAfter(t *testing.T, text, line, s string) string {
+	i := strings.Index(text, line)
+	require.True(t, i >= 0, "%q not found in %q", line, text)
+	i += len(line)
+	return text[:i] + s + text[i:]
+}
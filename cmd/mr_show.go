@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"log"
+	"os"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -22,17 +23,98 @@ var mrShowCmd = &cobra.Command{
 			log.Fatal(err)
 		}
 
-		mr, err := lab.MRGet(rn, int(mrNum))
+		mr, err := labClient.MRGet(ctx, rn, int(mrNum))
 		if err != nil {
 			log.Fatal(err)
 		}
 
+		showComments, _ := cmd.Flags().GetBool("comments")
+		unresolved, _ := cmd.Flags().GetBool("unresolved")
+
+		showComments = showComments || unresolved
+
+		var discussions []*gitlab.Discussion
+		if showComments {
+			discussions, err = labClient.MRListDiscussions(ctx, rn, int(mrNum))
+			if err != nil {
+				log.Fatal(err)
+			}
+			if unresolved {
+				discussions = unresolvedDiscussions(discussions)
+			}
+		}
+
+		if structuredOutput() {
+			var v interface{} = mr
+			if showComments {
+				v = mrWithDiscussions{MergeRequest: mr, Discussions: discussions}
+			}
+			if err := printStructured(os.Stdout, v); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+
 		printMR(mr, rn)
+		if showComments {
+			diffs, err := mrDiffs(rn, int(mrNum), discussions)
+			if err != nil {
+				log.Fatal(err)
+			}
+			printDiscussions(discussions, diffs)
+		}
 	},
 }
 
-func printMR(mr *gitlab.MergeRequest, project string) {
+// mrWithDiscussions is printed by mr show --comments with --output or
+// --format. It is a single {"merge_request": ..., "discussions": [...]}
+// object, while templates can use the fields of the merge request directly
+// along with .Discussions.
+type mrWithDiscussions struct {
+	*lab.MergeRequest `json:"merge_request"`
+	Discussions       []*gitlab.Discussion `json:"discussions"`
+}
+
+// unresolvedDiscussions returns the discussions which can be resolved and
+// haven't been yet
+func unresolvedDiscussions(discussions []*gitlab.Discussion) []*gitlab.Discussion {
+	var unresolved []*gitlab.Discussion
+	for _, d := range discussions {
+		if resolvable, resolved := discussionResolved(d); resolvable && !resolved {
+			unresolved = append(unresolved, d)
+		}
+	}
+	return unresolved
+}
+
+// mrDiffs returns the diffs of the files of a merge request, keyed by their
+// new path, when any of the discussions were started on the diff
+func mrDiffs(project string, mrNum int, discussions []*gitlab.Discussion) (map[string]string, error) {
+	onDiff := false
+	for _, d := range discussions {
+		if len(d.Notes) > 0 && d.Notes[0].Position != nil {
+			onDiff = true
+			break
+		}
+	}
+	if !onDiff {
+		return nil, nil
+	}
+
+	mr, err := labClient.MRChanges(ctx, project, mrNum)
+	if err != nil {
+		return nil, err
+	}
+	diffs := make(map[string]string, len(mr.Changes))
+	for _, c := range mr.Changes {
+		diffs[c.NewPath] = c.Diff
+	}
+	return diffs, nil
+}
+
+func printMR(mr *lab.MergeRequest, project string) {
 	assignee := "None"
+	reviewers := "None"
 	milestone := "None"
 	labels := "None"
 	state := map[string]string{
@@ -40,9 +122,14 @@ func printMR(mr *gitlab.MergeRequest, project string) {
 		"closed": "Closed",
 		"merged": "Merged",
 	}[mr.State]
-	if mr.Assignee.Username != "" {
+	if len(mr.Assignees) > 0 {
+		assignee = strings.Join(usernames(mr.Assignees), ", ")
+	} else if mr.Assignee.Username != "" {
 		assignee = mr.Assignee.Username
 	}
+	if len(mr.Reviewers) > 0 {
+		reviewers = strings.Join(usernames(mr.Reviewers), ", ")
+	}
 	if mr.Milestone != nil {
 		milestone = mr.Milestone.Title
 	}
@@ -59,17 +146,30 @@ Project: %s
 Branches: %s->%s
 Status: %s
 Assignee: %s
+Reviewers: %s
 Author: %s
 Milestone: %s
 Labels: %s
 WebURL: %s
 `,
 		mr.IID, mr.Title, mr.Description, project, mr.SourceBranch,
-		mr.TargetBranch, state, assignee,
+		mr.TargetBranch, state, assignee, reviewers,
 		mr.Author.Username, milestone, labels, mr.WebURL)
 }
 
+// usernames returns the usernames of users
+func usernames(users []*gitlab.IssueAssignee) []string {
+	names := make([]string, len(users))
+	for i, u := range users {
+		names[i] = u.Username
+	}
+	return names
+}
+
 func init() {
+	addFormatFlag(mrShowCmd)
+	mrShowCmd.Flags().BoolP("comments", "c", false, "Show comments for the merge request")
+	mrShowCmd.Flags().Bool("unresolved", false, "Show only the unresolved threads, implies --comments")
 	mrShowCmd.MarkZshCompPositionalArgumentCustom(1, "__lab_completion_remote")
 	mrShowCmd.MarkZshCompPositionalArgumentCustom(2, "__lab_completion_merge_request $words[2]")
 	mrCmd.AddCommand(mrShowCmd)
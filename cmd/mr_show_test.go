@@ -1,10 +1,14 @@
 package cmd
 
 import (
+	"encoding/json"
 	"os/exec"
+	"strings"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	gitlab "github.com/xanzy/go-gitlab"
 )
 
 func Test_mrShow(t *testing.T) {
@@ -28,9 +32,85 @@ Project: zaquestion/test
 Branches: mrtest->master
 Status: Open
 Assignee: zaquestion
+Reviewers: None
 Author: zaquestion
 Milestone: 1.0
 Labels: documentation
 WebURL: https://gitlab.com/zaquestion/test/merge_requests/1
 `)
 }
+
+func Test_mrShowComments(t *testing.T) {
+	skipIfLive(t)
+	t.Parallel()
+	repo := copyTestRepo(t)
+	cmd := exec.Command(labBinaryPath, "mr", "show", "2", "--comments")
+	cmd.Dir = repo
+
+	b, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Log(string(b))
+		t.Fatal(err)
+	}
+	out := string(b)
+	require.Contains(t, out, "lab-testing commented at")
+	require.Contains(t, out, "a comment on the merge request")
+	require.Contains(t, out, "lab-testing started a discussion on README.md, line 5 (unresolved) at")
+	require.Contains(t, out, `
+    @@ -1,4 +1,5 @@
+     
+    -Repo for testing [lab](https://github.com/zaquestion/lab)
+    +Repo for testing [lab](https://github.com/zaquestion/lab)
+    +- open issues and merge requests are for supporting integration tests
+
+    which tests?
+`)
+	require.Contains(t, out, "the ones in the lab repo")
+	require.Contains(t, out, "lab-testing started a discussion on README.md, old line 4 (resolved by zaquestion) at")
+}
+
+func Test_mrShowUnresolved(t *testing.T) {
+	skipIfLive(t)
+	t.Parallel()
+	repo := copyTestRepo(t)
+	cmd := exec.Command(labBinaryPath, "mr", "show", "2", "--unresolved")
+	cmd.Dir = repo
+
+	b, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Log(string(b))
+		t.Fatal(err)
+	}
+	out := string(b)
+	require.Contains(t, out, "which tests?")
+	require.NotContains(t, out, "a comment on the merge request")
+	require.NotContains(t, out, "missing newline")
+}
+
+func Test_mrShowCommentsOutput(t *testing.T) {
+	skipIfLive(t)
+	t.Parallel()
+	repo := copyTestRepo(t)
+	cmd := exec.Command(labBinaryPath, "mr", "show", "2", "--comments", "-o", "json")
+	cmd.Dir = repo
+
+	b, err := cmd.Output()
+	require.NoError(t, err)
+	// A single document holds the merge request and its discussions
+	var out struct {
+		MergeRequest *gitlab.MergeRequest `json:"merge_request"`
+		Discussions  []*gitlab.Discussion `json:"discussions"`
+	}
+	dec := json.NewDecoder(strings.NewReader(strings.Join(getAppOutput(b), "\n")))
+	require.NoError(t, dec.Decode(&out))
+	assert.False(t, dec.More(), string(b))
+	assert.Equal(t, 2, out.MergeRequest.IID)
+	assert.NotEmpty(t, out.Discussions)
+
+	cmd = exec.Command(labBinaryPath, "mr", "show", "2", "--unresolved",
+		"--format", "!{{.IID}}{{range .Discussions}} {{(index .Notes 0).Body}}{{end}}")
+	cmd.Dir = repo
+	b, err = cmd.Output()
+	require.NoError(t, err)
+	assert.Regexp(t, `^!2 .`, getAppOutput(b)[0])
+}
@@ -5,7 +5,6 @@ import (
 	"log"
 
 	"github.com/spf13/cobra"
-	lab "github.com/zaquestion/lab/internal/gitlab"
 )
 
 var mrThumbCmd = &cobra.Command{
@@ -26,12 +25,12 @@ var mrThumbUpCmd = &cobra.Command{
 			log.Fatal(err)
 		}
 
-		p, err := lab.FindProject(rn)
+		p, err := labClient.FindProject(ctx, rn)
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		err = lab.MRThumbUp(p.ID, int(id))
+		err = labClient.MRThumbUp(ctx, p.ID, int(id))
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -50,12 +49,12 @@ var mrThumbDownCmd = &cobra.Command{
 			log.Fatal(err)
 		}
 
-		p, err := lab.FindProject(rn)
+		p, err := labClient.FindProject(ctx, rn)
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		err = lab.MRThumbDown(p.ID, int(id))
+		err = labClient.MRThumbDown(ctx, p.ID, int(id))
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	lab "github.com/zaquestion/lab/internal/gitlab"
+	yaml "gopkg.in/yaml.v2"
+)
+
+var (
+	// outputFormat is set by the global --output flag. When empty,
+	// commands print their usual human readable text.
+	outputFormat string
+	// outputTemplate is set by the --format flag on the commands which
+	// support it
+	outputTemplate string
+)
+
+// outputFormats lists the values accepted by --output
+var outputFormats = []string{"json", "yaml", "ndjson"}
+
+const outputUsage = `Print the raw GitLab objects as json, yaml or ndjson.
+The objects use the field names of the GitLab v4 API`
+
+// validateOutputFormat returns an error if format is not a known output
+// format. The empty string is valid and selects the default text output.
+func validateOutputFormat(format string) error {
+	if format == "" {
+		return nil
+	}
+	for _, f := range outputFormats {
+		if f == format {
+			return nil
+		}
+	}
+	return errors.Errorf("unknown output format %q, must be one of: json, yaml, ndjson", format)
+}
+
+// structuredOutput returns true when the user asked for machine readable or
+// templated output instead of the default text
+func structuredOutput() bool {
+	return outputFormat != "" || outputTemplate != ""
+}
+
+const formatUsage = `Pretty-print each object using a Go template, such as '{{.IID}}\t{{.Title}}'.
+Use @name to load the template saved as format.name in lab.hcl`
+
+// addFormatFlag adds the --format flag to cmd
+func addFormatFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&outputTemplate, "format", "", formatUsage)
+}
+
+// namedFormat looks up a template saved in the "format" block of lab.hcl:
+//
+//	"format" = {
+//	  "mine" = "{{.IID}} {{.Title}}"
+//	}
+func namedFormat(name string) (string, error) {
+	var formats map[string]interface{}
+	switch v := viper.Get("format").(type) {
+	case []map[string]interface{}:
+		if len(v) > 0 {
+			formats = v[0]
+		}
+	case map[string]interface{}:
+		formats = v
+	}
+	f, ok := formats[name].(string)
+	if !ok {
+		return "", errors.Errorf("format %q not found in %s", name, viper.ConfigFileUsed())
+	}
+	return f, nil
+}
+
+// parseOutputTemplate returns the template given with --format, resolving
+// named formats and the \t and \n escapes which are hard to type in a shell
+func parseOutputTemplate(format string) (*template.Template, error) {
+	if strings.HasPrefix(format, "@") {
+		var err error
+		format, err = namedFormat(format[1:])
+		if err != nil {
+			return nil, err
+		}
+	}
+	format = strings.NewReplacer(`\t`, "\t", `\n`, "\n").Replace(format)
+	return template.New("format").Funcs(templateFuncs).Parse(format)
+}
+
+// printTemplate executes tmpl for v, or for each element when v is a slice,
+// following each with a newline
+func printTemplate(w io.Writer, tmpl *template.Template, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		if err := tmpl.Execute(w, v); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintln(w)
+		return err
+	}
+	for i := 0; i < rv.Len(); i++ {
+		if err := tmpl.Execute(w, rv.Index(i).Interface()); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printStructured writes v to w in the format selected with --output or
+// --format. Slices are written as a single json or yaml list, or as one json
+// object or template per line for ndjson and --format.
+func printStructured(w io.Writer, v interface{}) error {
+	if outputTemplate != "" {
+		if outputFormat != "" {
+			return errors.New("--format and --output cannot be used together")
+		}
+		tmpl, err := parseOutputTemplate(outputTemplate)
+		if err != nil {
+			return err
+		}
+		return printTemplate(w, tmpl, v)
+	}
+
+	switch outputFormat {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case "ndjson":
+		enc := json.NewEncoder(w)
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice {
+			return enc.Encode(v)
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if err := enc.Encode(rv.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "yaml":
+		// Round trip through json so the yaml keys match the json
+		// field names used by the GitLab API rather than the go
+		// struct field names
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		var obj interface{}
+		if err := yaml.Unmarshal(b, &obj); err != nil {
+			return err
+		}
+		out, err := yaml.Marshal(obj)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(out)
+		return err
+	}
+	return validateOutputFormat(outputFormat)
+}
+
+// printList prints the items of it as their pages arrive, using printText for
+// the default text output. json and yaml are printed as a single list once
+// all the items have arrived.
+func printList(w io.Writer, it *lab.Iterator, printText func(v interface{})) error {
+	defer it.Close()
+	if outputFormat == "json" || outputFormat == "yaml" {
+		items := []interface{}{}
+		for it.Next() {
+			items = append(items, it.Value())
+		}
+		if err := it.Err(); err != nil {
+			return err
+		}
+		return printStructured(w, items)
+	}
+	for it.Next() {
+		if !structuredOutput() {
+			printText(it.Value())
+			continue
+		}
+		if err := printStructured(w, it.Value()); err != nil {
+			return err
+		}
+	}
+	return it.Err()
+}
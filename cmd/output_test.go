@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+func Test_printStructured(t *testing.T) {
+	labels := []*gitlab.Label{
+		{ID: 1, Name: "bug", Color: "#d9534f"},
+		{ID: 2, Name: "enhancement", Color: "#5cb85c"},
+	}
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{
+			format: "ndjson",
+			expected: `{"id":1,"name":"bug","color":"#d9534f","description":"","open_issues_count":0,"closed_issues_count":0,"open_merge_requests_count":0,"subscribed":false,"priority":0}
+{"id":2,"name":"enhancement","color":"#5cb85c","description":"","open_issues_count":0,"closed_issues_count":0,"open_merge_requests_count":0,"subscribed":false,"priority":0}
+`,
+		},
+		{
+			format: "yaml",
+			expected: `- closed_issues_count: 0
+  color: '#d9534f'
+  description: ""
+  id: 1
+  name: bug
+  open_issues_count: 0
+  open_merge_requests_count: 0
+  priority: 0
+  subscribed: false
+- closed_issues_count: 0
+  color: '#5cb85c'
+  description: ""
+  id: 2
+  name: enhancement
+  open_issues_count: 0
+  open_merge_requests_count: 0
+  priority: 0
+  subscribed: false
+`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			outputFormat = test.format
+			defer func() { outputFormat = "" }()
+
+			var buf bytes.Buffer
+			err := printStructured(&buf, labels)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, buf.String())
+		})
+	}
+}
+
+func Test_validateOutputFormat(t *testing.T) {
+	assert.NoError(t, validateOutputFormat(""))
+	assert.NoError(t, validateOutputFormat("json"))
+	assert.Error(t, validateOutputFormat("xml"))
+}
+
+func Test_printStructuredFormat(t *testing.T) {
+	issues := []*gitlab.Issue{
+		{IID: 1, Title: "test issue for lab list", Labels: []string{"bug", "critical"}},
+		{IID: 2, Title: "a very long title which will not fit", Labels: []string{}},
+	}
+	outputTemplate = `#{{.IID}}\t{{.Title | truncate 20}}\t{{.Labels | join ","}}`
+	defer func() { outputTemplate = "" }()
+
+	var buf bytes.Buffer
+	err := printStructured(&buf, issues)
+	require.NoError(t, err)
+	assert.Equal(t, "#1\ttest issue for la...\tbug,critical\n#2\ta very long title...\t\n", buf.String())
+}
+
+func Test_printStructuredNamedFormat(t *testing.T) {
+	viper.Set("format", map[string]interface{}{
+		"mine": "{{.IID}} {{color \"red\" .Title}}",
+	})
+	defer viper.Set("format", nil)
+	outputTemplate = "@mine"
+	defer func() { outputTemplate = "" }()
+
+	var buf bytes.Buffer
+	err := printStructured(&buf, &gitlab.Issue{IID: 3, Title: "test"})
+	require.NoError(t, err)
+	assert.Equal(t, "3 \x1b[31mtest\x1b[0m\n", buf.String())
+
+	outputTemplate = "@missing"
+	err = printStructured(&buf, &gitlab.Issue{IID: 3, Title: "test"})
+	assert.Error(t, err)
+}
+
+func Test_ago(t *testing.T) {
+	now := time.Now()
+	tests := map[string]interface{}{
+		"just now":     now,
+		"1 minute ago": now.Add(-90 * time.Second),
+		"3 hours ago":  now.Add(-3 * time.Hour),
+		"2 days ago":   now.Add(-49 * time.Hour),
+		"":             (*time.Time)(nil),
+	}
+	for expected, in := range tests {
+		assert.Equal(t, expected, ago(in))
+	}
+}
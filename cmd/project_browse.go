@@ -4,7 +4,6 @@ import (
 	"log"
 
 	"github.com/spf13/cobra"
-	lab "github.com/zaquestion/lab/internal/gitlab"
 )
 
 var projectBrowseCmd = &cobra.Command{
@@ -15,7 +14,7 @@ var projectBrowseCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		rn, _, err := parseArgs(args)
 
-		p, err := lab.FindProject(rn)
+		p, err := labClient.FindProject(ctx, rn)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -8,7 +8,6 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/xanzy/go-gitlab"
 	"github.com/zaquestion/lab/internal/git"
-	lab "github.com/zaquestion/lab/internal/gitlab"
 )
 
 // private and public are defined in snippet_create.go
@@ -62,7 +61,7 @@ lab project create -n "new proj"           # user/new-proj named "new proj"`,
 			Visibility:           &visibility,
 			ApprovalsBeforeMerge: gitlab.Int(0),
 		}
-		p, err := lab.ProjectCreate(&opts)
+		p, err := labClient.ProjectCreate(ctx, &opts)
 		if err != nil {
 			log.Fatal(err)
 		}
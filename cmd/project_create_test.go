@@ -9,10 +9,10 @@ import (
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 	"github.com/zaquestion/lab/internal/git"
-	lab "github.com/zaquestion/lab/internal/gitlab"
 )
 
 func Test_projectCreateCmd(t *testing.T) {
+	skipUnlessLive(t)
 	t.Parallel()
 	repo := copyTestRepo(t)
 	expectedPath := filepath.Base(repo)
@@ -43,11 +43,11 @@ func Test_projectCreateCmd(t *testing.T) {
 		require.Equal(t, "git@gitlab.com:lab-testing/"+expectedPath+".git\n", string(remote))
 	})
 
-	p, err := lab.FindProject(expectedPath)
+	p, err := labClient.FindProject(ctx, expectedPath)
 	if err != nil {
 		t.Fatal(errors.Wrap(err, "failed to find project for cleanup"))
 	}
-	err = lab.ProjectDelete(p.ID)
+	err = labClient.ProjectDelete(ctx, p.ID)
 	if err != nil {
 		t.Fatal(errors.Wrap(err, "failed to delete project during cleanup"))
 	}
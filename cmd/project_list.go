@@ -3,10 +3,10 @@ package cmd
 import (
 	"fmt"
 	"log"
+	"os"
 
 	"github.com/spf13/cobra"
 	"github.com/xanzy/go-gitlab"
-	lab "github.com/zaquestion/lab/internal/gitlab"
 )
 
 var projectListConfig struct {
@@ -43,13 +43,13 @@ var projectListCmd = &cobra.Command{
 		if projectListConfig.All {
 			num = -1
 		}
-		projects, err := lab.ProjectList(opt, num)
+		it := labClient.ProjectListIter(ctx, opt, num)
+		err = printList(os.Stdout, it, func(v interface{}) {
+			fmt.Println(v.(*gitlab.Project).PathWithNamespace)
+		})
 		if err != nil {
 			log.Fatal(err)
 		}
-		for _, p := range projects {
-			fmt.Println(p.PathWithNamespace)
-		}
 	},
 }
 
@@ -60,6 +60,7 @@ func init() {
 	projectListCmd.Flags().BoolVar(&projectListConfig.Membership, "member", false, "limit by projects which you are a member")
 	projectListCmd.Flags().BoolVar(&projectListConfig.Starred, "starred", false, "limit by your starred projects")
 	projectListCmd.Flags().IntVarP(&projectListConfig.Number, "number", "n", 100, "Number of projects to return")
+	addFormatFlag(projectListCmd)
 
 	projectListCmd.MarkZshCompPositionalArgumentCustom(1, "()")
 }
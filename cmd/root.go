@@ -2,14 +2,18 @@ package cmd
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
 	"os/exec"
+	"os/signal"
 	"strconv"
 	"strings"
 	"syscall"
 	"text/template"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -24,6 +28,12 @@ var RootCmd = &cobra.Command{
 	Short:                 "A Git Wrapper for GitLab",
 	Long:                  ``,
 	ZshCompletionFunction: zshCompletionFunction,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if err := validateOutputFormat(outputFormat); err != nil {
+			log.Fatal(err)
+		}
+		setupContext()
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		if ok, err := cmd.Flags().GetBool("version"); err == nil && ok {
 			versionCmd.Run(cmd, args)
@@ -38,8 +48,91 @@ func rpad(s string, padding int) string {
 	return fmt.Sprintf(template, s)
 }
 
+// ago returns a human readable representation of how long ago t was, such as
+// "3 days ago". It accepts both time.Time and *time.Time as most GitLab
+// objects store their timestamps as pointers.
+func ago(t interface{}) string {
+	var tm time.Time
+	switch v := t.(type) {
+	case time.Time:
+		tm = v
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		tm = *v
+	default:
+		return ""
+	}
+
+	d := time.Since(tm)
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("1 %s ago", unit)
+		}
+		return fmt.Sprintf("%d %ss ago", n, unit)
+	}
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return plural(int(d.Minutes()), "minute")
+	case d < 24*time.Hour:
+		return plural(int(d.Hours()), "hour")
+	case d < 30*24*time.Hour:
+		return plural(int(d.Hours()/24), "day")
+	case d < 365*24*time.Hour:
+		return plural(int(d.Hours()/24/30), "month")
+	}
+	return plural(int(d.Hours()/24/365), "year")
+}
+
+// join concatenates elems with sep. The separator comes first so join can be
+// used in a pipeline: {{.Labels | join ", "}}
+func join(sep string, elems []string) string {
+	return strings.Join(elems, sep)
+}
+
+var ansiColors = map[string]string{
+	"black":   "30",
+	"red":     "31",
+	"green":   "32",
+	"yellow":  "33",
+	"blue":    "34",
+	"magenta": "35",
+	"cyan":    "36",
+	"white":   "37",
+	"bold":    "1",
+}
+
+// color wraps s in the ANSI escape codes for the named color. Unknown colors
+// leave s unchanged.
+func color(name, s string) string {
+	code, ok := ansiColors[name]
+	if !ok {
+		return s
+	}
+	return "\x1b[" + code + "m" + s + "\x1b[0m"
+}
+
+// truncate shortens s to at most n characters, marking the cut with "..."
+func truncate(n int, s string) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	if n <= 3 {
+		return string(r[:n])
+	}
+	return string(r[:n-3]) + "..."
+}
+
 var templateFuncs = template.FuncMap{
-	"rpad": rpad,
+	"rpad":     rpad,
+	"ago":      ago,
+	"join":     join,
+	"color":    color,
+	"truncate": truncate,
 }
 
 const labUsageTmpl = `{{range .Commands}}{{if (and (or .IsAvailableCommand (ne .Name "help")) (and (ne .Name "clone") (ne .Name "version") (ne .Name "merge-request")))}}
@@ -106,6 +199,9 @@ func init() {
 	RootCmd.SetHelpCommand(helpCmd)
 	RootCmd.SetHelpFunc(helpFunc)
 	RootCmd.Flags().Bool("version", false, "Show the lab version")
+	RootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "", outputUsage)
+	RootCmd.MarkPFlagCustom("output", "(json yaml ndjson)")
+	RootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 0, "Give up on GitLab requests after the given duration, such as 30s or 5m (default no timeout)")
 }
 
 // TODO: this parseArgs thing has gotten way THE FUCK out of hand. Please fix.
@@ -164,7 +260,7 @@ func parseArgsRemoteInt(args []string) (string, int64, error) {
 	if err != nil || remote == "" {
 		remote = forkedFromRemote
 	}
-	rn, err := git.PathWithNameSpace(remote)
+	rn, err := projectForRemote(remote)
 	if err != nil {
 		return "", 0, err
 	}
@@ -205,40 +301,126 @@ func parseArgsRemoteString(args []string) (string, string, error) {
 		return "", "", errors.Errorf("%s is not a valid remote", remote)
 	}
 
-	remote, err = git.PathWithNameSpace(remote)
+	remote, err = projectForRemote(remote)
 	if err != nil {
 		return "", "", err
 	}
 	return remote, str, nil
 }
 
+var (
+	// ctx is passed to every API request made by a command. It is canceled
+	// on interrupt and once --timeout has elapsed.
+	ctx = context.Background()
+	// timeout is the value of --timeout, zero meaning no timeout
+	timeout time.Duration
+)
+
+// setupContext replaces ctx with one which is canceled by the first
+// interrupt, so that in-flight requests are abandoned cleanly. A second
+// interrupt kills lab as usual.
+func setupContext() {
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		<-sig
+		signal.Stop(sig)
+		cancel()
+	}()
+}
+
 var (
 	// Will be updated to upstream in Execute() if "upstream" remote exists
 	forkedFromRemote = "origin"
-	// Will be updated to lab.User() in Execute() if forkedFrom is "origin"
+	// Will be updated to labClient.User in Execute() if forkedFrom is "origin"
 	forkRemote = "origin"
+	// labClients holds a client for each configured GitLab host
+	labClients []*lab.Client
+	// labClient is the GitLab client used by all commands. It starts as the
+	// default client and is switched by useRemote() to the client for the
+	// host of the remote a command operates on.
+	labClient *lab.Client
 )
 
+// useRemote selects the client configured for the GitLab host of remote. The
+// default client is kept when the host of the remote is not configured.
+func useRemote(remote string) error {
+	host, err := git.RemoteHost(remote)
+	if err != nil {
+		return err
+	}
+	for _, c := range labClients {
+		u, err := url.Parse(c.Host())
+		if err != nil {
+			return err
+		}
+		if u.Hostname() == host {
+			labClient = c
+			return nil
+		}
+	}
+	return nil
+}
+
+// projectForRemote returns the project path for remote, such as
+// zaquestion/lab, and selects the client for the host of remote
+func projectForRemote(remote string) (string, error) {
+	if err := useRemote(remote); err != nil {
+		return "", err
+	}
+	return git.PathWithNameSpace(remote)
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
-// This is called by main.main(). It only needs to happen once to the rootCmd.
-func Execute() {
+// This is called by main.main() with a client for each configured host, the
+// first being the default. It only needs to happen once to the rootCmd.
+func Execute(clients []*lab.Client) {
+	labClients = clients
+	labClient = clients[0]
+
 	_, err := gitconfig.Local("remote.upstream.url")
 	if err == nil {
 		forkedFromRemote = "upstream"
-	}
-	if forkedFromRemote == "" {
-		// use the remote tracked by the branch if set
-		masterRemote, err := gitconfig.Local("branch.master.remote")
-		if err == nil {
-			forkedFromRemote = masterRemote
+	} else {
+		// use the remote tracked by the default branch if set. The
+		// project isn't known yet, so look for the branch named by
+		// lab.targetbranch, the default branch of origin as recorded
+		// by clone, and then the usual names of default branches.
+		var branches []string
+		if branch := targetBranchConfig(); branch != "" {
+			branches = append(branches, branch)
+		}
+		if branch, err := git.RemoteDefaultBranch("origin"); err == nil {
+			branches = append(branches, branch)
+		}
+		branches = append(branches, "main", "master")
+		for _, branch := range branches {
+			if remote, err := gitconfig.Local("branch." + branch + ".remote"); err == nil && remote != "." {
+				forkedFromRemote = remote
+				break
+			}
 		}
 	}
 
 	if forkedFromRemote == "origin" {
+		// The fork lives on the same host as the project it was forked
+		// from, so look the user up there
+		useRemote(forkedFromRemote)
 		// Check if the user fork exists
-		_, err = gitconfig.Local("remote." + lab.User() + ".url")
+		user, err := labClient.User(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		_, err = gitconfig.Local("remote." + user + ".url")
 		if err == nil {
-			forkRemote = lab.User()
+			forkRemote = user
 		}
 	}
 	// Check if the user is calling a lab command or if we should passthrough
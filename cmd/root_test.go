@@ -4,11 +4,13 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"math/rand"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"testing"
@@ -20,6 +22,7 @@ import (
 	gitlab "github.com/xanzy/go-gitlab"
 	"github.com/zaquestion/lab/internal/copy"
 	lab "github.com/zaquestion/lab/internal/gitlab"
+	"github.com/zaquestion/lab/internal/gitlabtest"
 )
 
 var labBinaryPath string
@@ -50,29 +53,57 @@ func TestMain(m *testing.M) {
 	if err := os.Chdir(repo); err != nil {
 		log.Fatalf("Error chdir to testdata: %s", err)
 	}
-	// Load config for non-testbinary based tests
-	viper.SetConfigName("lab")
-	viper.SetConfigType("hcl")
-	viper.AddConfigPath(".")
-	err = viper.ReadInConfig()
+	// Keep the responses cached by the lab binaries out of the user's
+	// cache
+	cacheDir, err := ioutil.TempDir("", "lab-cache")
 	if err != nil {
 		log.Fatal(err)
 	}
-	c := viper.AllSettings()["core"]
-	config := c.([]map[string]interface{})[0]
-	client := gitlab.NewClient(nil, config["token"].(string))
-	client.SetBaseURL(config["host"].(string) + "/api/v4")
+	os.Setenv("XDG_CACHE_HOME", cacheDir)
+	// Run against the fake GitLab unless asked to use the live
+	// gitlab.com test projects configured in testdata/lab.hcl
+	var srv *gitlabtest.Server
+	host, token := "", gitlabtest.Token
+	if os.Getenv("LAB_TEST_LIVE") == "" {
+		srv = gitlabtest.NewServer()
+		srv.WebURL = "https://gitlab.com"
+		gitlabtest.Seed(srv)
+		host = srv.URL
+		// The environment takes precedence over lab.hcl, so the lab
+		// binaries run by the tests use the fake too
+		os.Setenv("LAB_CORE_HOST", host)
+		os.Setenv("LAB_CORE_TOKEN", token)
+	} else {
+		// Load config for non-testbinary based tests
+		viper.SetConfigName("lab")
+		viper.SetConfigType("hcl")
+		viper.AddConfigPath(".")
+		err = viper.ReadInConfig()
+		if err != nil {
+			log.Fatal(err)
+		}
+		c := viper.AllSettings()["core"]
+		config := c.([]map[string]interface{})[0]
+		host, token = config["host"].(string), config["token"].(string)
+	}
+	client := gitlab.NewClient(nil, token)
+	client.SetBaseURL(host + "/api/v4")
 	u, _, err := client.Users.CurrentUser()
 	if err != nil {
 		log.Fatal(err)
 	}
-	lab.Init(
-		config["host"].(string),
-		u.Username,
-		config["token"].(string))
+	labClient = lab.NewClient(lab.Config{
+		Host:  host,
+		User:  u.Username,
+		Token: token,
+	})
 
 	code := m.Run()
 
+	if srv != nil {
+		srv.Close()
+	}
+	os.RemoveAll(cacheDir)
 	if err := os.Chdir(originalWd); err != nil {
 		log.Fatalf("Error chdir to original working dir: %s", err)
 	}
@@ -91,6 +122,45 @@ func TestMain(m *testing.M) {
 	os.Exit(code)
 }
 
+// skipUnlessLive skips tests which need git to talk to the GitLab remotes of
+// the testdata repo, which the fake GitLab API can't serve
+func skipUnlessLive(t *testing.T) {
+	t.Helper()
+	if os.Getenv("LAB_TEST_LIVE") == "" {
+		t.Skip("needs gitlab.com, set LAB_TEST_LIVE=1 to run")
+	}
+}
+
+// skipIfLive skips tests which rely on data seeded in the fake GitLab API,
+// which the live test projects don't have
+func skipIfLive(t *testing.T) {
+	t.Helper()
+	if os.Getenv("LAB_TEST_LIVE") != "" {
+		t.Skip("needs the fake GitLab API, unset LAB_TEST_LIVE to run")
+	}
+}
+
+// runLab runs lab with args in dir and returns its output, failing the test
+// when lab exits with an error
+func runLab(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := runLabErr(dir, args...)
+	if err != nil {
+		t.Log(out)
+		t.Fatal(err)
+	}
+	return out
+}
+
+// runLabErr runs lab with args in dir and returns its output and how it
+// exited, for the tests that expect lab to fail
+func runLabErr(dir string, args ...string) (string, error) {
+	cmd := exec.Command(labBinaryPath, args...)
+	cmd.Dir = dir
+	b, err := cmd.CombinedOutput()
+	return string(b), err
+}
+
 func TestRootCloneNoArg(t *testing.T) {
 	cmd := exec.Command(labBinaryPath, "clone")
 	b, _ := cmd.CombinedOutput()
@@ -107,12 +177,18 @@ Date:   Sun Apr 1 19:40:47 2018 -0700
     (ci) jobs with interleaved sleeps and prints`)
 }
 
+// gitUsage matches the start of git's usage, which newer versions of git
+// write with the short options too
+var gitUsage = regexp.MustCompile(`usage: git \[(-v \| )?--version\] \[(-h \| )?--help\] \[-C <path>\]`)
+
 func TestRootNoArg(t *testing.T) {
 	cmd := exec.Command(labBinaryPath)
 	b, _ := cmd.CombinedOutput()
-	assert.Contains(t, string(b), "usage: git [--version] [--help] [-C <path>]")
+	assert.Regexp(t, gitUsage, string(b))
 	assert.Contains(t, string(b), `These GitLab commands are provided by lab:
 
+  api           Make an authenticated request to the GitLab API
+  cache         Manage the cache of GitLab projects and users
   ci            Work with GitLab CI pipelines and jobs`)
 }
 
@@ -176,9 +252,11 @@ func TestGitHelp(t *testing.T) {
 			t.Log(expected)
 			t.Log(res)
 			assert.Equal(t, expected, res)
-			assert.Contains(t, res, "usage: git [--version] [--help] [-C <path>]")
+			assert.Regexp(t, gitUsage, res)
 			assert.Contains(t, res, `These GitLab commands are provided by lab:
 
+  api           Make an authenticated request to the GitLab API
+  cache         Manage the cache of GitLab projects and users
   ci            Work with GitLab CI pipelines and jobs`)
 		})
 	}
@@ -342,6 +420,55 @@ func Test_parseArgs(t *testing.T) {
 	}
 }
 
+func Test_useRemote(t *testing.T) {
+	oldClient, oldClients := labClient, labClients
+	defer func() {
+		labClient, labClients = oldClient, oldClients
+	}()
+	gitlabCom := lab.NewClient(lab.Config{Host: "https://gitlab.com", User: "zaquestion"})
+	selfHosted := lab.NewClient(lab.Config{Host: "https://git.mydomain.net/", User: "zaq"})
+	labClients = []*lab.Client{gitlabCom, selfHosted}
+
+	labClient = gitlabCom
+	rn, err := projectForRemote("origin-custom-port")
+	require.NoError(t, err)
+	assert.Equal(t, "zaquestion/test", rn)
+	assert.Equal(t, selfHosted, labClient)
+
+	rn, err = projectForRemote("origin")
+	require.NoError(t, err)
+	assert.Equal(t, "zaquestion/test", rn)
+	assert.Equal(t, gitlabCom, labClient)
+}
+
+func TestRootDefaultBranchRemote(t *testing.T) {
+	skipIfLive(t)
+	t.Parallel()
+	repo := copyTestRepo(t)
+	// origin defaults to develop, which tracks the lab-testing remote
+	for _, args := range [][]string{
+		{"branch", "develop"},
+		{"config", "branch.develop.remote", "lab-testing"},
+		{"symbolic-ref", "refs/remotes/origin/HEAD", "refs/remotes/origin/develop"},
+	} {
+		git := exec.Command("git", args...)
+		git.Dir = repo
+		if b, err := git.CombinedOutput(); err != nil {
+			t.Log(string(b))
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command(labBinaryPath, "api", "get", "projects/:id")
+	cmd.Dir = repo
+	b, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Log(string(b))
+		t.Fatal(err)
+	}
+	assert.Contains(t, string(b), `"path_with_namespace": "lab-testing/test"`)
+}
+
 func Test_parseArgsRemoteString(t *testing.T) {
 	tests := []struct {
 		Name           string
@@ -7,7 +7,6 @@ import (
 	"strconv"
 
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 var snippetBrowseCmd = &cobra.Command{
@@ -20,10 +19,7 @@ var snippetBrowseCmd = &cobra.Command{
 			log.Fatal(err)
 		}
 
-		c := viper.AllSettings()["core"]
-		config := c.([]map[string]interface{})[0]
-		host := config["host"].(string)
-		hostURL, err := url.Parse(host)
+		hostURL, err := url.Parse(labClient.Host())
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -15,7 +15,6 @@ import (
 	"github.com/spf13/cobra"
 	gitlab "github.com/xanzy/go-gitlab"
 	"github.com/zaquestion/lab/internal/git"
-	lab "github.com/zaquestion/lab/internal/gitlab"
 )
 
 var (
@@ -73,7 +72,7 @@ Optionally add a title & description with -m`,
 		}
 		// See if we're in a git repo or if global is set to determine
 		// if this should be a personal snippet
-		rn, _ := git.PathWithNameSpace(remote)
+		rn, _ := projectForRemote(remote)
 		if global || rn == "" {
 			opts := gitlab.CreateSnippetOptions{
 				Title:       gitlab.String(title),
@@ -82,7 +81,7 @@ Optionally add a title & description with -m`,
 				FileName:    gitlab.String(name),
 				Visibility:  &visibility,
 			}
-			snip, err := lab.SnippetCreate(&opts)
+			snip, err := labClient.SnippetCreate(ctx, &opts)
 			if err != nil || snip == nil {
 				log.Fatal(errors.Wrap(err, "failed to create snippet"))
 			}
@@ -90,7 +89,7 @@ Optionally add a title & description with -m`,
 			return
 		}
 
-		project, err := lab.FindProject(rn)
+		project, err := labClient.FindProject(ctx, rn)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -101,7 +100,7 @@ Optionally add a title & description with -m`,
 			FileName:    gitlab.String(name),
 			Visibility:  &visibility,
 		}
-		snip, err := lab.ProjectSnippetCreate(project.ID, &opts)
+		snip, err := labClient.ProjectSnippetCreate(ctx, project.ID, &opts)
 		if err != nil || snip == nil {
 			log.Fatal(errors.Wrap(err, "failed to create snippet"))
 		}
@@ -5,7 +5,6 @@ import (
 	"log"
 
 	"github.com/spf13/cobra"
-	lab "github.com/zaquestion/lab/internal/gitlab"
 )
 
 // snippetDeleteCmd represents the snippetDelete command
@@ -20,7 +19,7 @@ var snippetDeleteCmd = &cobra.Command{
 			log.Fatal(err)
 		}
 		if global || rn == "" {
-			err = lab.SnippetDelete(int(id))
+			err = labClient.SnippetDelete(ctx, int(id))
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -28,11 +27,11 @@ var snippetDeleteCmd = &cobra.Command{
 			return
 		}
 
-		project, err := lab.FindProject(rn)
+		project, err := labClient.FindProject(ctx, rn)
 		if err != nil {
 			log.Fatal(err)
 		}
-		err = lab.ProjectSnippetDelete(project.ID, int(id))
+		err = labClient.ProjectSnippetDelete(ctx, project.ID, int(id))
 		if err != nil {
 			log.Fatal(err)
 		}
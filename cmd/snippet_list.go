@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"log"
+	"os"
 
 	"github.com/spf13/cobra"
 	gitlab "github.com/xanzy/go-gitlab"
@@ -25,46 +26,36 @@ var snippetListCmd = &cobra.Command{
 		if err != nil {
 			log.Fatal(err)
 		}
-		listOpts := gitlab.ListOptions{
-			PerPage: snippetListConfig.Number,
-		}
-
 		num := snippetListConfig.Number
 		if snippetListConfig.All {
 			num = -1
 		}
 		// See if we're in a git repo or if global is set to determine
 		// if this should be a personal snippet
+		var it *lab.Iterator
 		if global || rn == "" {
-			opts := gitlab.ListSnippetsOptions(listOpts)
-			snips, err := lab.SnippetList(opts, num)
+			it = labClient.SnippetListIter(ctx, num)
+		} else {
+			project, err := labClient.FindProject(ctx, rn)
 			if err != nil {
 				log.Fatal(err)
 			}
-			for _, snip := range snips {
-				fmt.Printf("#%d %s\n", snip.ID, snip.Title)
-			}
-			return
-		}
-
-		project, err := lab.FindProject(rn)
-		if err != nil {
-			log.Fatal(err)
+			it = labClient.ProjectSnippetListIter(ctx, project.ID, num)
 		}
-		opts := gitlab.ListProjectSnippetsOptions(listOpts)
-		snips, err := lab.ProjectSnippetList(project.ID, opts, num)
+		err = printList(os.Stdout, it, func(v interface{}) {
+			snip := v.(*gitlab.Snippet)
+			fmt.Printf("#%d %s\n", snip.ID, snip.Title)
+		})
 		if err != nil {
 			log.Fatal(err)
 		}
-		for _, snip := range snips {
-			fmt.Printf("#%d %s\n", snip.ID, snip.Title)
-		}
 	},
 }
 
 func init() {
 	snippetListCmd.Flags().IntVarP(&snippetListConfig.Number, "number", "n", 10, "Number of snippets to return")
 	snippetListCmd.Flags().BoolVarP(&snippetListConfig.All, "all", "a", false, "List all snippets")
+	addFormatFlag(snippetListCmd)
 
 	snippetListCmd.MarkZshCompPositionalArgumentCustom(1, "__lab_completion_remote")
 	snippetCmd.AddCommand(snippetListCmd)
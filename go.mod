@@ -38,6 +38,7 @@ require (
 	golang.org/x/crypto v0.0.0-20180904163835-0709b304e793
 	golang.org/x/tools v0.0.0-20190107155254-e063def13b29 // indirect
 	gopkg.in/DATA-DOG/go-sqlmock.v1 v1.3.0 // indirect
+	gopkg.in/yaml.v2 v2.2.1
 )
 
 replace github.com/spf13/cobra => github.com/rsteube/cobra v0.0.1-zsh-completion-custom
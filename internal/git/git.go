@@ -110,6 +110,29 @@ func Log(sha1, sha2 string) (string, error) {
 	return string(outputs), nil
 }
 
+// RevParse returns the commit SHA of rev, such as HEAD~1 or a branch name
+func RevParse(rev string) (string, error) {
+	cmd := New("rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	cmd.Stdout = nil
+	sha, err := cmd.Output()
+	if err != nil {
+		return "", errors.Errorf("%s is not a commit", rev)
+	}
+	return strings.TrimSpace(string(sha)), nil
+}
+
+// RemoteDefaultBranch returns the default branch of remote, as recorded by
+// clone or git remote set-head in refs/remotes/<remote>/HEAD
+func RemoteDefaultBranch(remote string) (string, error) {
+	cmd := New("symbolic-ref", "--quiet", "refs/remotes/"+remote+"/HEAD")
+	cmd.Stdout = nil
+	ref, err := cmd.Output()
+	if err != nil {
+		return "", errors.Errorf("default branch of %s is not known", remote)
+	}
+	return strings.TrimPrefix(strings.TrimSpace(string(ref)), "refs/remotes/"+remote+"/"), nil
+}
+
 // CurrentBranch returns the currently checked out branch and strips away all
 // but the branchname itself.
 func CurrentBranch() (string, error) {
@@ -139,9 +162,23 @@ func CurrentBranch() (string, error) {
 // Such as zaquestion/lab
 // Respects GitLab subgroups (https://docs.gitlab.com/ce/user/group/subgroups/)
 func PathWithNameSpace(remote string) (string, error) {
+	_, path, err := parseRemote(remote)
+	return path, err
+}
+
+// RemoteHost returns the hostname of the server hosting remote, such as
+// gitlab.com. Any user info and port in the remote url are dropped.
+func RemoteHost(remote string) (string, error) {
+	host, _, err := parseRemote(remote)
+	return host, err
+}
+
+// parseRemote splits the url of remote into its hostname and the path of the
+// project on that host
+func parseRemote(remote string) (string, string, error) {
 	remoteURL, err := gitconfig.Local("remote." + remote + ".url")
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
 	parts := strings.Split(remoteURL, "//")
@@ -155,15 +192,22 @@ func PathWithNameSpace(remote string) (string, error) {
 		part := parts[1]
 		parts = strings.SplitN(part, "/", 2)
 	} else {
-		return "", errors.Errorf("cannot parse remote: %s url: %s", remote, remoteURL)
+		return "", "", errors.Errorf("cannot parse remote: %s url: %s", remote, remoteURL)
 	}
 
 	if len(parts) != 2 {
-		return "", errors.Errorf("cannot parse remote: %s url: %s", remote, remoteURL)
+		return "", "", errors.Errorf("cannot parse remote: %s url: %s", remote, remoteURL)
+	}
+	host := parts[0]
+	if i := strings.LastIndex(host, "@"); i >= 0 {
+		host = host[i+1:]
+	}
+	if i := strings.Index(host, ":"); i >= 0 {
+		host = host[:i]
 	}
 	path := parts[1]
 	path = strings.TrimSuffix(path, ".git")
-	return path, nil
+	return host, path, nil
 }
 
 // RepoName returns the name of the repository, such as "lab"
@@ -82,6 +82,15 @@ func TestLog(t *testing.T) {
 	assert.Contains(t, log, expectedMessage)
 }
 
+func TestRevParse(t *testing.T) {
+	sha, err := RevParse("HEAD")
+	require.NoError(t, err)
+	require.Equal(t, "09b519cba018b707c98fc56e37df15806d89d866", sha)
+
+	_, err = RevParse("no-such-rev")
+	require.EqualError(t, err, "no-such-rev is not a commit")
+}
+
 func TestCurrentBranch(t *testing.T) {
 	branch, err := CurrentBranch()
 	if err != nil {
@@ -193,6 +202,30 @@ func TestPathWithNameSpace(t *testing.T) {
 	}
 }
 
+func TestRemoteHost(t *testing.T) {
+	tests := []struct {
+		remote   string
+		expected string
+	}{
+		{"origin", "gitlab.com"},
+		{"origin-https", "gitlab.com"},
+		{"origin-https-token", "gitlab.com"},
+		{"origin-git", "gitlab.com"},
+		{"origin-ssh-alt", "gitlab.com"},
+		{"origin-custom-port", "git.mydomain.net"},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.remote, func(t *testing.T) {
+			t.Parallel()
+			host, err := RemoteHost(test.remote)
+			assert.Nil(t, err)
+			assert.Equal(t, test.expected, host)
+		})
+	}
+}
+
 func TestRepoName(t *testing.T) {
 	repo, err := RepoName()
 	if err != nil {
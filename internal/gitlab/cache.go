@@ -0,0 +1,210 @@
+package gitlab
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CacheDir returns the directory lab caches API responses in, following the
+// XDG base directory specification
+func CacheDir() (string, error) {
+	dir := os.Getenv("XDG_CACHE_HOME")
+	if dir == "" {
+		var err error
+		dir, err = os.UserCacheDir()
+		if err != nil {
+			return "", err
+		}
+	}
+	return filepath.Join(dir, "lab"), nil
+}
+
+// cacheRule selects the GET requests which are cached, matching the path
+// after /api/v4. Cached responses are used without asking GitLab until ttl
+// has elapsed, and then revalidated using their ETag.
+type cacheRule struct {
+	// group is the subdirectory the responses are stored in. Changing
+	// anything under /projects removes the cached responses of the
+	// "projects" group.
+	group   string
+	pattern *regexp.Regexp
+	ttl     time.Duration
+}
+
+var cacheRules = []cacheRule{
+	// The current user, looked up when no user is configured
+	{"user", regexp.MustCompile(`^/user$`), 24 * time.Hour},
+	// User IDs, looked up by username
+	{"user", regexp.MustCompile(`^/users$`), 24 * time.Hour},
+	{"projects", regexp.MustCompile(`^/projects/[^/]+$`), time.Hour},
+	{"projects", regexp.MustCompile(`^/projects/[^/]+/labels$`), time.Hour},
+}
+
+// cacheTransport is an http.RoundTripper which stores the responses to the
+// requests matching cacheRules on disk, so that they can be reused by later
+// invocations of lab
+type cacheTransport struct {
+	base http.RoundTripper
+	dir  string
+}
+
+type noCacheKey struct{}
+
+// withoutCache returns a context for requests which must reach GitLab
+func withoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noCacheKey{}, true)
+}
+
+type cacheEntry struct {
+	URL    string      `json:"url"`
+	ETag   string      `json:"etag"`
+	Stored time.Time   `json:"stored"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	path := apiPath(req)
+	if req.Method != http.MethodGet {
+		resp, err := t.base.RoundTrip(req)
+		if err == nil && resp.StatusCode < 300 && strings.HasPrefix(path, "/projects") {
+			os.RemoveAll(filepath.Join(t.dir, tokenDir(req), "projects"))
+		}
+		return resp, err
+	}
+	var rule *cacheRule
+	for i := range cacheRules {
+		if cacheRules[i].pattern.MatchString(path) {
+			rule = &cacheRules[i]
+			break
+		}
+	}
+	if rule == nil || req.Context().Value(noCacheKey{}) != nil {
+		return t.base.RoundTrip(req)
+	}
+
+	sum := sha256.Sum256([]byte(req.URL.String()))
+	file := filepath.Join(t.dir, tokenDir(req), rule.group, hex.EncodeToString(sum[:])+".json")
+	entry := loadCacheEntry(file)
+	if entry != nil && time.Since(entry.Stored) < rule.ttl {
+		return entry.response(req), nil
+	}
+	if entry != nil && entry.ETag != "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("If-None-Match", entry.ETag)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		resp.Body.Close()
+		entry.Stored = time.Now()
+		entry.store(file)
+		return entry.response(req), nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	entry = &cacheEntry{
+		URL:    req.URL.String(),
+		ETag:   resp.Header.Get("ETag"),
+		Stored: time.Now(),
+		Header: resp.Header,
+		Body:   body,
+	}
+	entry.store(file)
+	return resp, nil
+}
+
+// apiPath returns the path of req after /api/v4
+func apiPath(req *http.Request) string {
+	path := req.URL.EscapedPath()
+	if i := strings.Index(path, "/api/v4/"); i >= 0 {
+		return path[i+len("/api/v4"):]
+	}
+	return path
+}
+
+// tokenDir returns the directory of the responses cached for the user
+// authenticated by req, so that users never see each others responses
+func tokenDir(req *http.Request) string {
+	sum := sha256.Sum256([]byte(req.Header.Get("Private-Token") + req.Header.Get("Authorization")))
+	return hex.EncodeToString(sum[:8])
+}
+
+func loadCacheEntry(file string) *cacheEntry {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(b, &entry); err != nil {
+		return nil
+	}
+	return &entry
+}
+
+// store writes e to file. The cache is only an optimisation, so failing to
+// write it is not an error.
+func (e *cacheEntry) store(file string) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return
+	}
+	// Write to a temporary file first, so that concurrent invocations of
+	// lab never read a partial entry
+	tmp, err := ioutil.TempFile(filepath.Dir(file), ".entry")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(b)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+func (e *cacheEntry) response(req *http.Request) *http.Response {
+	header := e.Header.Clone()
+	header.Set("Content-Length", strconv.Itoa(len(e.Body)))
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
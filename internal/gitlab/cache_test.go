@@ -0,0 +1,98 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// etagServer serves the project one/test, supporting If-None-Match, and
+// counts the requests made for each response status
+func etagServer() (*httptest.Server, *int32, *int32) {
+	var ok, notModified int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{}`)
+			return
+		}
+		w.Header().Set("ETag", `W/"v1"`)
+		if r.Header.Get("If-None-Match") == `W/"v1"` {
+			atomic.AddInt32(&notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		atomic.AddInt32(&ok, 1)
+		fmt.Fprint(w, `{"id": 1, "path_with_namespace": "one/test"}`)
+	}))
+	return srv, &ok, &notModified
+}
+
+// ageCache makes every entry in dir look like it was stored d ago
+func ageCache(t *testing.T, dir string, d time.Duration) {
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var entry cacheEntry
+		if err := json.Unmarshal(b, &entry); err != nil {
+			return err
+		}
+		entry.Stored = entry.Stored.Add(-d)
+		entry.store(path)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestCache(t *testing.T) {
+	srv, ok, notModified := etagServer()
+	defer srv.Close()
+	dir, err := ioutil.TempDir("", "lab-cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	findProject := func() {
+		// Each lab invocation has a new client
+		c := NewClient(Config{Host: srv.URL, User: "one", Token: "token", CacheDir: dir})
+		p, err := c.FindProject(context.Background(), "test")
+		require.NoError(t, err)
+		require.Equal(t, "one/test", p.PathWithNamespace)
+	}
+
+	findProject()
+	findProject()
+	assert.Equal(t, int32(1), atomic.LoadInt32(ok), "Expected the project to be cached")
+
+	ageCache(t, dir, 2*time.Hour)
+	findProject()
+	findProject()
+	assert.Equal(t, int32(1), atomic.LoadInt32(ok))
+	assert.Equal(t, int32(1), atomic.LoadInt32(notModified), "Expected the stale project to be revalidated once")
+
+	c := NewClient(Config{Host: srv.URL, User: "one", Token: "token", CacheDir: dir})
+	err = c.IssueClose(context.Background(), 1, 1)
+	require.NoError(t, err)
+	findProject()
+	assert.Equal(t, int32(2), atomic.LoadInt32(ok), "Expected changing the project to clear its cache")
+
+	other := NewClient(Config{Host: srv.URL, User: "one", Token: "other", CacheDir: dir})
+	_, err = other.FindProject(context.Background(), "test")
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(ok), "Expected the cache not to be shared between tokens")
+}
@@ -1,18 +1,25 @@
 // Package gitlab is an internal wrapper for the go-gitlab package
 //
-// Most functions serve to expose debug logging if set and accept a project
-// name string over an ID.
+// All API access goes through a Client, so a single process may talk to
+// several GitLab instances.
 package gitlab
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	gitlab "github.com/xanzy/go-gitlab"
@@ -24,33 +31,65 @@ var (
 	ErrProjectNotFound = errors.New("gitlab project not found")
 )
 
-var (
+// Config holds the settings used to create a Client
+type Config struct {
+	// Host is the scheme://hostname of the GitLab instance
+	Host string
+	// User is optional, it is looked up using the Token when empty
+	User  string
+	Token string
+	// CacheDir is where slow-changing objects, such as projects, are
+	// cached between invocations. Nothing is cached when empty.
+	CacheDir string
+}
+
+// Client talks to a single GitLab instance on behalf of a user. Most methods
+// expose debug logging if set and accept a project name string over an ID.
+// The requests of a method are canceled with the context passed to it.
+type Client struct {
 	lab   *gitlab.Client
 	host  string
 	user  string
 	token string
-)
 
-// Host exposes the GitLab scheme://hostname used to interact with the API
-func Host() string {
-	return host
+	localProjects map[string]*gitlab.Project
+}
+
+// NewClient returns a Client for the GitLab instance described by cfg
+func NewClient(cfg Config) *Client {
+	host := strings.TrimSuffix(cfg.Host, "/")
+	var transport http.RoundTripper = newRetryTransport(http.DefaultTransport)
+	if cfg.CacheDir != "" {
+		transport = &cacheTransport{base: transport, dir: cfg.CacheDir}
+	}
+	httpClient := &http.Client{Transport: transport}
+	lab := gitlab.NewClient(httpClient, cfg.Token)
+	lab.SetBaseURL(host + "/api/v4")
+	return &Client{
+		lab:           lab,
+		host:          host,
+		user:          cfg.User,
+		token:         cfg.Token,
+		localProjects: make(map[string]*gitlab.Project),
+	}
 }
 
-// User exposes the configured GitLab user
-func User() string {
-	return user
+// Host exposes the GitLab scheme://hostname used to interact with the API
+func (c *Client) Host() string {
+	return c.host
 }
 
-// Init initializes a gitlab client for use throughout lab.
-func Init(_host, _user, _token string) {
-	if len(_host) > 0 && _host[len(_host)-1 : len(_host)][0] == '/' {
-		_host = _host[0 : len(_host)-1]
+// User exposes the configured GitLab user. When no user was configured it is
+// looked up using the token.
+func (c *Client) User(ctx context.Context) (string, error) {
+	if c.user == "" {
+		u, _, err := c.lab.Users.CurrentUser(gitlab.WithContext(ctx))
+		if err != nil {
+			return "", err
+		}
+		c.user = u.Username
 	}
-	host = _host
-	user = _user
-	token = _token
-	lab = gitlab.NewClient(nil, token)
-	lab.SetBaseURL(host + "/api/v4")
+	return c.user, nil
 }
 
 // Defines filepath for default GitLab templates
@@ -84,13 +123,9 @@ func LoadGitLabTmpl(tmplName string) string {
 	return strings.TrimSpace(string(tmpl))
 }
 
-var (
-	localProjects map[string]*gitlab.Project = make(map[string]*gitlab.Project)
-)
-
 // GetProject looks up a Gitlab project by ID.
-func GetProject(projectID interface{}) (*gitlab.Project, error) {
-	target, resp, err := lab.Projects.GetProject(projectID)
+func (c *Client) GetProject(ctx context.Context, projectID interface{}) (*gitlab.Project, error) {
+	target, resp, err := c.lab.Projects.GetProject(projectID, gitlab.WithContext(ctx))
 	if resp != nil && resp.StatusCode == http.StatusNotFound {
 		return nil, ErrProjectNotFound
 	}
@@ -102,18 +137,22 @@ func GetProject(projectID interface{}) (*gitlab.Project, error) {
 
 // FindProject looks up the Gitlab project. If the namespace is not provided in
 // the project string it will search for projects in the users namespace
-func FindProject(project string) (*gitlab.Project, error) {
-	if target, ok := localProjects[project]; ok {
+func (c *Client) FindProject(ctx context.Context, project string) (*gitlab.Project, error) {
+	if target, ok := c.localProjects[project]; ok {
 		return target, nil
 	}
 
 	search := project
 	// Assuming that a "/" in the project means its owned by an org
 	if !strings.Contains(project, "/") {
+		user, err := c.User(ctx)
+		if err != nil {
+			return nil, err
+		}
 		search = user + "/" + project
 	}
 
-	target, resp, err := lab.Projects.GetProject(search)
+	target, resp, err := c.lab.Projects.GetProject(search, gitlab.WithContext(ctx))
 	if resp != nil && resp.StatusCode == http.StatusNotFound {
 		return nil, ErrProjectNotFound
 	}
@@ -121,32 +160,32 @@ func FindProject(project string) (*gitlab.Project, error) {
 		return nil, err
 	}
 	// fwiw, I feel bad about this
-	localProjects[project] = target
+	c.localProjects[project] = target
 
 	return target, nil
 }
 
 // Fork creates a user fork of a GitLab project
-func Fork(project string) (string, error) {
+func (c *Client) Fork(ctx context.Context, project string) (string, error) {
 	if !strings.Contains(project, "/") {
 		return "", errors.New("remote must include namespace")
 	}
 	parts := strings.Split(project, "/")
 
 	// See if a fork already exists
-	target, err := FindProject(parts[1])
+	target, err := c.FindProject(ctx, parts[1])
 	if err == nil {
 		return target.SSHURLToRepo, nil
 	} else if err != nil && err != ErrProjectNotFound {
 		return "", err
 	}
 
-	target, err = FindProject(project)
+	target, err = c.FindProject(ctx, project)
 	if err != nil {
 		return "", err
 	}
 
-	fork, _, err := lab.Projects.ForkProject(target.ID)
+	fork, _, err := c.lab.Projects.ForkProject(target.ID, gitlab.WithContext(ctx))
 	if err != nil {
 		return "", err
 	}
@@ -154,98 +193,323 @@ func Fork(project string) (string, error) {
 	return fork.SSHURLToRepo, nil
 }
 
+// MRCreateOptions are the options of MRCreate, adding several assignees and
+// reviewers, which go-gitlab predates
+type MRCreateOptions struct {
+	gitlab.CreateMergeRequestOptions
+	AssigneeIDs []int `json:"assignee_ids,omitempty"`
+	ReviewerIDs []int `json:"reviewer_ids,omitempty"`
+}
+
 // MRCreate opens a merge request on GitLab
-func MRCreate(project string, opts *gitlab.CreateMergeRequestOptions) (string, error) {
-	p, err := FindProject(project)
+func (c *Client) MRCreate(ctx context.Context, project string, opts *MRCreateOptions) (string, error) {
+	p, err := c.FindProject(ctx, project)
 	if err != nil {
 		return "", err
 	}
 
-	mr, _, err := lab.MergeRequests.CreateMergeRequest(p.ID, opts)
+	u := fmt.Sprintf("projects/%d/merge_requests", p.ID)
+	req, err := c.lab.NewRequest("POST", u, opts, []gitlab.OptionFunc{gitlab.WithContext(ctx)})
 	if err != nil {
 		return "", err
 	}
+	mr := new(gitlab.MergeRequest)
+	if _, err := c.lab.Do(req, mr); err != nil {
+		return "", err
+	}
 	return mr.WebURL, nil
 }
 
 // MRCreateNote adds a note to a merge request on GitLab
-func MRCreateNote(project string, mrNum int, opts *gitlab.CreateMergeRequestNoteOptions) (string, error) {
-        p, err := FindProject(project)
-        if err != nil {
-                return "", err
-        }
+func (c *Client) MRCreateNote(ctx context.Context, project string, mrNum int, opts *gitlab.CreateMergeRequestNoteOptions) (string, error) {
+	p, err := c.FindProject(ctx, project)
+	if err != nil {
+		return "", err
+	}
+
+	note, _, err := c.lab.Notes.CreateMergeRequestNote(p.ID, mrNum, opts, gitlab.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	// Unlike MR, Note has no WebURL property, so we have to create it
+	// ourselves from the project, noteable id and note id
+	return fmt.Sprintf("%s/merge_requests/%d#note_%d", p.WebURL, note.NoteableIID, note.ID), nil
+}
 
-        note, _, err := lab.Notes.CreateMergeRequestNote(p.ID, mrNum, opts)
-        if err != nil {
-                return "", err
-        }
-        // Unlike MR, Note has no WebURL property, so we have to create it
-        // ourselves from the project, noteable id and note id
-        return fmt.Sprintf("%s/merge_requests/%d#note_%d", p.WebURL, note.NoteableIID, note.ID), nil
+// MergeRequest is a merge request along with its assignees, reviewers and
+// the details of whether it can be merged, which the MergeRequest of
+// go-gitlab predates
+type MergeRequest struct {
+	gitlab.MergeRequest `yaml:",inline"`
+	Assignees           []*gitlab.IssueAssignee `json:"assignees"`
+	Reviewers           []*gitlab.IssueAssignee `json:"reviewers"`
+	// DetailedMergeStatus tells what prevents merging, such as
+	// "discussions_not_resolved", or "mergeable"
+	DetailedMergeStatus string `json:"detailed_merge_status,omitempty"`
+	HasConflicts        bool   `json:"has_conflicts"`
+	// BlockingDiscussionsResolved is nil when GitLab doesn't report it
+	BlockingDiscussionsResolved *bool `json:"blocking_discussions_resolved,omitempty"`
 }
 
 // MRGet retrieves the merge request from GitLab project
-func MRGet(project string, mrNum int) (*gitlab.MergeRequest, error) {
-	p, err := FindProject(project)
+func (c *Client) MRGet(ctx context.Context, project string, mrNum int) (*MergeRequest, error) {
+	p, err := c.FindProject(ctx, project)
 	if err != nil {
 		return nil, err
 	}
 
-	mr, _, err := lab.MergeRequests.GetMergeRequest(p.ID, mrNum, nil)
+	u := fmt.Sprintf("projects/%d/merge_requests/%d", p.ID, mrNum)
+	req, err := c.lab.NewRequest("GET", u, nil, []gitlab.OptionFunc{gitlab.WithContext(ctx)})
 	if err != nil {
 		return nil, err
 	}
+	mr := new(MergeRequest)
+	if _, err := c.lab.Do(req, mr); err != nil {
+		return nil, err
+	}
 
 	return mr, nil
 }
 
-// MRList lists the MRs on a GitLab project
-func MRList(project string, opts gitlab.ListProjectMergeRequestsOptions, n int) ([]*gitlab.MergeRequest, error) {
-	if n == -1 {
-		opts.PerPage = 100
+// MRChanges retrieves a merge request along with the diffs of the files it
+// changes
+func (c *Client) MRChanges(ctx context.Context, project string, mrNum int) (*gitlab.MergeRequest, error) {
+	p, err := c.FindProject(ctx, project)
+	if err != nil {
+		return nil, err
 	}
-	p, err := FindProject(project)
+
+	mr, _, err := c.lab.MergeRequests.GetMergeRequestChanges(p.ID, mrNum, gitlab.WithContext(ctx))
 	if err != nil {
 		return nil, err
 	}
+	return mr, nil
+}
 
-	list, resp, err := lab.MergeRequests.ListProjectMergeRequests(p.ID, &opts)
+// MRVersions retrieves the versions of the diff of a merge request, one for
+// each time its source branch was pushed, the newest first. The versions are
+// returned without their diffs.
+func (c *Client) MRVersions(ctx context.Context, project string, mrNum int) ([]*gitlab.MergeRequestDiffVersion, error) {
+	p, err := c.FindProject(ctx, project)
 	if err != nil {
 		return nil, err
 	}
-	if resp.CurrentPage == resp.TotalPages {
-		return list, nil
+
+	it := paginate(ctx, -1, func(ctx context.Context, lo gitlab.ListOptions) (interface{}, *gitlab.Response, error) {
+		opt := gitlab.GetMergeRequestDiffVersionsOptions(lo)
+		return c.lab.MergeRequests.GetMergeRequestDiffVersions(p.ID, mrNum, &opt, gitlab.WithContext(ctx))
+	})
+	defer it.Close()
+	versions := []*gitlab.MergeRequestDiffVersion{}
+	for it.Next() {
+		versions = append(versions, it.Value().(*gitlab.MergeRequestDiffVersion))
 	}
-	opts.Page = resp.NextPage
-	for len(list) < n || n == -1 {
-		if n != -1 {
-			opts.PerPage = n - len(list)
-		}
-		mrs, resp, err := lab.MergeRequests.ListProjectMergeRequests(p.ID, &opts)
+	return versions, it.Err()
+}
+
+// MRVersion retrieves a version of the diff of a merge request along with
+// its diffs
+func (c *Client) MRVersion(ctx context.Context, project string, mrNum, versionID int) (*gitlab.MergeRequestDiffVersion, error) {
+	p, err := c.FindProject(ctx, project)
+	if err != nil {
+		return nil, err
+	}
+
+	v, _, err := c.lab.MergeRequests.GetSingleMergeRequestDiffVersion(p.ID, mrNum, versionID, gitlab.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Compare retrieves the diffs between two commits of a project
+func (c *Client) Compare(ctx context.Context, pid interface{}, from, to string) (*gitlab.Compare, error) {
+	cmp, _, err := c.lab.Repositories.Compare(pid, &gitlab.CompareOptions{
+		From: &from,
+		To:   &to,
+	}, gitlab.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return cmp, nil
+}
+
+// MRListDiscussions retrieves the discussions (aka notes & comments) for a
+// merge request, including the threads started on its diff
+func (c *Client) MRListDiscussions(ctx context.Context, project string, mrNum int) ([]*gitlab.Discussion, error) {
+	p, err := c.FindProject(ctx, project)
+	if err != nil {
+		return nil, err
+	}
+
+	it := paginate(ctx, -1, func(ctx context.Context, lo gitlab.ListOptions) (interface{}, *gitlab.Response, error) {
+		opt := gitlab.ListMergeRequestDiscussionsOptions(lo)
+		return c.lab.Discussions.ListMergeRequestDiscussions(p.ID, mrNum, &opt, gitlab.WithContext(ctx))
+	})
+	defer it.Close()
+	discussions := []*gitlab.Discussion{}
+	for it.Next() {
+		discussions = append(discussions, it.Value().(*gitlab.Discussion))
+	}
+	return discussions, it.Err()
+}
+
+// MRCreateDiscussion starts a thread on a merge request, on a line of its
+// diff when opts has a Position, and returns the URL of its first note
+func (c *Client) MRCreateDiscussion(ctx context.Context, project string, mrNum int, opts *gitlab.CreateMergeRequestDiscussionOptions) (string, error) {
+	p, err := c.FindProject(ctx, project)
+	if err != nil {
+		return "", err
+	}
+
+	d, _, err := c.lab.Discussions.CreateMergeRequestDiscussion(p.ID, mrNum, opts, gitlab.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	if len(d.Notes) == 0 {
+		return fmt.Sprintf("%s/merge_requests/%d", p.WebURL, mrNum), nil
+	}
+	return fmt.Sprintf("%s/merge_requests/%d#note_%d", p.WebURL, mrNum, d.Notes[0].ID), nil
+}
+
+// MRAddDiscussionNote replies to a thread of a merge request and returns the
+// URL of the reply
+func (c *Client) MRAddDiscussionNote(ctx context.Context, project string, mrNum int, discussion string, body string) (string, error) {
+	p, err := c.FindProject(ctx, project)
+	if err != nil {
+		return "", err
+	}
+
+	note, _, err := c.lab.Discussions.AddMergeRequestDiscussionNote(p.ID, mrNum, discussion, &gitlab.AddMergeRequestDiscussionNoteOptions{
+		Body: &body,
+	}, gitlab.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/merge_requests/%d#note_%d", p.WebURL, mrNum, note.ID), nil
+}
+
+// MRResolveDiscussion resolves or unresolves a thread of a merge request
+func (c *Client) MRResolveDiscussion(ctx context.Context, project string, mrNum int, discussion string, resolved bool) error {
+	p, err := c.FindProject(ctx, project)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = c.lab.Discussions.ResolveMergeRequestDiscussion(p.ID, mrNum, discussion, &gitlab.ResolveMergeRequestDiscussionOptions{
+		Resolved: &resolved,
+	}, gitlab.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// MRUpdateOptions are the changes made by MRUpdate. Labels, AssigneeIDs and
+// ReviewerIDs replace the labels, assignees and reviewers when not nil, so
+// unlike with go-gitlab an empty list removes them all.
+type MRUpdateOptions struct {
+	gitlab.UpdateMergeRequestOptions
+	Labels      *gitlab.Labels `json:"labels,omitempty"`
+	AssigneeIDs *[]int         `json:"assignee_ids,omitempty"`
+	ReviewerIDs *[]int         `json:"reviewer_ids,omitempty"`
+}
+
+// MRUpdate edits a merge request on a GitLab project and returns its URL
+func (c *Client) MRUpdate(ctx context.Context, project string, mrNum int, opts *MRUpdateOptions) (string, error) {
+	p, err := c.FindProject(ctx, project)
+	if err != nil {
+		return "", err
+	}
+
+	u := fmt.Sprintf("projects/%d/merge_requests/%d", p.ID, mrNum)
+	req, err := c.lab.NewRequest("PUT", u, opts, []gitlab.OptionFunc{gitlab.WithContext(ctx)})
+	if err != nil {
+		return "", err
+	}
+	mr := new(gitlab.MergeRequest)
+	if _, err := c.lab.Do(req, mr); err != nil {
+		return "", err
+	}
+	return mr.WebURL, nil
+}
+
+// MRListOptions are the filters of MRListIter, adding filtering by reviewer
+// and draft status, which go-gitlab predates
+type MRListOptions struct {
+	gitlab.ListProjectMergeRequestsOptions
+	ReviewerID *int `url:"reviewer_id,omitempty" json:"reviewer_id,omitempty"`
+	// WIP is "yes" to list drafts only and "no" to leave them out
+	WIP *string `url:"wip,omitempty" json:"wip,omitempty"`
+}
+
+// MRListIter streams the MRs on a GitLab project, stopping after n unless n
+// is -1
+func (c *Client) MRListIter(ctx context.Context, project string, opts MRListOptions, n int) *Iterator {
+	p, err := c.FindProject(ctx, project)
+	if err != nil {
+		return errIterator(err)
+	}
+	u := fmt.Sprintf("projects/%d/merge_requests", p.ID)
+	return paginate(ctx, n, func(ctx context.Context, lo gitlab.ListOptions) (interface{}, *gitlab.Response, error) {
+		opts := opts
+		opts.ListOptions = lo
+		req, err := c.lab.NewRequest("GET", u, &opts, []gitlab.OptionFunc{gitlab.WithContext(ctx)})
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		opts.Page = resp.NextPage
-		list = append(list, mrs...)
-		if resp.CurrentPage == resp.TotalPages {
-			break
+		var mrs []*gitlab.MergeRequest
+		resp, err := c.lab.Do(req, &mrs)
+		if err != nil {
+			return nil, resp, err
 		}
+		return mrs, resp, nil
+	})
+}
+
+// MRList lists the MRs on a GitLab project
+func (c *Client) MRList(ctx context.Context, project string, opts gitlab.ListProjectMergeRequestsOptions, n int) ([]*gitlab.MergeRequest, error) {
+	it := c.MRListIter(ctx, project, MRListOptions{ListProjectMergeRequestsOptions: opts}, n)
+	defer it.Close()
+	list := []*gitlab.MergeRequest{}
+	for it.Next() {
+		list = append(list, it.Value().(*gitlab.MergeRequest))
+	}
+	return list, it.Err()
+}
+
+// MRApprovals retrieves who approved a merge request and how many approvals
+// it still needs
+func (c *Client) MRApprovals(ctx context.Context, project string, mrNum int) (*gitlab.MergeRequestApprovals, error) {
+	p, err := c.FindProject(ctx, project)
+	if err != nil {
+		return nil, err
 	}
-	return list, nil
+
+	u := fmt.Sprintf("projects/%d/merge_requests/%d/approvals", p.ID, mrNum)
+	req, err := c.lab.NewRequest("GET", u, nil, []gitlab.OptionFunc{gitlab.WithContext(ctx)})
+	if err != nil {
+		return nil, err
+	}
+	approvals := new(gitlab.MergeRequestApprovals)
+	if _, err := c.lab.Do(req, approvals); err != nil {
+		return nil, err
+	}
+	return approvals, nil
 }
 
 // MRClose closes an mr on a GitLab project
-func MRClose(pid interface{}, id int) error {
-	mr, _, err := lab.MergeRequests.GetMergeRequest(pid, id, nil)
+func (c *Client) MRClose(ctx context.Context, pid interface{}, id int) error {
+	mr, _, err := c.lab.MergeRequests.GetMergeRequest(pid, id, nil, gitlab.WithContext(ctx))
 	if err != nil {
 		return err
 	}
 	if mr.State == "closed" {
 		return fmt.Errorf("mr already closed")
 	}
-	_, _, err = lab.MergeRequests.UpdateMergeRequest(pid, int(id), &gitlab.UpdateMergeRequestOptions{
+	_, _, err = c.lab.MergeRequests.UpdateMergeRequest(pid, int(id), &gitlab.UpdateMergeRequestOptions{
 		StateEvent: gitlab.String("close"),
-	})
+	}, gitlab.WithContext(ctx))
 	if err != nil {
 		return err
 	}
@@ -253,28 +517,58 @@ func MRClose(pid interface{}, id int) error {
 }
 
 // MRRebase merges an mr on a GitLab project
-func MRRebase(pid interface{}, id int) error {
-	_, err := lab.MergeRequests.RebaseMergeRequest(pid, int(id))
+func (c *Client) MRRebase(ctx context.Context, pid interface{}, id int) error {
+	_, err := c.lab.MergeRequests.RebaseMergeRequest(pid, int(id), gitlab.WithContext(ctx))
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-// MRMerge merges an mr on a GitLab project
-func MRMerge(pid interface{}, id int) error {
-	_, _, err := lab.MergeRequests.AcceptMergeRequest(pid, int(id), &gitlab.AcceptMergeRequestOptions{
-		MergeWhenPipelineSucceeds: gitlab.Bool(true),
-	})
+// MRMergeOptions are the options of MRMerge, adding the squash options
+// which go-gitlab predates
+type MRMergeOptions struct {
+	gitlab.AcceptMergeRequestOptions
+	Squash              *bool   `json:"squash,omitempty"`
+	SquashCommitMessage *string `json:"squash_commit_message,omitempty"`
+}
+
+// MRMerge merges an mr on a GitLab project, or sets it to be merged once its
+// pipeline succeeds with opts.MergeWhenPipelineSucceeds
+func (c *Client) MRMerge(ctx context.Context, project string, mrNum int, opts *MRMergeOptions) (*gitlab.MergeRequest, error) {
+	p, err := c.FindProject(ctx, project)
+	if err != nil {
+		return nil, err
+	}
+
+	u := fmt.Sprintf("projects/%d/merge_requests/%d/merge", p.ID, mrNum)
+	req, err := c.lab.NewRequest("PUT", u, opts, []gitlab.OptionFunc{gitlab.WithContext(ctx)})
+	if err != nil {
+		return nil, err
+	}
+	mr := new(gitlab.MergeRequest)
+	if _, err := c.lab.Do(req, mr); err != nil {
+		return nil, err
+	}
+	return mr, nil
+}
+
+// MRApprove approves an mr on a GitLab project
+func (c *Client) MRApprove(ctx context.Context, pid interface{}, id int) error {
+	_, _, err := c.lab.MergeRequestApprovals.ApproveMergeRequest(pid, id, &gitlab.ApproveMergeRequestOptions{}, gitlab.WithContext(ctx))
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-// MRApprove approves an mr on a GitLab project
-func MRApprove(pid interface{}, id int) error {
-	_, _, err := lab.MergeRequestApprovals.ApproveMergeRequest(pid, id, &gitlab.ApproveMergeRequestOptions{})
+// MRUnapprove revokes the approval of an mr by the current user. GitLab
+// answers 404 when the user hadn't approved it, which isn't an error here.
+func (c *Client) MRUnapprove(ctx context.Context, pid interface{}, id int) error {
+	resp, err := c.lab.MergeRequestApprovals.UnapproveMergeRequest(pid, id, gitlab.WithContext(ctx))
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
@@ -282,10 +576,10 @@ func MRApprove(pid interface{}, id int) error {
 }
 
 // MRThumbUp places a thumb up/down on a merge request
-func MRThumbUp(pid interface{}, id int) error {
-	_, _, err := lab.AwardEmoji.CreateMergeRequestAwardEmoji(pid, id, &gitlab.CreateAwardEmojiOptions{
+func (c *Client) MRThumbUp(ctx context.Context, pid interface{}, id int) error {
+	_, _, err := c.lab.AwardEmoji.CreateMergeRequestAwardEmoji(pid, id, &gitlab.CreateAwardEmojiOptions{
 		Name: "thumbsup",
-	})
+	}, gitlab.WithContext(ctx))
 	if err != nil {
 		return err
 	}
@@ -293,10 +587,10 @@ func MRThumbUp(pid interface{}, id int) error {
 }
 
 // MRThumbDown places a thumb up/down on a merge request
-func MRThumbDown(pid interface{}, id int) error {
-	_, _, err := lab.AwardEmoji.CreateMergeRequestAwardEmoji(pid, id, &gitlab.CreateAwardEmojiOptions{
+func (c *Client) MRThumbDown(ctx context.Context, pid interface{}, id int) error {
+	_, _, err := c.lab.AwardEmoji.CreateMergeRequestAwardEmoji(pid, id, &gitlab.CreateAwardEmojiOptions{
 		Name: "thumbsdown",
-	})
+	}, gitlab.WithContext(ctx))
 	if err != nil {
 		return err
 	}
@@ -304,13 +598,13 @@ func MRThumbDown(pid interface{}, id int) error {
 }
 
 // IssueCreate opens a new issue on a GitLab project
-func IssueCreate(project string, opts *gitlab.CreateIssueOptions) (string, error) {
-	p, err := FindProject(project)
+func (c *Client) IssueCreate(ctx context.Context, project string, opts *gitlab.CreateIssueOptions) (string, error) {
+	p, err := c.FindProject(ctx, project)
 	if err != nil {
 		return "", err
 	}
 
-	mr, _, err := lab.Issues.CreateIssue(p.ID, opts)
+	mr, _, err := c.lab.Issues.CreateIssue(p.ID, opts, gitlab.WithContext(ctx))
 	if err != nil {
 		return "", err
 	}
@@ -318,13 +612,13 @@ func IssueCreate(project string, opts *gitlab.CreateIssueOptions) (string, error
 }
 
 // IssueUpdate edits an issue on a GitLab project
-func IssueUpdate(project string, issueNum int, opts *gitlab.UpdateIssueOptions) (string, error) {
-	p, err := FindProject(project)
+func (c *Client) IssueUpdate(ctx context.Context, project string, issueNum int, opts *gitlab.UpdateIssueOptions) (string, error) {
+	p, err := c.FindProject(ctx, project)
 	if err != nil {
 		return "", err
 	}
 
-	issue, _, err := lab.Issues.UpdateIssue(p.ID, issueNum, opts)
+	issue, _, err := c.lab.Issues.UpdateIssue(p.ID, issueNum, opts, gitlab.WithContext(ctx))
 	if err != nil {
 		return "", err
 	}
@@ -332,13 +626,13 @@ func IssueUpdate(project string, issueNum int, opts *gitlab.UpdateIssueOptions)
 }
 
 // IssueCreateNote creates a new note on an issue and returns the note URL
-func IssueCreateNote(project string, issueNum int, opts *gitlab.CreateIssueNoteOptions) (string, error) {
-	p, err := FindProject(project)
+func (c *Client) IssueCreateNote(ctx context.Context, project string, issueNum int, opts *gitlab.CreateIssueNoteOptions) (string, error) {
+	p, err := c.FindProject(ctx, project)
 	if err != nil {
 		return "", err
 	}
 
-	note, _, err := lab.Notes.CreateIssueNote(p.ID, issueNum, opts)
+	note, _, err := c.lab.Notes.CreateIssueNote(p.ID, issueNum, opts, gitlab.WithContext(ctx))
 	if err != nil {
 		return "", err
 	}
@@ -349,13 +643,13 @@ func IssueCreateNote(project string, issueNum int, opts *gitlab.CreateIssueNoteO
 }
 
 // IssueGet retrieves the issue information from a GitLab project
-func IssueGet(project string, issueNum int) (*gitlab.Issue, error) {
-	p, err := FindProject(project)
+func (c *Client) IssueGet(ctx context.Context, project string, issueNum int) (*gitlab.Issue, error) {
+	p, err := c.FindProject(ctx, project)
 	if err != nil {
 		return nil, err
 	}
 
-	issue, _, err := lab.Issues.GetIssue(p.ID, issueNum)
+	issue, _, err := c.lab.Issues.GetIssue(p.ID, issueNum, gitlab.WithContext(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -363,47 +657,25 @@ func IssueGet(project string, issueNum int) (*gitlab.Issue, error) {
 	return issue, nil
 }
 
-// IssueList gets a list of issues on a GitLab Project
-func IssueList(project string, opts gitlab.ListProjectIssuesOptions, n int) ([]*gitlab.Issue, error) {
-	if n == -1 {
-		opts.PerPage = 100
-	}
-	p, err := FindProject(project)
+// IssueListIter streams the issues on a GitLab project, stopping after n
+// unless n is -1
+func (c *Client) IssueListIter(ctx context.Context, project string, opts gitlab.ListProjectIssuesOptions, n int) *Iterator {
+	p, err := c.FindProject(ctx, project)
 	if err != nil {
-		return nil, err
-	}
-
-	list, resp, err := lab.Issues.ListProjectIssues(p.ID, &opts)
-	if err != nil {
-		return nil, err
-	}
-	if resp.CurrentPage == resp.TotalPages {
-		return list, nil
-	}
-
-	opts.Page = resp.NextPage
-	for len(list) < n || n == -1 {
-		if n != -1 {
-			opts.PerPage = n - len(list)
-		}
-		issues, resp, err := lab.Issues.ListProjectIssues(p.ID, &opts)
-		if err != nil {
-			return nil, err
-		}
-		opts.Page = resp.NextPage
-		list = append(list, issues...)
-		if resp.CurrentPage == resp.TotalPages {
-			break
-		}
+		return errIterator(err)
 	}
-	return list, nil
+	return paginate(ctx, n, func(ctx context.Context, lo gitlab.ListOptions) (interface{}, *gitlab.Response, error) {
+		opts := opts
+		opts.ListOptions = lo
+		return c.lab.Issues.ListProjectIssues(p.ID, &opts, gitlab.WithContext(ctx))
+	})
 }
 
 // IssueClose closes an issue on a GitLab project
-func IssueClose(pid interface{}, id int) error {
-	_, _, err := lab.Issues.UpdateIssue(pid, id, &gitlab.UpdateIssueOptions{
+func (c *Client) IssueClose(ctx context.Context, pid interface{}, id int) error {
+	_, _, err := c.lab.Issues.UpdateIssue(pid, id, &gitlab.UpdateIssueOptions{
 		StateEvent: gitlab.String("close"),
-	})
+	}, gitlab.WithContext(ctx))
 	if err != nil {
 		return err
 	}
@@ -411,44 +683,44 @@ func IssueClose(pid interface{}, id int) error {
 }
 
 // IssueListDiscussions retrieves the discussions (aka notes & comments) for an issue
-func IssueListDiscussions(project string, issueNum int) ([]*gitlab.Discussion, error) {
-	p, err := FindProject(project)
+func (c *Client) IssueListDiscussions(ctx context.Context, project string, issueNum int) ([]*gitlab.Discussion, error) {
+	p, err := c.FindProject(ctx, project)
 	if err != nil {
 		return nil, err
 	}
 
+	it := paginate(ctx, -1, func(ctx context.Context, lo gitlab.ListOptions) (interface{}, *gitlab.Response, error) {
+		opt := gitlab.ListIssueDiscussionsOptions(lo)
+		return c.lab.Discussions.ListIssueDiscussions(p.ID, issueNum, &opt, gitlab.WithContext(ctx))
+	})
+	defer it.Close()
 	discussions := []*gitlab.Discussion{}
-	opt := &gitlab.ListIssueDiscussionsOptions{
-		// 100 is the maximum allowed by the API
-		PerPage: 100,
-		Page:    1,
+	for it.Next() {
+		discussions = append(discussions, it.Value().(*gitlab.Discussion))
 	}
+	return discussions, it.Err()
+}
 
-	for {
-		// get a page of discussions from the API ...
-		d, resp, err := lab.Discussions.ListIssueDiscussions(p.ID, issueNum, opt)
-		if err != nil {
-			return nil, err
-		}
-
-		// ... and add them to our collection of discussions
-		discussions = append(discussions, d...)
-
-		// if we've seen all the pages, then we can break here
-		if opt.Page >= resp.TotalPages {
-			break
-		}
-
-		// otherwise, update the page number to get the next page.
-		opt.Page = resp.NextPage
+// IssueAddDiscussionNote replies to a thread of an issue and returns the URL
+// of the reply
+func (c *Client) IssueAddDiscussionNote(ctx context.Context, project string, issueNum int, discussion string, body string) (string, error) {
+	p, err := c.FindProject(ctx, project)
+	if err != nil {
+		return "", err
 	}
 
-	return discussions, nil
+	note, _, err := c.lab.Discussions.AddIssueDiscussionNote(p.ID, issueNum, discussion, &gitlab.AddIssueDiscussionNoteOptions{
+		Body: &body,
+	}, gitlab.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/issues/%d#note_%d", p.WebURL, issueNum, note.ID), nil
 }
 
 // BranchPushed checks if a branch exists on a GitLab project
-func BranchPushed(pid interface{}, branch string) bool {
-	b, _, err := lab.Branches.GetBranch(pid, branch)
+func (c *Client) BranchPushed(ctx context.Context, pid interface{}, branch string) bool {
+	b, _, err := c.lab.Branches.GetBranch(pid, branch, gitlab.WithContext(ctx))
 	if err != nil {
 		return false
 	}
@@ -456,13 +728,13 @@ func BranchPushed(pid interface{}, branch string) bool {
 }
 
 // LabelList gets a list of labels on a GitLab Project
-func LabelList(project string) ([]*gitlab.Label, error) {
-	p, err := FindProject(project)
+func (c *Client) LabelList(ctx context.Context, project string) ([]*gitlab.Label, error) {
+	p, err := c.FindProject(ctx, project)
 	if err != nil {
 		return nil, err
 	}
 
-	list, _, err := lab.Labels.ListLabels(p.ID, &gitlab.ListLabelsOptions{})
+	list, _, err := c.lab.Labels.ListLabels(p.ID, &gitlab.ListLabelsOptions{}, gitlab.WithContext(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -471,8 +743,8 @@ func LabelList(project string) ([]*gitlab.Label, error) {
 }
 
 // ProjectSnippetCreate creates a snippet in a project
-func ProjectSnippetCreate(pid interface{}, opts *gitlab.CreateProjectSnippetOptions) (*gitlab.Snippet, error) {
-	snip, _, err := lab.ProjectSnippets.CreateSnippet(pid, opts)
+func (c *Client) ProjectSnippetCreate(ctx context.Context, pid interface{}, opts *gitlab.CreateProjectSnippetOptions) (*gitlab.Snippet, error) {
+	snip, _, err := c.lab.ProjectSnippets.CreateSnippet(pid, opts, gitlab.WithContext(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -481,44 +753,23 @@ func ProjectSnippetCreate(pid interface{}, opts *gitlab.CreateProjectSnippetOpti
 }
 
 // ProjectSnippetDelete deletes a project snippet
-func ProjectSnippetDelete(pid interface{}, id int) error {
-	_, err := lab.ProjectSnippets.DeleteSnippet(pid, id)
+func (c *Client) ProjectSnippetDelete(ctx context.Context, pid interface{}, id int) error {
+	_, err := c.lab.ProjectSnippets.DeleteSnippet(pid, id, gitlab.WithContext(ctx))
 	return err
 }
 
-// ProjectSnippetList lists snippets on a project
-func ProjectSnippetList(pid interface{}, opts gitlab.ListProjectSnippetsOptions, n int) ([]*gitlab.Snippet, error) {
-	if n == -1 {
-		opts.PerPage = 100
-	}
-	list, resp, err := lab.ProjectSnippets.ListSnippets(pid, &opts)
-	if err != nil {
-		return nil, err
-	}
-	if resp.CurrentPage == resp.TotalPages {
-		return list, nil
-	}
-	opts.Page = resp.NextPage
-	for len(list) < n || n == -1 {
-		if n != -1 {
-			opts.PerPage = n - len(list)
-		}
-		snips, resp, err := lab.ProjectSnippets.ListSnippets(pid, &opts)
-		if err != nil {
-			return nil, err
-		}
-		opts.Page = resp.NextPage
-		list = append(list, snips...)
-		if resp.CurrentPage == resp.TotalPages {
-			break
-		}
-	}
-	return list, nil
+// ProjectSnippetListIter streams the snippets on a project, stopping after n
+// unless n is -1
+func (c *Client) ProjectSnippetListIter(ctx context.Context, pid interface{}, n int) *Iterator {
+	return paginate(ctx, n, func(ctx context.Context, lo gitlab.ListOptions) (interface{}, *gitlab.Response, error) {
+		opts := gitlab.ListProjectSnippetsOptions(lo)
+		return c.lab.ProjectSnippets.ListSnippets(pid, &opts, gitlab.WithContext(ctx))
+	})
 }
 
 // SnippetCreate creates a personal snippet
-func SnippetCreate(opts *gitlab.CreateSnippetOptions) (*gitlab.Snippet, error) {
-	snip, _, err := lab.Snippets.CreateSnippet(opts)
+func (c *Client) SnippetCreate(ctx context.Context, opts *gitlab.CreateSnippetOptions) (*gitlab.Snippet, error) {
+	snip, _, err := c.lab.Snippets.CreateSnippet(opts, gitlab.WithContext(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -527,44 +778,23 @@ func SnippetCreate(opts *gitlab.CreateSnippetOptions) (*gitlab.Snippet, error) {
 }
 
 // SnippetDelete deletes a personal snippet
-func SnippetDelete(id int) error {
-	_, err := lab.Snippets.DeleteSnippet(id)
+func (c *Client) SnippetDelete(ctx context.Context, id int) error {
+	_, err := c.lab.Snippets.DeleteSnippet(id, gitlab.WithContext(ctx))
 	return err
 }
 
-// SnippetList lists snippets on a project
-func SnippetList(opts gitlab.ListSnippetsOptions, n int) ([]*gitlab.Snippet, error) {
-	if n == -1 {
-		opts.PerPage = 100
-	}
-	list, resp, err := lab.Snippets.ListSnippets(&opts)
-	if err != nil {
-		return nil, err
-	}
-	if resp.CurrentPage == resp.TotalPages {
-		return list, nil
-	}
-	opts.Page = resp.NextPage
-	for len(list) < n || n == -1 {
-		if n != -1 {
-			opts.PerPage = n - len(list)
-		}
-		snips, resp, err := lab.Snippets.ListSnippets(&opts)
-		if err != nil {
-			return nil, err
-		}
-		opts.Page = resp.NextPage
-		list = append(list, snips...)
-		if resp.CurrentPage == resp.TotalPages {
-			break
-		}
-	}
-	return list, nil
+// SnippetListIter streams the personal snippets, stopping after n unless n
+// is -1
+func (c *Client) SnippetListIter(ctx context.Context, n int) *Iterator {
+	return paginate(ctx, n, func(ctx context.Context, lo gitlab.ListOptions) (interface{}, *gitlab.Response, error) {
+		opts := gitlab.ListSnippetsOptions(lo)
+		return c.lab.Snippets.ListSnippets(&opts, gitlab.WithContext(ctx))
+	})
 }
 
 // Lint validates .gitlab-ci.yml contents
-func Lint(content string) (bool, error) {
-	lint, _, err := lab.Validate.Lint(content)
+func (c *Client) Lint(ctx context.Context, content string) (bool, error) {
+	lint, _, err := c.lab.Validate.Lint(content, gitlab.WithContext(ctx))
 	if err != nil {
 		return false, err
 	}
@@ -575,8 +805,8 @@ func Lint(content string) (bool, error) {
 }
 
 // ProjectCreate creates a new project on GitLab
-func ProjectCreate(opts *gitlab.CreateProjectOptions) (*gitlab.Project, error) {
-	p, _, err := lab.Projects.CreateProject(opts)
+func (c *Client) ProjectCreate(ctx context.Context, opts *gitlab.CreateProjectOptions) (*gitlab.Project, error) {
+	p, _, err := c.lab.Projects.CreateProject(opts, gitlab.WithContext(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -584,88 +814,162 @@ func ProjectCreate(opts *gitlab.CreateProjectOptions) (*gitlab.Project, error) {
 }
 
 // ProjectDelete creates a new project on GitLab
-func ProjectDelete(pid interface{}) error {
-	_, err := lab.Projects.DeleteProject(pid)
+func (c *Client) ProjectDelete(ctx context.Context, pid interface{}) error {
+	_, err := c.lab.Projects.DeleteProject(pid, gitlab.WithContext(ctx))
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-// ProjectList gets a list of projects on GitLab
-func ProjectList(opts gitlab.ListProjectsOptions, n int) ([]*gitlab.Project, error) {
-	list, resp, err := lab.Projects.ListProjects(&opts)
+// ProjectListIter streams the projects on GitLab, stopping after n unless n
+// is -1
+func (c *Client) ProjectListIter(ctx context.Context, opts gitlab.ListProjectsOptions, n int) *Iterator {
+	return paginate(ctx, n, func(ctx context.Context, lo gitlab.ListOptions) (interface{}, *gitlab.Response, error) {
+		opts := opts
+		opts.ListOptions = lo
+		return c.lab.Projects.ListProjects(&opts, gitlab.WithContext(ctx))
+	})
+}
+
+// Pipeline is a pipeline along with what started it, which go-gitlab
+// predates
+type Pipeline struct {
+	gitlab.Pipeline
+	// Source is the event which started the pipeline, such as "push" or
+	// "merge_request_event"
+	Source string `json:"source"`
+	WebURL string `json:"web_url"`
+}
+
+// projectPath returns pid, the ID or path of a project, for use in the path
+// of an API request
+func projectPath(pid interface{}) (string, error) {
+	switch id := pid.(type) {
+	case int:
+		return strconv.Itoa(id), nil
+	case string:
+		return url.QueryEscape(id), nil
+	}
+	return "", errors.Errorf("invalid project %v, expected an ID or a path", pid)
+}
+
+// CIPipeline retrieves a pipeline of a project
+func (c *Client) CIPipeline(ctx context.Context, pid interface{}, id int) (*Pipeline, error) {
+	project, err := projectPath(pid)
 	if err != nil {
 		return nil, err
 	}
-	if resp.CurrentPage == resp.TotalPages {
-		return list, nil
+	u := fmt.Sprintf("projects/%s/pipelines/%d", project, id)
+	req, err := c.lab.NewRequest("GET", u, nil, []gitlab.OptionFunc{gitlab.WithContext(ctx)})
+	if err != nil {
+		return nil, err
 	}
-	opts.Page = resp.NextPage
-	for len(list) < n || n == -1 {
-		if n != -1 {
-			opts.PerPage = n - len(list)
-		}
-		projects, resp, err := lab.Projects.ListProjects(&opts)
+	pipeline := new(Pipeline)
+	if _, err := c.lab.Do(req, pipeline); err != nil {
+		return nil, err
+	}
+	return pipeline, nil
+}
+
+// CIPipelineListIter streams the pipelines of a project, the newest first,
+// stopping after n unless n is -1
+func (c *Client) CIPipelineListIter(ctx context.Context, pid interface{}, opts gitlab.ListProjectPipelinesOptions, n int) *Iterator {
+	project, err := projectPath(pid)
+	if err != nil {
+		return errIterator(err)
+	}
+	u := fmt.Sprintf("projects/%s/pipelines", project)
+	return paginate(ctx, n, func(ctx context.Context, lo gitlab.ListOptions) (interface{}, *gitlab.Response, error) {
+		opts := opts
+		opts.ListOptions = lo
+		req, err := c.lab.NewRequest("GET", u, &opts, []gitlab.OptionFunc{gitlab.WithContext(ctx)})
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		opts.Page = resp.NextPage
-		list = append(list, projects...)
-		if resp.CurrentPage == resp.TotalPages {
-			break
+		var pipelines []*Pipeline
+		resp, err := c.lab.Do(req, &pipelines)
+		if err != nil {
+			return nil, resp, err
 		}
-	}
-	return list, nil
+		return pipelines, resp, nil
+	})
 }
 
-// CIJobs returns a list of jobs in a pipeline for a given sha. The jobs are
-// returned sorted by their CreatedAt time
-func CIJobs(pid interface{}, branch string) ([]*gitlab.Job, error) {
-	pipelines, _, err := lab.Pipelines.ListProjectPipelines(pid, &gitlab.ListProjectPipelinesOptions{
-		Ref: gitlab.String(branch),
-	})
-	if len(pipelines) == 0 || err != nil {
-		return nil, err
-	}
-	target := pipelines[0].ID
-	opts := &gitlab.ListJobsOptions{
-		ListOptions: gitlab.ListOptions{
-			PerPage: 500,
-		},
+// CILatestPipeline returns the ID of the newest pipeline of a project
+// matching opts, such as the one of a ref or commit, or 0 when there is none
+func (c *Client) CILatestPipeline(ctx context.Context, pid interface{}, opts gitlab.ListProjectPipelinesOptions) (int, error) {
+	it := c.CIPipelineListIter(ctx, pid, opts, 1)
+	defer it.Close()
+	if it.Next() {
+		return it.Value().(*Pipeline).ID, nil
 	}
-	list, resp, err := lab.Jobs.ListPipelineJobs(pid, target, opts)
+	return 0, it.Err()
+}
+
+// CIMRPipelineListIter streams the pipelines of a merge request, the newest
+// first, stopping after n unless n is -1
+func (c *Client) CIMRPipelineListIter(ctx context.Context, pid interface{}, mrNum int, n int) *Iterator {
+	project, err := projectPath(pid)
 	if err != nil {
-		return nil, err
+		return errIterator(err)
 	}
-	if resp.CurrentPage == resp.TotalPages {
-		return list, nil
-	}
-	opts.Page = resp.NextPage
-	for {
-		jobs, resp, err := lab.Jobs.ListPipelineJobs(pid, target, opts)
+	u := fmt.Sprintf("projects/%s/merge_requests/%d/pipelines", project, mrNum)
+	return paginate(ctx, n, func(ctx context.Context, lo gitlab.ListOptions) (interface{}, *gitlab.Response, error) {
+		req, err := c.lab.NewRequest("GET", u, &lo, []gitlab.OptionFunc{gitlab.WithContext(ctx)})
 		if err != nil {
-			return nil, err
+			return nil, nil, err
+		}
+		var pipelines []*Pipeline
+		resp, err := c.lab.Do(req, &pipelines)
+		if err != nil {
+			return nil, resp, err
 		}
-		opts.Page = resp.NextPage
-		list = append(list, jobs...)
-		if resp.CurrentPage == resp.TotalPages {
-			break
+		return pipelines, resp, nil
+	})
+}
+
+// CIJobs returns the jobs of a pipeline, sorted by their CreatedAt time, the
+// oldest first. GitLab lists the newest first.
+func (c *Client) CIJobs(ctx context.Context, pid interface{}, pipelineID int) ([]*gitlab.Job, error) {
+	it := paginate(ctx, -1, func(ctx context.Context, lo gitlab.ListOptions) (interface{}, *gitlab.Response, error) {
+		return c.lab.Jobs.ListPipelineJobs(pid, pipelineID, &gitlab.ListJobsOptions{ListOptions: lo}, gitlab.WithContext(ctx))
+	})
+	defer it.Close()
+	list := []*gitlab.Job{}
+	for it.Next() {
+		list = append(list, it.Value().(*gitlab.Job))
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	createdAt := func(j *gitlab.Job) time.Time {
+		if j.CreatedAt == nil {
+			return time.Time{}
 		}
+		return *j.CreatedAt
 	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := createdAt(list[i]), createdAt(list[j])
+		if a.Equal(b) {
+			// Jobs created together keep the order of their IDs
+			return list[i].ID < list[j].ID
+		}
+		return a.Before(b)
+	})
 	return list, nil
 }
 
-// CITrace searches by name for a job and returns its trace file. The trace is
-// static so may only be a portion of the logs if the job is till running. If
-// no name is provided job is picked using the first available:
+// CIJob searches by name for a job of a pipeline. If no name is provided, or
+// no job has that name, the job is picked using the first available:
 // 1. Last Running Job
 // 2. First Pending Job
 // 3. Last Job in Pipeline
-func CITrace(pid interface{}, branch, name string) (io.Reader, *gitlab.Job, error) {
-	jobs, err := CIJobs(pid, branch)
+// A nil job is returned when the pipeline has no jobs.
+func (c *Client) CIJob(ctx context.Context, pid interface{}, pipelineID int, name string) (*gitlab.Job, error) {
+	jobs, err := c.CIJobs(ctx, pid, pipelineID)
 	if len(jobs) == 0 || err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 	var (
 		job          *gitlab.Job
@@ -694,7 +998,18 @@ func CITrace(pid interface{}, branch, name string) (io.Reader, *gitlab.Job, erro
 	if job == nil {
 		job = jobs[len(jobs)-1]
 	}
-	r, _, err := lab.Jobs.GetTraceFile(pid, job.ID)
+	return job, nil
+}
+
+// CITrace searches by name for a job and returns its trace file. The trace is
+// static so may only be a portion of the logs if the job is till running. The
+// job is picked like CIJob does.
+func (c *Client) CITrace(ctx context.Context, pid interface{}, pipelineID int, name string) (io.Reader, *gitlab.Job, error) {
+	job, err := c.CIJob(ctx, pid, pipelineID, name)
+	if job == nil || err != nil {
+		return nil, nil, err
+	}
+	r, _, err := c.lab.Jobs.GetTraceFile(pid, job.ID, gitlab.WithContext(ctx))
 	if err != nil {
 		return nil, job, err
 	}
@@ -702,21 +1017,227 @@ func CITrace(pid interface{}, branch, name string) (io.Reader, *gitlab.Job, erro
 	return r, job, err
 }
 
+// CIArtifacts writes the artifacts archive of a job to w
+func (c *Client) CIArtifacts(ctx context.Context, pid interface{}, jobID int, w io.Writer) error {
+	project, err := projectPath(pid)
+	if err != nil {
+		return err
+	}
+	u := fmt.Sprintf("projects/%s/jobs/%d/artifacts", project, jobID)
+	return c.download(ctx, u, nil, w)
+}
+
+// CILatestArtifacts writes the artifacts archive of the job with the given
+// name in the latest successful pipeline of a ref to w
+func (c *Client) CILatestArtifacts(ctx context.Context, pid interface{}, ref, job string, w io.Writer) error {
+	project, err := projectPath(pid)
+	if err != nil {
+		return err
+	}
+	// Jobs.DownloadArtifactsFile puts the job query in the path, where it
+	// gets escaped, so build the request here
+	u := fmt.Sprintf("projects/%s/jobs/artifacts/%s/download", project, url.PathEscape(ref))
+	opt := struct {
+		Job string `url:"job"`
+	}{job}
+	return c.download(ctx, u, &opt, w)
+}
+
+// download streams the response to a GET of path to w. go-gitlab's download
+// methods buffer the whole response in memory, which artifacts may not fit.
+func (c *Client) download(ctx context.Context, path string, opt interface{}, w io.Writer) error {
+	req, err := c.lab.NewRequest("GET", path, opt, []gitlab.OptionFunc{gitlab.WithContext(ctx)})
+	if err != nil {
+		return err
+	}
+	_, err = c.lab.Do(req, w)
+	return err
+}
+
+// CIVariable is a CI/CD variable stored in a project or a group. go-gitlab
+// predates the masked, variable type and environment scope attributes.
+type CIVariable struct {
+	Key              string `json:"key"`
+	Value            string `json:"value"`
+	VariableType     string `json:"variable_type"`
+	Protected        bool   `json:"protected"`
+	Masked           bool   `json:"masked"`
+	EnvironmentScope string `json:"environment_scope"`
+}
+
+// CIVariableOwner is the project or the group CI/CD variables are stored in
+type CIVariableOwner struct {
+	// ID is the ID or the path of the project or group
+	ID    interface{}
+	Group bool
+}
+
+// path returns the path of the variables of o
+func (o CIVariableOwner) path() (string, error) {
+	id, err := projectPath(o.ID)
+	if err != nil {
+		return "", err
+	}
+	if o.Group {
+		return "groups/" + id + "/variables", nil
+	}
+	return "projects/" + id + "/variables", nil
+}
+
+// ciVariableOptions is sent when creating and updating variables. The scope
+// filter picks among variables with the same key, and is sent in the query
+// string of GET and DELETE requests and in the body of the others.
+type ciVariableOptions struct {
+	Filter *ciVariableFilter `url:"filter,omitempty" json:"filter,omitempty"`
+
+	Key              string `url:"-" json:"key,omitempty"`
+	Value            string `url:"-" json:"value"`
+	VariableType     string `url:"-" json:"variable_type,omitempty"`
+	Protected        bool   `url:"-" json:"protected"`
+	Masked           bool   `url:"-" json:"masked"`
+	EnvironmentScope string `url:"-" json:"environment_scope,omitempty"`
+}
+
+type ciVariableFilter struct {
+	EnvironmentScope string `url:"environment_scope" json:"environment_scope"`
+}
+
+// scopeFilter returns the filter picking the variable with the environment
+// scope env, or nil to pick the first variable with a key
+func scopeFilter(env string) *ciVariableFilter {
+	if env == "" {
+		return nil
+	}
+	return &ciVariableFilter{EnvironmentScope: env}
+}
+
+// CIVariableListIter streams the CI/CD variables of a project or group,
+// stopping after n unless n is -1
+func (c *Client) CIVariableListIter(ctx context.Context, owner CIVariableOwner, n int) *Iterator {
+	u, err := owner.path()
+	if err != nil {
+		return errIterator(err)
+	}
+	return paginate(ctx, n, func(ctx context.Context, lo gitlab.ListOptions) (interface{}, *gitlab.Response, error) {
+		req, err := c.lab.NewRequest("GET", u, &lo, []gitlab.OptionFunc{gitlab.WithContext(ctx)})
+		if err != nil {
+			return nil, nil, err
+		}
+		var vars []*CIVariable
+		resp, err := c.lab.Do(req, &vars)
+		if err != nil {
+			return nil, resp, err
+		}
+		return vars, resp, nil
+	})
+}
+
+// CIVariables lists all the CI/CD variables of a project or group
+func (c *Client) CIVariables(ctx context.Context, owner CIVariableOwner) ([]*CIVariable, error) {
+	it := c.CIVariableListIter(ctx, owner, -1)
+	defer it.Close()
+	list := []*CIVariable{}
+	for it.Next() {
+		list = append(list, it.Value().(*CIVariable))
+	}
+	return list, it.Err()
+}
+
+// CIVariable retrieves the CI/CD variable key of a project or group with the
+// environment scope env, or the first one with that key when env is empty.
+// A nil variable is returned when there is none.
+func (c *Client) CIVariable(ctx context.Context, owner CIVariableOwner, key, env string) (*CIVariable, error) {
+	u, err := owner.path()
+	if err != nil {
+		return nil, err
+	}
+	opt := ciVariableOptions{Filter: scopeFilter(env)}
+	req, err := c.lab.NewRequest("GET", u+"/"+url.PathEscape(key), &opt, []gitlab.OptionFunc{gitlab.WithContext(ctx)})
+	if err != nil {
+		return nil, err
+	}
+	var v CIVariable
+	if resp, err := c.lab.Do(req, &v); err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &v, nil
+}
+
+// CISetVariable updates the CI/CD variable of a project or group with the key
+// and environment scope of v, or creates it when there is none. It returns
+// whether the variable was created.
+func (c *Client) CISetVariable(ctx context.Context, owner CIVariableOwner, v *CIVariable) (bool, error) {
+	u, err := owner.path()
+	if err != nil {
+		return false, err
+	}
+	opt := ciVariableOptions{
+		Filter:           scopeFilter(v.EnvironmentScope),
+		Value:            v.Value,
+		VariableType:     v.VariableType,
+		Protected:        v.Protected,
+		Masked:           v.Masked,
+		EnvironmentScope: v.EnvironmentScope,
+	}
+	req, err := c.lab.NewRequest("PUT", u+"/"+url.PathEscape(v.Key), &opt, []gitlab.OptionFunc{gitlab.WithContext(ctx)})
+	if err != nil {
+		return false, err
+	}
+	resp, err := c.lab.Do(req, nil)
+	if err == nil {
+		return false, nil
+	}
+	if resp == nil || resp.StatusCode != http.StatusNotFound {
+		return false, err
+	}
+
+	opt.Filter = nil
+	opt.Key = v.Key
+	req, err = c.lab.NewRequest("POST", u, &opt, []gitlab.OptionFunc{gitlab.WithContext(ctx)})
+	if err != nil {
+		return false, err
+	}
+	if _, err := c.lab.Do(req, nil); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// CIDeleteVariable deletes the CI/CD variable key of a project or group with
+// the environment scope env, or the first one with that key when env is
+// empty
+func (c *Client) CIDeleteVariable(ctx context.Context, owner CIVariableOwner, key, env string) error {
+	u, err := owner.path()
+	if err != nil {
+		return err
+	}
+	opt := ciVariableOptions{Filter: scopeFilter(env)}
+	req, err := c.lab.NewRequest("DELETE", u+"/"+url.PathEscape(key), &opt, []gitlab.OptionFunc{gitlab.WithContext(ctx)})
+	if err != nil {
+		return err
+	}
+	_, err = c.lab.Do(req, nil)
+	return err
+}
+
 // CIPlayOrRetry runs a job either by playing it for the first time or by
 // retrying it based on the currently known job state
-func CIPlayOrRetry(pid interface{}, jobID int, status string) (*gitlab.Job, error) {
+func (c *Client) CIPlayOrRetry(ctx context.Context, pid interface{}, jobID int, status string) (*gitlab.Job, error) {
 	switch status {
 	case "pending", "running":
 		return nil, nil
 	case "manual":
-		j, _, err := lab.Jobs.PlayJob(pid, jobID)
+		j, _, err := c.lab.Jobs.PlayJob(pid, jobID, gitlab.WithContext(ctx))
 		if err != nil {
 			return nil, err
 		}
 		return j, nil
 	default:
 
-		j, _, err := lab.Jobs.RetryJob(pid, jobID)
+		j, _, err := c.lab.Jobs.RetryJob(pid, jobID, gitlab.WithContext(ctx))
 		if err != nil {
 			return nil, err
 		}
@@ -726,8 +1247,8 @@ func CIPlayOrRetry(pid interface{}, jobID int, status string) (*gitlab.Job, erro
 }
 
 // CICancel cancels a job for a given project by its ID.
-func CICancel(pid interface{}, jobID int) (*gitlab.Job, error) {
-	j, _, err := lab.Jobs.CancelJob(pid, jobID)
+func (c *Client) CICancel(ctx context.Context, pid interface{}, jobID int) (*gitlab.Job, error) {
+	j, _, err := c.lab.Jobs.CancelJob(pid, jobID, gitlab.WithContext(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -735,8 +1256,8 @@ func CICancel(pid interface{}, jobID int) (*gitlab.Job, error) {
 }
 
 // CICreate creates a pipeline for given ref
-func CICreate(pid interface{}, opts *gitlab.CreatePipelineOptions) (*gitlab.Pipeline, error) {
-	p, _, err := lab.Pipelines.CreatePipeline(pid, opts)
+func (c *Client) CICreate(ctx context.Context, pid interface{}, opts *gitlab.CreatePipelineOptions) (*gitlab.Pipeline, error) {
+	p, _, err := c.lab.Pipelines.CreatePipeline(pid, opts, gitlab.WithContext(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -744,8 +1265,8 @@ func CICreate(pid interface{}, opts *gitlab.CreatePipelineOptions) (*gitlab.Pipe
 }
 
 // CITrigger triggers a pipeline for given ref
-func CITrigger(pid interface{}, opts gitlab.RunPipelineTriggerOptions) (*gitlab.Pipeline, error) {
-	p, _, err := lab.PipelineTriggers.RunPipelineTrigger(pid, &opts)
+func (c *Client) CITrigger(ctx context.Context, pid interface{}, opts gitlab.RunPipelineTriggerOptions) (*gitlab.Pipeline, error) {
+	p, _, err := c.lab.PipelineTriggers.RunPipelineTrigger(pid, &opts, gitlab.WithContext(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -754,12 +1275,98 @@ func CITrigger(pid interface{}, opts gitlab.RunPipelineTriggerOptions) (*gitlab.
 
 // UserIDFromUsername returns the associated Users ID in GitLab. This is useful
 // for API calls that allow you to reference a user, but only by ID.
-func UserIDFromUsername(username string) (int, error) {
-	us, _, err := lab.Users.ListUsers(&gitlab.ListUsersOptions{
+func (c *Client) UserIDFromUsername(ctx context.Context, username string) (int, error) {
+	us, _, err := c.lab.Users.ListUsers(&gitlab.ListUsersOptions{
 		Username: gitlab.String(username),
-	})
+	}, gitlab.WithContext(ctx))
 	if err != nil || len(us) == 0 {
 		return -1, err
 	}
 	return us[0].ID, nil
 }
+
+// ProjectMembers lists the members of a project, including those inherited
+// from its groups
+func (c *Client) ProjectMembers(ctx context.Context, pid interface{}) ([]*gitlab.ProjectMember, error) {
+	it := paginate(ctx, -1, func(ctx context.Context, lo gitlab.ListOptions) (interface{}, *gitlab.Response, error) {
+		return c.lab.ProjectMembers.ListAllProjectMembers(pid, &gitlab.ListProjectMembersOptions{ListOptions: lo}, gitlab.WithContext(ctx))
+	})
+	defer it.Close()
+	list := []*gitlab.ProjectMember{}
+	for it.Next() {
+		list = append(list, it.Value().(*gitlab.ProjectMember))
+	}
+	return list, it.Err()
+}
+
+// GroupMembers lists the members of a group, including those inherited from
+// its parent groups. It returns nil without an error when there is no such
+// group.
+func (c *Client) GroupMembers(ctx context.Context, group string) ([]*gitlab.GroupMember, error) {
+	it := paginate(ctx, -1, func(ctx context.Context, lo gitlab.ListOptions) (interface{}, *gitlab.Response, error) {
+		return c.lab.Groups.ListAllGroupMembers(group, &gitlab.ListGroupMembersOptions{ListOptions: lo}, gitlab.WithContext(ctx))
+	})
+	defer it.Close()
+	list := []*gitlab.GroupMember{}
+	for it.Next() {
+		list = append(list, it.Value().(*gitlab.GroupMember))
+	}
+	if err, ok := it.Err().(*gitlab.ErrorResponse); ok && err.Response.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	return list, it.Err()
+}
+
+// APIRequest makes a request to path, such as projects/1/issues, and returns
+// the raw response body. query is added to the URL and body, when not nil, is
+// sent as JSON. The response is never served from the cache.
+func (c *Client) APIRequest(ctx context.Context, method, path string, query url.Values, body []byte) ([]byte, *gitlab.Response, error) {
+	req, err := c.lab.NewRequest(method, path, nil, []gitlab.OptionFunc{gitlab.WithContext(withoutCache(ctx))})
+	if err != nil {
+		return nil, nil, err
+	}
+	req.URL.RawQuery = query.Encode()
+	// NewRequest sends an empty body as null, so always set our own
+	req.Body, req.GetBody, req.ContentLength = nil, nil, 0
+	req.Header.Del("Content-Type")
+	if body != nil {
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(body)), nil
+		}
+		req.ContentLength = int64(len(body))
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	var buf bytes.Buffer
+	resp, err := c.lab.Do(req, &buf)
+	if err != nil {
+		return nil, resp, err
+	}
+	return buf.Bytes(), resp, nil
+}
+
+// APIRequestIter streams the items of the JSON list at path, following its
+// pages, as json.RawMessage values. The pages are as large as allowed unless
+// query sets per_page.
+func (c *Client) APIRequestIter(ctx context.Context, path string, query url.Values) *Iterator {
+	return paginate(ctx, -1, func(ctx context.Context, lo gitlab.ListOptions) (interface{}, *gitlab.Response, error) {
+		q := url.Values{}
+		for k, v := range query {
+			q[k] = v
+		}
+		q.Set("page", strconv.Itoa(lo.Page))
+		if q.Get("per_page") == "" {
+			q.Set("per_page", strconv.Itoa(lo.PerPage))
+		}
+		b, resp, err := c.APIRequest(ctx, http.MethodGet, path, q, nil)
+		if err != nil {
+			return nil, resp, err
+		}
+		var list []json.RawMessage
+		if err := json.Unmarshal(b, &list); err != nil {
+			return nil, resp, errors.Errorf("%s is not a list, it can't be paginated", path)
+		}
+		return list, resp, nil
+	})
+}
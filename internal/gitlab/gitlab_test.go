@@ -1,8 +1,12 @@
 package gitlab
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -14,8 +18,11 @@ import (
 	"github.com/stretchr/testify/require"
 	gitlab "github.com/xanzy/go-gitlab"
 	"github.com/zaquestion/lab/internal/copy"
+	"github.com/zaquestion/lab/internal/gitlabtest"
 )
 
+var client *Client
+
 func TestMain(m *testing.M) {
 	rand.Seed(time.Now().UnixNano())
 	repo := copyTestRepo()
@@ -24,30 +31,45 @@ func TestMain(m *testing.M) {
 		log.Fatal(err)
 	}
 
-	viper.SetConfigName("lab")
-	viper.SetConfigType("hcl")
-	viper.AddConfigPath(".")
-	err = viper.ReadInConfig()
-	if err != nil {
-		log.Fatal(err)
+	// Run against the fake GitLab unless asked to use the live
+	// gitlab.com test projects configured in testdata/lab.hcl
+	var srv *gitlabtest.Server
+	host, token := "", gitlabtest.Token
+	if os.Getenv("LAB_TEST_LIVE") == "" {
+		srv = gitlabtest.NewServer()
+		gitlabtest.Seed(srv)
+		host = srv.URL
+	} else {
+		viper.SetConfigName("lab")
+		viper.SetConfigType("hcl")
+		viper.AddConfigPath(".")
+		err = viper.ReadInConfig()
+		if err != nil {
+			log.Fatal(err)
+		}
+		c := viper.AllSettings()["core"]
+		config := c.([]map[string]interface{})[0]
+		host, token = config["host"].(string), config["token"].(string)
 	}
-	c := viper.AllSettings()["core"]
-	config := c.([]map[string]interface{})[0]
 
-	lab := gitlab.NewClient(nil, config["token"].(string))
-	lab.SetBaseURL(config["host"].(string) + "/api/v4")
+	lab := gitlab.NewClient(nil, token)
+	lab.SetBaseURL(host + "/api/v4")
 	u, _, err := lab.Users.CurrentUser()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	Init(
-		config["host"].(string),
-		u.Username,
-		config["token"].(string))
+	client = NewClient(Config{
+		Host:  host,
+		User:  u.Username,
+		Token: token,
+	})
 
 	code := m.Run()
 
+	if srv != nil {
+		srv.Close()
+	}
 	if err := os.Chdir("../"); err != nil {
 		log.Fatalf("Error chdir to ../: %s", err)
 	}
@@ -58,14 +80,58 @@ func TestMain(m *testing.M) {
 }
 
 func TestGetProject(t *testing.T) {
-	project, err := GetProject("lab-testing/test")
+	project, err := client.GetProject(context.Background(), "lab-testing/test")
 	require.NoError(t, err)
 	assert.Equal(t, 5694926, project.ID, "Expected 'lab-testing/test' to be project 5694926")
 }
 
+func TestNewClientMultipleHosts(t *testing.T) {
+	newServer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "/api/v4/projects/"+name+"%2Ftest", r.URL.EscapedPath())
+			fmt.Fprintf(w, `{"id": 1, "path_with_namespace": "%s/test"}`, name)
+		}))
+	}
+	one, two := newServer("one"), newServer("two")
+	defer one.Close()
+	defer two.Close()
+
+	c1 := NewClient(Config{Host: one.URL + "/", User: "one"})
+	c2 := NewClient(Config{Host: two.URL, User: "two"})
+	require.Equal(t, one.URL, c1.Host())
+
+	p1, err := c1.FindProject(context.Background(), "test")
+	require.NoError(t, err)
+	p2, err := c2.FindProject(context.Background(), "test")
+	require.NoError(t, err)
+	assert.Equal(t, "one/test", p1.PathWithNamespace)
+	assert.Equal(t, "two/test", p2.PathWithNamespace)
+}
+
+func TestContextDeadline(t *testing.T) {
+	hung := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-hung
+	}))
+	defer srv.Close()
+	defer close(hung)
+
+	c := NewClient(Config{Host: srv.URL, User: "lab-testing"})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := c.GetProject(ctx, "lab-testing/test")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), context.DeadlineExceeded.Error())
+	assert.True(t, time.Since(start) < 5*time.Second, "Expected the hung request to be abandoned")
+}
+
 func TestUser(t *testing.T) {
-	// Should get set by Init() after TestMain()
-	require.Equal(t, "lab-testing", User())
+	// Should get set by NewClient() in TestMain()
+	user, err := client.User(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "lab-testing", user)
 }
 
 func TestLoadGitLabTmplMR(t *testing.T) {
@@ -108,7 +174,7 @@ func TestLint(t *testing.T) {
 		t.Run(test.desc, func(t *testing.T) {
 			test := test
 			t.Parallel()
-			ok, _ := Lint(test.content)
+			ok, _ := client.Lint(context.Background(), test.content)
 			require.Equal(t, test.expected, ok)
 		})
 	}
@@ -140,7 +206,7 @@ func TestBranchPushed(t *testing.T) {
 		t.Run(test.desc, func(t *testing.T) {
 			test := test
 			t.Parallel()
-			ok := BranchPushed(4181224, test.branch)
+			ok := client.BranchPushed(context.Background(), 4181224, test.branch)
 			require.Equal(t, test.expected, ok)
 		})
 	}
@@ -0,0 +1,190 @@
+package gitlab
+
+import (
+	"context"
+	"reflect"
+
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+const (
+	// maxPerPage is the largest page size allowed by the API
+	maxPerPage = 100
+	// pageWorkers is the number of pages fetched at once when the number
+	// of pages is known
+	pageWorkers = 4
+)
+
+// pageFunc fetches a single page of a list, returning the items of the page
+// as a slice
+type pageFunc func(ctx context.Context, opt gitlab.ListOptions) (interface{}, *gitlab.Response, error)
+
+// Iterator streams the items of a paginated list as their pages arrive:
+//
+//	it := client.MRListIter(ctx, project, opts, -1)
+//	defer it.Close()
+//	for it.Next() {
+//		mr := it.Value().(*gitlab.MergeRequest)
+//	}
+//	if err := it.Err(); err != nil {
+//		return err
+//	}
+type Iterator struct {
+	items  chan interface{}
+	cancel context.CancelFunc
+	value  interface{}
+	// err is set before items is closed
+	err error
+}
+
+// Next advances to the next item, returning false once the list is done or
+// has failed
+func (it *Iterator) Next() bool {
+	v, ok := <-it.items
+	it.value = v
+	return ok
+}
+
+// Value returns the item Next advanced to
+func (it *Iterator) Value() interface{} {
+	return it.value
+}
+
+// Err returns the error which stopped the list early, if any. It must only be
+// called once Next has returned false.
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+// Close stops fetching pages. It is safe to call Close after the list is done.
+func (it *Iterator) Close() {
+	it.cancel()
+	for range it.items {
+	}
+}
+
+// paginate walks the pages of a list using fetch, stopping after n items
+// unless n is -1. The first page tells how many pages there are, and the rest
+// are then fetched a few at a time while the items of the earlier pages are
+// consumed. When GitLab doesn't report the number of pages, as it may not do
+// for very long lists, they are fetched one after the other.
+func paginate(ctx context.Context, n int, fetch pageFunc) *Iterator {
+	ctx, cancel := context.WithCancel(ctx)
+	it := &Iterator{
+		items:  make(chan interface{}),
+		cancel: cancel,
+	}
+	go func() {
+		defer cancel()
+		defer close(it.items)
+		it.err = walkPages(ctx, n, fetch, it.items)
+	}()
+	return it
+}
+
+// errIterator returns an Iterator which fails with err straight away
+func errIterator(err error) *Iterator {
+	it := &Iterator{
+		items:  make(chan interface{}),
+		cancel: func() {},
+		err:    err,
+	}
+	close(it.items)
+	return it
+}
+
+type page struct {
+	items interface{}
+	err   error
+}
+
+func walkPages(ctx context.Context, n int, fetch pageFunc, items chan<- interface{}) error {
+	if n == 0 {
+		return nil
+	}
+	perPage := maxPerPage
+	if n > 0 && n < maxPerPage {
+		perPage = n
+	}
+
+	sent := 0
+	// send streams the items of a page, returning true once n items
+	// have been sent
+	send := func(list interface{}) (bool, error) {
+		v := reflect.ValueOf(list)
+		for i := 0; i < v.Len(); i++ {
+			select {
+			case items <- v.Index(i).Interface():
+			case <-ctx.Done():
+				return true, ctx.Err()
+			}
+			sent++
+			if n > 0 && sent >= n {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	list, resp, err := fetch(ctx, gitlab.ListOptions{Page: 1, PerPage: perPage})
+	if err != nil {
+		return err
+	}
+	if done, err := send(list); done || err != nil {
+		return err
+	}
+
+	if resp.TotalPages == 0 {
+		for next := resp.NextPage; next != 0; next = resp.NextPage {
+			list, resp, err = fetch(ctx, gitlab.ListOptions{Page: next, PerPage: perPage})
+			if err != nil {
+				return err
+			}
+			if done, err := send(list); done || err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	last := resp.TotalPages
+	if n > 0 {
+		if needed := (n + perPage - 1) / perPage; needed < last {
+			last = needed
+		}
+	}
+	// Each page is fetched by its own goroutine. Queuing the pages in
+	// order lets them be sent in order, and the size of the queue limits
+	// how far ahead of the consumer the fetching gets.
+	queue := make(chan chan page, pageWorkers-1)
+	go func() {
+		defer close(queue)
+		for p := 2; p <= last; p++ {
+			result := make(chan page, 1)
+			select {
+			case queue <- result:
+			case <-ctx.Done():
+				return
+			}
+			go func(p int) {
+				list, _, err := fetch(ctx, gitlab.ListOptions{Page: p, PerPage: perPage})
+				result <- page{list, err}
+			}(p)
+		}
+	}()
+	for result := range queue {
+		var pg page
+		select {
+		case pg = <-result:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if pg.err != nil {
+			return pg.err
+		}
+		if done, err := send(pg.items); done || err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package gitlab
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// fakePages returns a pageFunc serving the numbers 1 to total. When
+// totalPages is false the X-Total-Pages header is left out, as GitLab does
+// for very long lists.
+func fakePages(total int, totalPages bool, failPage int) pageFunc {
+	return func(ctx context.Context, opt gitlab.ListOptions) (interface{}, *gitlab.Response, error) {
+		if opt.Page == failPage {
+			return nil, nil, errors.New("page failed")
+		}
+		list := []int{}
+		for i := (opt.Page-1)*opt.PerPage + 1; i <= opt.Page*opt.PerPage && i <= total; i++ {
+			list = append(list, i)
+		}
+		resp := &gitlab.Response{CurrentPage: opt.Page}
+		pages := (total + opt.PerPage - 1) / opt.PerPage
+		if totalPages {
+			resp.TotalPages = pages
+		}
+		if opt.Page < pages {
+			resp.NextPage = opt.Page + 1
+		}
+		return list, resp, nil
+	}
+}
+
+func collectInts(it *Iterator) ([]int, error) {
+	defer it.Close()
+	list := []int{}
+	for it.Next() {
+		list = append(list, it.Value().(int))
+	}
+	return list, it.Err()
+}
+
+func TestPaginate(t *testing.T) {
+	tests := []struct {
+		desc       string
+		total      int
+		n          int
+		totalPages bool
+		expected   int
+	}{
+		{"all", 1050, -1, true, 1050},
+		{"all unknown pages", 1050, -1, false, 1050},
+		{"limit within page", 1050, 10, true, 10},
+		{"limit across pages", 1050, 250, true, 250},
+		{"limit across unknown pages", 1050, 250, false, 250},
+		{"limit beyond total", 42, 250, true, 42},
+		{"empty", 0, -1, true, 0},
+		{"none", 1050, 0, true, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			list, err := collectInts(paginate(context.Background(), test.n, fakePages(test.total, test.totalPages, 0)))
+			require.NoError(t, err)
+			require.Len(t, list, test.expected)
+			for i, v := range list {
+				require.Equal(t, i+1, v, "Expected the items in order")
+			}
+		})
+	}
+}
+
+func TestPaginateError(t *testing.T) {
+	list, err := collectInts(paginate(context.Background(), -1, fakePages(1050, true, 3)))
+	require.EqualError(t, err, "page failed")
+	assert.Len(t, list, 200, "Expected the pages before the failure")
+}
+
+func TestPaginateClose(t *testing.T) {
+	it := paginate(context.Background(), -1, fakePages(1050, true, 0))
+	require.True(t, it.Next())
+	it.Close()
+	assert.False(t, it.Next())
+}
+
+func TestProjectListIter(t *testing.T) {
+	opts := gitlab.ListProjectsOptions{
+		OrderBy: gitlab.String("id"),
+		Sort:    gitlab.String("asc"),
+	}
+	it := client.ProjectListIter(context.Background(), opts, 110)
+	defer it.Close()
+	var ids []int
+	for it.Next() {
+		ids = append(ids, it.Value().(*gitlab.Project).ID)
+	}
+	require.NoError(t, it.Err())
+	require.Len(t, ids, 110)
+	for i := 1; i < len(ids); i++ {
+		require.True(t, ids[i-1] < ids[i], "Expected the projects to be ordered by id")
+	}
+}
@@ -0,0 +1,234 @@
+package gitlab
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Retry settings of the transport used by every Client
+const (
+	maxRetries     = 4
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 30 * time.Second
+	// retryMaxWait is the longest lab waits for GitLab to lift a rate
+	// limit before giving up
+	retryMaxWait = time.Minute
+)
+
+// retryTransport is an http.RoundTripper which retries requests that GitLab
+// rejected as rate limited or failed to serve (502, 503 and 504). Requests
+// which may have been processed are only retried when they are idempotent,
+// waiting longer between each attempt.
+//
+// The Retry-After and RateLimit-* headers of the responses are respected:
+// once the rate limit is used up, requests are held back until it resets.
+type retryTransport struct {
+	base      http.RoundTripper
+	retries   int
+	baseDelay time.Duration
+	maxDelay  time.Duration
+	maxWait   time.Duration
+
+	mu       sync.Mutex
+	resumeAt time.Time
+}
+
+func newRetryTransport(base http.RoundTripper) *retryTransport {
+	return &retryTransport{
+		base:      base,
+		retries:   maxRetries,
+		baseDelay: retryBaseDelay,
+		maxDelay:  retryMaxDelay,
+		maxWait:   retryMaxWait,
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	// The body is read once so that each attempt gets its own reader. The
+	// GetBody of go-gitlab requests returns the reader the first attempt
+	// already consumed.
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	for attempt := 0; ; attempt++ {
+		r := req
+		if body != nil {
+			r = req.Clone(ctx)
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+			r.GetBody = func() (io.ReadCloser, error) {
+				return ioutil.NopCloser(bytes.NewReader(body)), nil
+			}
+		}
+
+		if err := t.waitForRateLimit(ctx); err != nil {
+			return nil, err
+		}
+		resp, err := t.base.RoundTrip(r)
+		t.updateRateLimit(resp)
+
+		wait, ok := t.shouldRetry(req, resp, err)
+		if !ok {
+			return resp, err
+		}
+		reason := describeFailure(resp, err)
+		if resp != nil {
+			// Drain the body so the connection can be reused
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if attempt == t.retries {
+			return nil, errors.Errorf("giving up after %d attempts, last response: %s", attempt+1, reason)
+		}
+		if wait > t.maxWait {
+			return nil, errors.Errorf("%s, GitLab asked to retry in %s", reason, wait.Round(time.Second))
+		}
+		if wait <= 0 {
+			wait = t.backoff(attempt)
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// shouldRetry reports whether req should be retried after getting resp or
+// err, and how long GitLab asked to wait before doing so
+func (t *retryTransport) shouldRetry(req *http.Request, resp *http.Response, err error) (time.Duration, bool) {
+	if req.Context().Err() != nil {
+		return 0, false
+	}
+	if err != nil {
+		return 0, isIdempotent(req)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		// Rate limited requests were rejected before being processed,
+		// so they are safe to send again whatever their method
+		return retryAfter(resp), true
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return retryAfter(resp), isIdempotent(req)
+	}
+	return 0, false
+}
+
+// backoff returns the jittered delay before the retry following attempt
+func (t *retryTransport) backoff(attempt int) time.Duration {
+	d := t.baseDelay << uint(attempt)
+	if d > t.maxDelay || d <= 0 {
+		d = t.maxDelay
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// updateRateLimit holds back further requests once resp reports the rate
+// limit as used up
+func (t *retryTransport) updateRateLimit(resp *http.Response) {
+	if resp == nil || resp.Header.Get("RateLimit-Remaining") != "0" {
+		return
+	}
+	reset, ok := rateLimitReset(resp)
+	if !ok {
+		return
+	}
+	t.mu.Lock()
+	if reset.After(t.resumeAt) {
+		t.resumeAt = reset
+	}
+	t.mu.Unlock()
+}
+
+// waitForRateLimit blocks until the rate limit has been reset
+func (t *retryTransport) waitForRateLimit(ctx context.Context) error {
+	t.mu.Lock()
+	wait := time.Until(t.resumeAt)
+	t.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+	if wait > t.maxWait {
+		return errors.Errorf("GitLab rate limit exceeded, it resets in %s", wait.Round(time.Second))
+	}
+	return sleep(ctx, wait)
+}
+
+// retryAfter returns how long resp asks to wait before retrying, using the
+// Retry-After header and falling back to the RateLimit-Reset header
+func retryAfter(resp *http.Response) time.Duration {
+	if v := resp.Header.Get("Retry-After"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil {
+			return time.Duration(secs) * time.Second
+		}
+		if at, err := http.ParseTime(v); err == nil {
+			return time.Until(at)
+		}
+	}
+	if reset, ok := rateLimitReset(resp); ok {
+		return time.Until(reset)
+	}
+	return 0
+}
+
+// rateLimitReset returns when the rate limit reported by resp resets
+func rateLimitReset(resp *http.Response) (time.Time, bool) {
+	if v := resp.Header.Get("RateLimit-Reset"); v != "" {
+		if unix, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return time.Unix(unix, 0), true
+		}
+	}
+	if v := resp.Header.Get("RateLimit-ResetTime"); v != "" {
+		if at, err := http.ParseTime(v); err == nil {
+			return at, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// isIdempotent reports whether sending req twice has the same effect as
+// sending it once. Merging and rebasing merge requests use PUT but act each
+// time they are sent.
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodDelete:
+		return true
+	case http.MethodPut:
+		return !strings.HasSuffix(req.URL.Path, "/merge") && !strings.HasSuffix(req.URL.Path, "/rebase")
+	}
+	return false
+}
+
+func describeFailure(resp *http.Response, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return resp.Status
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
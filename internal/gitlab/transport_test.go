@@ -0,0 +1,172 @@
+package gitlab
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// flakyServer fails the first n requests it receives with status, and
+// counts all the requests made
+func flakyServer(n int, status int, header http.Header) (*httptest.Server, *int32) {
+	var count int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if atomic.AddInt32(&count, 1) <= int32(n) {
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(status)
+			return
+		}
+		w.Write(body)
+	}))
+	return srv, &count
+}
+
+func testTransport() *retryTransport {
+	t := newRetryTransport(http.DefaultTransport)
+	t.baseDelay = time.Millisecond
+	t.maxDelay = 10 * time.Millisecond
+	return t
+}
+
+func TestRetryTransport(t *testing.T) {
+	tests := []struct {
+		desc     string
+		method   string
+		status   int
+		failures int
+		attempts int32
+		err      string
+	}{
+		{"get recovers", "GET", http.StatusServiceUnavailable, 2, 3, ""},
+		{"put recovers", "PUT", http.StatusBadGateway, 1, 2, ""},
+		{"post not retried", "POST", http.StatusServiceUnavailable, 1, 1, ""},
+		{"rate limited post retried", "POST", http.StatusTooManyRequests, 1, 2, ""},
+		{"not found not retried", "GET", http.StatusNotFound, 1, 1, ""},
+		{"retries exhausted", "GET", http.StatusGatewayTimeout, 10, 5, "giving up after 5 attempts, last response: 504 Gateway Timeout"},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			srv, count := flakyServer(test.failures, test.status, nil)
+			defer srv.Close()
+			c := &http.Client{Transport: testTransport()}
+
+			req, err := http.NewRequest(test.method, srv.URL, strings.NewReader("body"))
+			require.NoError(t, err)
+			resp, err := c.Do(req)
+			assert.Equal(t, test.attempts, atomic.LoadInt32(count))
+			if test.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.err)
+				return
+			}
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			if test.attempts > int32(test.failures) {
+				body, _ := ioutil.ReadAll(resp.Body)
+				assert.Equal(t, "body", string(body), "Expected the body to be sent again")
+			}
+		})
+	}
+}
+
+func TestRetryTransportRetryAfter(t *testing.T) {
+	srv, count := flakyServer(1, http.StatusTooManyRequests, http.Header{"Retry-After": {"1"}})
+	defer srv.Close()
+	c := &http.Client{Transport: testTransport()}
+
+	start := time.Now()
+	resp, err := c.Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, int32(2), atomic.LoadInt32(count))
+	assert.True(t, time.Since(start) >= time.Second, "Expected to wait as asked by Retry-After")
+}
+
+func TestRetryTransportRetryAfterTooLong(t *testing.T) {
+	srv, count := flakyServer(1, http.StatusTooManyRequests, http.Header{"Retry-After": {"3600"}})
+	defer srv.Close()
+	c := &http.Client{Transport: testTransport()}
+
+	_, err := c.Get(srv.URL)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "429 Too Many Requests, GitLab asked to retry in 1h0m0s")
+	assert.Equal(t, int32(1), atomic.LoadInt32(count))
+}
+
+func TestRetryTransportRateLimitUsedUp(t *testing.T) {
+	reset := time.Now().Add(time.Hour).Unix()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("RateLimit-Remaining", "0")
+		w.Header().Set("RateLimit-Reset", strconv.FormatInt(reset, 10))
+	}))
+	defer srv.Close()
+	c := &http.Client{Transport: testTransport()}
+
+	resp, err := c.Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	_, err = c.Get(srv.URL)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "GitLab rate limit exceeded")
+}
+
+func TestRetryTransportGitLabClient(t *testing.T) {
+	tests := []struct {
+		desc   string
+		method string
+		status int
+	}{
+		{"rate limited post", "POST", http.StatusTooManyRequests},
+		{"put", "PUT", http.StatusBadGateway},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			srv, count := flakyServer(1, test.status, nil)
+			defer srv.Close()
+			c := gitlab.NewClient(&http.Client{Transport: testTransport()}, "token")
+			require.NoError(t, c.SetBaseURL(srv.URL))
+
+			// go-gitlab's GetBody returns the reader the first attempt
+			// consumed, so the body must be replayed by the transport
+			req, err := c.NewRequest(test.method, "projects/1/issues/1", &gitlab.UpdateIssueOptions{
+				Title: gitlab.String("retried"),
+			}, nil)
+			require.NoError(t, err)
+			var echoed gitlab.UpdateIssueOptions
+			_, err = c.Do(req, &echoed)
+			require.NoError(t, err)
+			assert.Equal(t, int32(2), atomic.LoadInt32(count))
+			require.NotNil(t, echoed.Title)
+			assert.Equal(t, "retried", *echoed.Title)
+		})
+	}
+}
+
+func TestRetryTransportMergeNotRetried(t *testing.T) {
+	for _, action := range []string{"merge", "rebase"} {
+		srv, count := flakyServer(1, http.StatusBadGateway, nil)
+		c := &http.Client{Transport: testTransport()}
+
+		req, err := http.NewRequest("PUT", srv.URL+"/api/v4/projects/1/merge_requests/1/"+action, strings.NewReader("{}"))
+		require.NoError(t, err)
+		resp, err := c.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadGateway, resp.StatusCode, action)
+		assert.Equal(t, int32(1), atomic.LoadInt32(count), action)
+		srv.Close()
+	}
+}
@@ -0,0 +1,386 @@
+package gitlabtest
+
+import (
+	"fmt"
+	"time"
+
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// Token authenticates as the lab-testing user added by Seed
+const Token = "lab-testing-token"
+
+// Commits of the branches in testdata/test.git
+const (
+	masterSHA   = "09b519cba018b707c98fc56e37df15806d89d866"
+	mergedSHA   = "700e056463504690c11d63727bf25a380f303be9"
+	mrtestSHA   = "54fd49a2ac60aeeef5ddc75efecd49f85f7ba9b0"
+	mrtest2SHA  = "9bb1cea7cd73afe2dbea016203b30955128ad477"
+	baseSHA     = "ab0cb3e1a9f657076e788dbcb163361cd6757d94" // merge base of needs/encode and mrtest2
+	encodeSHA   = "381f2b123dd404e8046ea42d5785061aa3b6674b"
+	mergeSHA    = "e31383ebf044d9341196debc25111871138bcb1f" // merge of merged into master
+	pipelineRef = "ci_test_pipeline"
+)
+
+// traceTemplate is the log of every job in the seeded pipeline
+const traceTemplate = `Running with gitlab-runner 10.6.0 (a3543a27)
+  on docker-auto-scale 72989761
+Using Docker executor with image alpine ...
+Fetching changes...
+Checking out 09b519cb as ci_test_pipeline...
+Skipping Git submodules setup
+$ echo "For example you might run an update here or install a build dependency"
+For example you might run an update here or install a build dependency
+$ echo "Or perhaps you might print out some debugging details"
+Or perhaps you might print out some debugging details
+$ echo "%s"
+%s
+$ echo "For example you might do some cleanup here"
+For example you might do some cleanup here
+%s
+`
+
+// Seed adds the users and projects of gitlab.com which lab's integration
+// tests were written against:
+//
+//   - zaquestion/test, with issues, merge requests and a CI pipeline for
+//     the ci_test_pipeline branch, whose build1 job has artifacts, and
+//     another for merge request !1
+//   - lab-testing/test, a fork of zaquestion/test owned by the lab-testing
+//     user, which Token authenticates as
+//   - the lab-testers group, whose members are zaquestion and lab-testing
+//
+// Set WebURL to https://gitlab.com before seeding for the objects to have the
+// same URLs as on gitlab.com.
+func Seed(s *Server) {
+	zaq := s.AddUser("zaquestion", "")
+	lab := s.AddUser("lab-testing", Token)
+	s.AddGroup("lab-testers", zaq, lab)
+
+	upstream := s.AddProject(&gitlab.Project{
+		ID:                4181224,
+		PathWithNamespace: "zaquestion/test",
+	})
+	for name, sha := range map[string]string{
+		"master":       masterSHA,
+		"mrtest":       mrtestSHA,
+		"mrtest2":      mrtest2SHA,
+		"merged":       mergedSHA,
+		"needs/encode": encodeSHA,
+		pipelineRef:    masterSHA,
+	} {
+		s.AddBranch(upstream.ID, name, sha)
+	}
+	for _, l := range []string{"bug", "confirmed", "critical", "documentation", "enhancement"} {
+		s.AddLabel(upstream.ID, l)
+	}
+	milestone := s.AddMilestone(upstream.ID, "1.0")
+
+	// The projects of lab-testing are listed by ID, and the fork of
+	// www-gitlab-com is the oldest
+	s.AddProject(&gitlab.Project{
+		ID:                5694000,
+		PathWithNamespace: "lab-testing/www-gitlab-com",
+	})
+	fork := s.AddProject(&gitlab.Project{
+		ID:                5694926,
+		PathWithNamespace: "lab-testing/test",
+		ForkedFromProject: &gitlab.ForkParent{
+			ID:                upstream.ID,
+			Name:              upstream.Name,
+			Path:              upstream.Path,
+			PathWithNamespace: upstream.PathWithNamespace,
+			WebURL:            upstream.WebURL,
+		},
+	})
+	s.AddBranch(fork.ID, "master", masterSHA)
+	s.AddBranch(fork.ID, "mrtest", mrtestSHA)
+	for _, l := range []string{"bug", "critical"} {
+		s.AddLabel(fork.ID, l)
+	}
+
+	// Enough public projects to need several pages
+	for i := 1; i <= 120; i++ {
+		s.AddProject(&gitlab.Project{PathWithNamespace: fmt.Sprintf("fixtures/project-%d", i)})
+	}
+
+	seedIssues(s, upstream.ID, fork.ID, zaq, lab, milestone)
+	seedMergeRequests(s, upstream.ID, fork.ID, zaq, lab, milestone)
+	seedPipeline(s, upstream.ID)
+	seedMergeRequestPipeline(s, upstream.ID, zaq)
+
+	snip := &gitlab.Snippet{Title: "snippet title", FileName: "snippet.txt"}
+	snip.Author.ID = lab.ID
+	snip.Author.Username = lab.Username
+	s.AddSnippet(fork.ID, snip, "snippet contents")
+}
+
+func seedIssues(s *Server, upstream, fork int, zaq, lab *gitlab.User, milestone *gitlab.Milestone) {
+	due := gitlab.ISOTime(time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC))
+	s.AddIssue(upstream, &gitlab.Issue{
+		Title:     "test issue for lab list",
+		Author:    s.issueAuthor(lab),
+		Assignees: []*gitlab.IssueAssignee{s.issueAssignee(zaq), s.issueAssignee(lab)},
+		Milestone: milestone,
+		DueDate:   &due,
+		Labels:    []string{"bug"},
+		TimeStats: &gitlab.TimeStats{
+			HumanTimeEstimate:   "1w",
+			HumanTotalTimeSpent: "1d",
+			TimeEstimate:        144000,
+			TotalTimeSpent:      28800,
+		},
+	})
+	note := &gitlab.Note{Body: "a comment on the issue"}
+	setNoteAuthor(note, lab)
+	s.AddNote(upstream, Issues, 1, note)
+
+	s.AddIssue(upstream, &gitlab.Issue{
+		Title:  "test issue for lab show",
+		Author: s.issueAuthor(zaq),
+	})
+	s.AddIssue(upstream, &gitlab.Issue{
+		Title:  "test filter labels 1",
+		Author: s.issueAuthor(zaq),
+		Labels: []string{"enhancement"},
+	})
+	s.AddIssue(upstream, &gitlab.Issue{
+		Title:  "test closed issue",
+		Author: s.issueAuthor(zaq),
+		State:  "closed",
+	})
+
+	s.AddIssue(fork, &gitlab.Issue{
+		Title:  "test issue for lab note",
+		Author: s.issueAuthor(lab),
+	})
+}
+
+func seedMergeRequests(s *Server, upstream, fork int, zaq, lab *gitlab.User, milestone *gitlab.Milestone) {
+	mr := &gitlab.MergeRequest{
+		Title:        "Test MR for lab list",
+		Description:  "This MR is to remain open for testing the `lab mr list` functionality",
+		SourceBranch: "mrtest",
+		TargetBranch: "master",
+		Milestone:    milestone,
+		Labels:       []string{"documentation"},
+	}
+	setAuthor(mr, zaq)
+	setAssignee(mr, zaq)
+	s.AddMergeRequest(upstream, mr)
+
+	for _, mr := range []*gitlab.MergeRequest{
+		{Title: "test mr for lab show", SourceBranch: "needs/encode", TargetBranch: "mrtest2", State: "closed"},
+		{Title: "for testings filtering with labels and lists", SourceBranch: "mrtest2", TargetBranch: "mrtest", Labels: []string{"confirmed"}},
+		{Title: "merged merge request", SourceBranch: "merged", TargetBranch: "master", State: "merged"},
+		{Title: "closed mr", SourceBranch: "needs/encode", TargetBranch: "master", State: "closed"},
+	} {
+		setAuthor(mr, zaq)
+		s.AddMergeRequest(upstream, mr)
+	}
+	// !3 is approved and waits on a review from lab-testing
+	filtering := s.mustProject(upstream).mergeRequest(3)
+	filtering.approvalsRequired = 1
+	filtering.approvedBy = []*gitlab.User{zaq}
+	s.setReviewers(filtering, []int{lab.ID})
+
+	seedMergeRequestDiffs(s, upstream, 2)
+	seedMergeRequestDiscussions(s, upstream, 2, zaq, lab)
+
+	mr = &gitlab.MergeRequest{
+		Title:        "test mr for lab note",
+		SourceBranch: "mrtest2",
+		TargetBranch: "master",
+	}
+	setAuthor(mr, zaq)
+	s.AddMergeRequest(fork, mr)
+}
+
+// readmeDiff is the change to README.md merged by the merged branch, which
+// needs/encode was branched from
+const readmeDiff = `@@ -1,4 +1,5 @@
+ Test
+ ==
+ 
+-Repo for testing [lab](https://github.com/zaquestion/lab)
+\ No newline at end of file
++Repo for testing [lab](https://github.com/zaquestion/lab)
++- open issues and merge requests are for supporting integration tests
+\ No newline at end of file
+`
+
+// ciDiff adds the CI configuration in the last commit of needs/encode
+const ciDiff = `@@ -0,0 +1,32 @@
++image: busybox:latest
++
++before_script:
++  - echo "Before script section"
++  - echo "For example you might run an update here or install a build dependency"
++  - echo "Or perhaps you might print out some debugging details"
++   
++after_script:
++  - echo "After script section"
++  - echo "For example you might do some cleanup here"
++   
++build1:
++  stage: build
++  script:
++    - echo "Do your build here"
++   
++test1:
++  stage: test
++  script: 
++    - echo "Do a test here"
++    - echo "For example run a test suite"
++   
++test2:
++  stage: test
++  script: 
++    - echo "Do another parallel test here"
++    - echo "For example run a lint test"
++   
++deploy1:
++  stage: deploy
++  script:
++    - echo "Do your deploy here"
+\ No newline at end of file
+`
+
+// seedMergeRequestDiffs adds the changes of needs/encode to the merge request
+// iid. Its first version was pushed before the CI configuration was added.
+func seedMergeRequestDiffs(s *Server, pid, iid int) {
+	mr := s.mustProject(pid).mergeRequest(iid)
+	mr.DiffRefs.BaseSha = baseSHA
+	mr.DiffRefs.StartSha = mrtest2SHA
+	mr.DiffRefs.HeadSha = encodeSHA
+	readme := &gitlab.Diff{OldPath: "README.md", NewPath: "README.md", AMode: "100644", BMode: "100644", Diff: readmeDiff}
+	ci := &gitlab.Diff{OldPath: ".gitlab-ci.yml", NewPath: ".gitlab-ci.yml", AMode: "0", BMode: "100644", NewFile: true, Diff: ciDiff}
+	s.AddMergeRequestDiff(pid, iid, ci)
+	s.AddMergeRequestDiff(pid, iid, readme)
+
+	s.AddMergeRequestVersion(pid, iid, &gitlab.MergeRequestDiffVersion{
+		BaseCommitSHA:  baseSHA,
+		StartCommitSHA: mrtest2SHA,
+		HeadCommitSHA:  mergeSHA,
+		Diffs:          []*gitlab.Diff{readme},
+	})
+	s.AddMergeRequestVersion(pid, iid, &gitlab.MergeRequestDiffVersion{
+		BaseCommitSHA:  baseSHA,
+		StartCommitSHA: mrtest2SHA,
+		HeadCommitSHA:  encodeSHA,
+		Diffs:          []*gitlab.Diff{ci, readme},
+	})
+	s.AddComparison(pid, mergeSHA, encodeSHA, ci)
+}
+
+// seedMergeRequestDiscussions adds a comment to the merge request iid, along
+// with an open thread on the line added to README.md and a resolved thread
+// on the removed one
+func seedMergeRequestDiscussions(s *Server, pid, iid int, zaq, lab *gitlab.User) {
+	note := &gitlab.Note{Body: "a comment on the merge request"}
+	setNoteAuthor(note, lab)
+	s.AddNote(pid, MergeRequests, iid, note)
+
+	position := func(oldLine, newLine int) *gitlab.NotePosition {
+		return &gitlab.NotePosition{
+			BaseSHA:      baseSHA,
+			StartSHA:     mrtest2SHA,
+			HeadSHA:      encodeSHA,
+			PositionType: "text",
+			OldPath:      "README.md",
+			NewPath:      "README.md",
+			OldLine:      oldLine,
+			NewLine:      newLine,
+		}
+	}
+	question := &gitlab.Note{Body: "which tests?", Position: position(0, 5), Resolvable: true}
+	setNoteAuthor(question, lab)
+	answer := &gitlab.Note{Body: "the ones in the lab repo", Position: position(0, 5), Resolvable: true}
+	setNoteAuthor(answer, zaq)
+	s.AddDiscussion(pid, MergeRequests, iid, question, answer)
+
+	typo := &gitlab.Note{Body: "missing newline", Position: position(4, 0), Resolvable: true, Resolved: true}
+	setNoteAuthor(typo, lab)
+	typo.ResolvedBy.ID = zaq.ID
+	typo.ResolvedBy.Username = zaq.Username
+	typo.ResolvedBy.Name = zaq.Name
+	s.AddDiscussion(pid, MergeRequests, iid, typo)
+}
+
+func seedPipeline(s *Server, upstream int) {
+	pl := s.AddPipeline(upstream, &gitlab.Pipeline{
+		Ref:    pipelineRef,
+		SHA:    masterSHA,
+		Status: "success",
+	})
+	jobs := []struct{ stage, name, status string }{
+		{"build", "build1", "success"},
+		{"build", "build2", "success"},
+		{"build", "build2:fails", "failed"},
+		{"test", "test1", "success"},
+		{"test", "test2", "success"},
+		{"test", "test2:really_a_long_name_for", "success"},
+		{"test", "test2:no_suffix:test", "success"},
+		{"test", "test3", "success"},
+		{"deploy", "deploy1", "success"},
+		{"deploy", "deploy2", "manual"},
+		{"deploy", "deploy3:no_sufix:deploy", "success"},
+		{"deploy", "deploy4", "success"},
+		{"deploy", "deploy5:really_a_long_name_for", "success"},
+		{"deploy", "deploy5", "success"},
+		{"deploy", "deploy6", "success"},
+		{"deploy", "deploy7", "success"},
+		{"deploy", "deploy8", "success"},
+		{"deploy", "deploy9", "success"},
+		{"deploy", "deploy10", "success"},
+	}
+	for _, j := range jobs {
+		result := "Job succeeded"
+		if j.status == "failed" {
+			result = "ERROR: Job failed: exit code 1"
+		}
+		trace := fmt.Sprintf(traceTemplate, "Running "+j.name, "Running "+j.name, result)
+		if j.status == "manual" {
+			trace = ""
+		}
+		job := s.AddJob(upstream, pl.ID, &gitlab.Job{
+			Name:   j.name,
+			Stage:  j.stage,
+			Status: j.status,
+		}, trace)
+		if j.name == "build1" {
+			s.AddArtifacts(upstream, job.ID, map[string]string{
+				"bin/lab":             "lab binary\n",
+				"coverage/index.html": "<h1>coverage</h1>\n",
+				"coverage/lab.out":    "mode: set\n",
+			})
+		}
+	}
+}
+
+// seedMergeRequestPipeline adds a failed merge request pipeline for !1 of
+// the upstream project, which runs on the merge request ref
+func seedMergeRequestPipeline(s *Server, upstream int, zaq *gitlab.User) {
+	started := time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC)
+	finished := started.Add(95 * time.Second)
+	pl := &gitlab.Pipeline{
+		Ref:        "refs/merge-requests/1/head",
+		SHA:        mrtestSHA,
+		Status:     "failed",
+		Duration:   95,
+		StartedAt:  &started,
+		FinishedAt: &finished,
+	}
+	pl.User.ID = zaq.ID
+	pl.User.Username = zaq.Username
+	pl.User.Name = zaq.Name
+	s.AddPipeline(upstream, pl)
+	trace := fmt.Sprintf(traceTemplate, "Running lint", "Running lint", "ERROR: Job failed: exit code 1")
+	s.AddJob(upstream, pl.ID, &gitlab.Job{
+		Name:       "lint",
+		Stage:      "test",
+		Status:     "failed",
+		StartedAt:  &started,
+		FinishedAt: &finished,
+	}, trace)
+}
@@ -0,0 +1,75 @@
+package gitlabtest
+
+import (
+	"net/http"
+	"strconv"
+
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// group holds a group, its members and its CI/CD variables
+type group struct {
+	*gitlab.Group
+	members   []*gitlab.User
+	variables []*variable
+}
+
+// AddGroup adds a group with the given path and members
+func (s *Server) AddGroup(path string, members ...*gitlab.User) *gitlab.Group {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g := &gitlab.Group{
+		ID:       s.nextID(),
+		Name:     path,
+		Path:     path,
+		FullPath: path,
+		WebURL:   s.WebURL + "/groups/" + path,
+	}
+	s.groups = append(s.groups, &group{Group: g, members: members})
+	return g
+}
+
+// group returns the group with the given ID or path, or nil
+func (s *Server) group(id string) *group {
+	n, err := strconv.Atoi(id)
+	for _, g := range s.groups {
+		if (err == nil && g.ID == n) || g.FullPath == id {
+			return g
+		}
+	}
+	return nil
+}
+
+// members returns users as members with the developer access level
+func (s *Server) members(users []*gitlab.User) []*gitlab.GroupMember {
+	list := []*gitlab.GroupMember{}
+	for _, u := range users {
+		list = append(list, &gitlab.GroupMember{
+			ID:          u.ID,
+			Username:    u.Username,
+			Name:        u.Name,
+			State:       u.State,
+			WebURL:      s.WebURL + "/" + u.Username,
+			AccessLevel: gitlab.DeveloperPermissions,
+		})
+	}
+	return list
+}
+
+func (s *Server) listGroupMembers(w http.ResponseWriter, r *request) {
+	g := s.group(r.params["group"])
+	if g == nil {
+		notFound(w, "Group")
+		return
+	}
+	writePage(w, r, s.members(g.members))
+}
+
+// listProjectMembers lists every user, as the fake doesn't restrict access
+// to projects
+func (s *Server) listProjectMembers(w http.ResponseWriter, r *request) {
+	if s.routeProject(w, r) == nil {
+		return
+	}
+	writePage(w, r, s.members(s.users))
+}
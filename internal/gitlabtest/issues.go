@@ -0,0 +1,297 @@
+package gitlabtest
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// AddIssue adds an issue to the project pid, filling in the IDs, URL and
+// state when not set
+func (s *Server) AddIssue(pid int, i *gitlab.Issue) *gitlab.Issue {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.mustProject(pid)
+	s.addIssue(p, i)
+	return i
+}
+
+func (s *Server) addIssue(p *project, i *gitlab.Issue) {
+	i.ID = s.nextID()
+	if i.IID == 0 {
+		for _, other := range p.issues {
+			if other.IID > i.IID {
+				i.IID = other.IID
+			}
+		}
+		i.IID++
+	}
+	i.ProjectID = p.ID
+	if i.State == "" {
+		i.State = "opened"
+	}
+	if i.CreatedAt == nil {
+		i.CreatedAt = now()
+	}
+	if i.UpdatedAt == nil {
+		i.UpdatedAt = i.CreatedAt
+	}
+	if len(i.Assignees) > 0 && i.Assignee == nil {
+		i.Assignee = i.Assignees[0]
+	}
+	if i.TimeStats == nil {
+		i.TimeStats = &gitlab.TimeStats{}
+	}
+	for _, l := range i.Labels {
+		s.label(p, l)
+	}
+	i.WebURL = p.WebURL + "/issues/" + strconv.Itoa(i.IID)
+	p.issues = append(p.issues, i)
+}
+
+// issue returns the issue of p with the given iid, or nil
+func (p *project) issue(iid int) *gitlab.Issue {
+	for _, i := range p.issues {
+		if i.IID == iid {
+			return i
+		}
+	}
+	return nil
+}
+
+// routeIssue returns the issue of the :id and :iid route parameters,
+// writing a 404 when it doesn't exist
+func (s *Server) routeIssue(w http.ResponseWriter, r *request) (*project, *gitlab.Issue) {
+	p := s.routeProject(w, r)
+	if p == nil {
+		return nil, nil
+	}
+	iid, _ := strconv.Atoi(r.params["iid"])
+	i := p.issue(iid)
+	if i == nil {
+		notFound(w, "Issue")
+		return nil, nil
+	}
+	return p, i
+}
+
+func (s *Server) listIssues(w http.ResponseWriter, r *request) {
+	p := s.routeProject(w, r)
+	if p == nil {
+		return
+	}
+	q := r.URL.Query()
+	list := []*gitlab.Issue{}
+	for _, i := range p.issues {
+		if state := q.Get("state"); state != "" && state != "all" && i.State != state {
+			continue
+		}
+		if !hasLabels(i.Labels, splitList(q.Get("labels"))) {
+			continue
+		}
+		if v := q.Get("milestone"); v != "" && (i.Milestone == nil || i.Milestone.Title != v) {
+			continue
+		}
+		if v := q.Get("search"); v != "" && !containsFold(i.Title, v) && !containsFold(i.Description, v) {
+			continue
+		}
+		if v := q.Get("author_id"); v != "" && (i.Author == nil || strconv.Itoa(i.Author.ID) != v) {
+			continue
+		}
+		if v := q.Get("assignee_id"); v != "" && !issueAssignedTo(i, v) {
+			continue
+		}
+		list = append(list, i)
+	}
+
+	less := func(a, b *time.Time) bool { return a.Before(*b) }
+	key := func(i *gitlab.Issue) *time.Time { return i.CreatedAt }
+	if q.Get("order_by") == "updated_at" {
+		key = func(i *gitlab.Issue) *time.Time { return i.UpdatedAt }
+	}
+	sort.SliceStable(list, func(a, b int) bool {
+		ka, kb := key(list[a]), key(list[b])
+		if ka.Equal(*kb) {
+			return list[a].ID < list[b].ID
+		}
+		return less(ka, kb)
+	})
+	if q.Get("sort") != "asc" {
+		reverseIssues(list)
+	}
+	writePage(w, r, list)
+}
+
+func issueAssignedTo(i *gitlab.Issue, id string) bool {
+	for _, a := range i.Assignees {
+		if strconv.Itoa(a.ID) == id {
+			return true
+		}
+	}
+	return false
+}
+
+func reverseIssues(list []*gitlab.Issue) {
+	for i, j := 0, len(list)-1; i < j; i, j = i+1, j-1 {
+		list[i], list[j] = list[j], list[i]
+	}
+}
+
+func (s *Server) getIssue(w http.ResponseWriter, r *request) {
+	_, i := s.routeIssue(w, r)
+	if i == nil {
+		return
+	}
+	writeJSON(w, http.StatusOK, i)
+}
+
+// issueOptions are the fields accepted when creating or updating an issue
+type issueOptions struct {
+	Title        *string `json:"title"`
+	Description  *string `json:"description"`
+	Labels       *labels `json:"labels"`
+	AssigneeIDs  *[]int  `json:"assignee_ids"`
+	MilestoneID  *int    `json:"milestone_id"`
+	DueDate      *string `json:"due_date"`
+	Confidential *bool   `json:"confidential"`
+	StateEvent   *string `json:"state_event"`
+}
+
+// applyIssueOptions sets the fields of i given in opt
+func (s *Server) applyIssueOptions(p *project, i *gitlab.Issue, opt issueOptions) {
+	if opt.Title != nil {
+		i.Title = *opt.Title
+	}
+	if opt.Description != nil {
+		i.Description = *opt.Description
+	}
+	if opt.Labels != nil {
+		i.Labels = []string(*opt.Labels)
+		for _, l := range i.Labels {
+			s.label(p, l)
+		}
+	}
+	if opt.AssigneeIDs != nil {
+		i.Assignees = nil
+		i.Assignee = nil
+		for _, id := range *opt.AssigneeIDs {
+			if u := s.userByID(id); u != nil {
+				i.Assignees = append(i.Assignees, s.issueAssignee(u))
+			}
+		}
+		if len(i.Assignees) > 0 {
+			i.Assignee = i.Assignees[0]
+		}
+	}
+	if opt.MilestoneID != nil {
+		i.Milestone = p.milestone(*opt.MilestoneID)
+	}
+	if opt.DueDate != nil {
+		i.DueDate = nil
+		if t, err := time.Parse("2006-01-02", *opt.DueDate); err == nil {
+			d := gitlab.ISOTime(t)
+			i.DueDate = &d
+		}
+	}
+	if opt.Confidential != nil {
+		i.Confidential = *opt.Confidential
+	}
+	if opt.StateEvent != nil {
+		switch *opt.StateEvent {
+		case "close":
+			i.State = "closed"
+			i.ClosedAt = now()
+		case "reopen":
+			i.State = "opened"
+			i.ClosedAt = nil
+		}
+	}
+	i.UpdatedAt = now()
+}
+
+func (s *Server) createIssue(w http.ResponseWriter, r *request) {
+	p := s.routeProject(w, r)
+	if p == nil {
+		return
+	}
+	var opt issueOptions
+	if err := decode(r, &opt); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if opt.Title == nil || *opt.Title == "" {
+		writeError(w, http.StatusBadRequest, "title is missing")
+		return
+	}
+	i := &gitlab.Issue{Author: s.issueAuthor(r.user)}
+	s.applyIssueOptions(p, i, opt)
+	i.UpdatedAt = nil
+	s.addIssue(p, i)
+	writeJSON(w, http.StatusCreated, i)
+}
+
+func (s *Server) updateIssue(w http.ResponseWriter, r *request) {
+	p, i := s.routeIssue(w, r)
+	if i == nil {
+		return
+	}
+	var opt issueOptions
+	if err := decode(r, &opt); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.applyIssueOptions(p, i, opt)
+	writeJSON(w, http.StatusOK, i)
+}
+
+// AddMilestone adds a milestone to the project pid
+func (s *Server) AddMilestone(pid int, title string) *gitlab.Milestone {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.mustProject(pid)
+	m := &gitlab.Milestone{
+		ID:        s.nextID(),
+		IID:       len(p.milestones) + 1,
+		ProjectID: p.ID,
+		Title:     title,
+		State:     "active",
+		CreatedAt: now(),
+	}
+	p.milestones = append(p.milestones, m)
+	return m
+}
+
+// milestone returns the milestone of p with the given ID, or nil
+func (p *project) milestone(id int) *gitlab.Milestone {
+	for _, m := range p.milestones {
+		if m.ID == id {
+			return m
+		}
+	}
+	return nil
+}
+
+func (s *Server) listMilestones(w http.ResponseWriter, r *request) {
+	p := s.routeProject(w, r)
+	if p == nil {
+		return
+	}
+	q := r.URL.Query()
+	list := []*gitlab.Milestone{}
+	for _, m := range p.milestones {
+		if v := q.Get("title"); v != "" && m.Title != v {
+			continue
+		}
+		if v := q.Get("search"); v != "" && !containsFold(m.Title, v) {
+			continue
+		}
+		if v := q.Get("state"); v != "" && m.State != v {
+			continue
+		}
+		list = append(list, m)
+	}
+	writePage(w, r, list)
+}
@@ -0,0 +1,654 @@
+package gitlabtest
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// mergeRequest is a merge request along with its assignees and reviewers,
+// which go-gitlab's MergeRequest predates
+type mergeRequest struct {
+	*gitlab.MergeRequest
+	Assignees []*gitlab.IssueAssignee `json:"assignees"`
+	Reviewers []*gitlab.IssueAssignee `json:"reviewers"`
+	// DetailedMergeStatus and BlockingDiscussionsResolved are worked
+	// out by mergeStatus whenever the merge request is served
+	DetailedMergeStatus         string `json:"detailed_merge_status"`
+	BlockingDiscussionsResolved bool   `json:"blocking_discussions_resolved"`
+	// diffs are the changes of the merge request, served separately
+	diffs    []*gitlab.Diff
+	versions []*gitlab.MergeRequestDiffVersion
+	// approvalsRequired is how many approvals the merge request needs
+	// from approvedBy
+	approvalsRequired int
+	approvedBy        []*gitlab.User
+	// mergeUser set the merge request to be merged when its pipeline
+	// succeeds
+	mergeUser *gitlab.User
+}
+
+// AddMergeRequest adds a merge request to the project pid, filling in the
+// IDs, URL and state when not set. The source project defaults to pid.
+func (s *Server) AddMergeRequest(pid int, mr *gitlab.MergeRequest) *gitlab.MergeRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.mustProject(pid)
+	s.addMergeRequest(p, &mergeRequest{MergeRequest: mr})
+	return mr
+}
+
+func (s *Server) addMergeRequest(p *project, mr *mergeRequest) {
+	mr.ID = s.nextID()
+	if mr.IID == 0 {
+		for _, other := range p.mrs {
+			if other.IID > mr.IID {
+				mr.IID = other.IID
+			}
+		}
+		mr.IID++
+	}
+	mr.ProjectID = p.ID
+	mr.TargetProjectID = p.ID
+	if mr.SourceProjectID == 0 {
+		mr.SourceProjectID = p.ID
+	}
+	if mr.State == "" {
+		mr.State = "opened"
+	}
+	if mr.MergeStatus == "" {
+		mr.MergeStatus = "can_be_merged"
+	}
+	if mr.CreatedAt == nil {
+		mr.CreatedAt = now()
+	}
+	if mr.UpdatedAt == nil {
+		mr.UpdatedAt = mr.CreatedAt
+	}
+	if mr.TimeStats == nil {
+		mr.TimeStats = &gitlab.TimeStats{}
+	}
+	if src := s.project(strconv.Itoa(mr.SourceProjectID)); src != nil && mr.SHA == "" {
+		if b, ok := src.branches[mr.SourceBranch]; ok {
+			mr.SHA = b.Commit.ID
+		}
+	}
+	for _, l := range mr.Labels {
+		s.label(p, l)
+	}
+	if len(mr.Assignees) == 0 && mr.Assignee.ID != 0 {
+		s.setAssignees(mr, []int{mr.Assignee.ID})
+	}
+	mr.WebURL = p.WebURL + "/merge_requests/" + strconv.Itoa(mr.IID)
+	p.mrs = append(p.mrs, mr)
+}
+
+// AddMergeRequestDiff adds the changes to a file d to the merge request iid
+// of the project pid
+func (s *Server) AddMergeRequestDiff(pid, iid int, d *gitlab.Diff) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	mr := s.mustProject(pid).mergeRequest(iid)
+	if mr == nil {
+		panic(fmt.Sprintf("gitlabtest: merge request %d not found in project %d", iid, pid))
+	}
+	mr.diffs = append(mr.diffs, d)
+}
+
+// AddMergeRequestVersion adds a version of the diff of the merge request iid
+// of the project pid, filling in its ID. Versions are added oldest first.
+func (s *Server) AddMergeRequestVersion(pid, iid int, v *gitlab.MergeRequestDiffVersion) *gitlab.MergeRequestDiffVersion {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	mr := s.mustProject(pid).mergeRequest(iid)
+	if mr == nil {
+		panic(fmt.Sprintf("gitlabtest: merge request %d not found in project %d", iid, pid))
+	}
+	v.ID = s.nextID()
+	v.MergeRequestID = mr.ID
+	if v.State == "" {
+		v.State = "collected"
+	}
+	if v.CreatedAt == nil {
+		v.CreatedAt = now()
+	}
+	mr.versions = append(mr.versions, v)
+	return v
+}
+
+// setAuthor sets the author of mr to u
+func setAuthor(mr *gitlab.MergeRequest, u *gitlab.User) {
+	mr.Author.ID = u.ID
+	mr.Author.Username = u.Username
+	mr.Author.Name = u.Name
+	mr.Author.State = u.State
+}
+
+// setAssignee sets the assignee of mr to u, or clears it when u is nil
+func setAssignee(mr *gitlab.MergeRequest, u *gitlab.User) {
+	mr.Assignee.ID, mr.Assignee.Username, mr.Assignee.Name, mr.Assignee.State = 0, "", "", ""
+	if u != nil {
+		mr.Assignee.ID = u.ID
+		mr.Assignee.Username = u.Username
+		mr.Assignee.Name = u.Name
+		mr.Assignee.State = u.State
+	}
+}
+
+// setAssignees sets the assignees of mr to the users with the given IDs,
+// the first of which is also its single assignee. Unknown IDs, such as the
+// 0 used to remove all of them, are ignored.
+func (s *Server) setAssignees(mr *mergeRequest, ids []int) {
+	mr.Assignees = nil
+	for _, id := range ids {
+		if u := s.userByID(id); u != nil {
+			mr.Assignees = append(mr.Assignees, s.issueAssignee(u))
+		}
+	}
+	if len(mr.Assignees) > 0 {
+		setAssignee(mr.MergeRequest, s.userByID(mr.Assignees[0].ID))
+	} else {
+		setAssignee(mr.MergeRequest, nil)
+	}
+}
+
+// setReviewers sets the reviewers of mr to the users with the given IDs
+func (s *Server) setReviewers(mr *mergeRequest, ids []int) {
+	mr.Reviewers = nil
+	for _, id := range ids {
+		if u := s.userByID(id); u != nil {
+			mr.Reviewers = append(mr.Reviewers, s.issueAssignee(u))
+		}
+	}
+}
+
+// hasUserID reports whether the user with the given ID is one of users
+func hasUserID(users []*gitlab.IssueAssignee, id string) bool {
+	for _, u := range users {
+		if strconv.Itoa(u.ID) == id {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeRequest returns the merge request of p with the given iid, or nil
+func (p *project) mergeRequest(iid int) *mergeRequest {
+	for _, mr := range p.mrs {
+		if mr.IID == iid {
+			return mr
+		}
+	}
+	return nil
+}
+
+// routeMergeRequest returns the merge request of the :id and :iid route
+// parameters, writing a 404 when it doesn't exist
+func (s *Server) routeMergeRequest(w http.ResponseWriter, r *request) (*project, *mergeRequest) {
+	p := s.routeProject(w, r)
+	if p == nil {
+		return nil, nil
+	}
+	iid, _ := strconv.Atoi(r.params["iid"])
+	mr := p.mergeRequest(iid)
+	if mr == nil {
+		notFound(w, "Merge Request")
+		return nil, nil
+	}
+	return p, mr
+}
+
+func (s *Server) listMergeRequests(w http.ResponseWriter, r *request) {
+	p := s.routeProject(w, r)
+	if p == nil {
+		return
+	}
+	q := r.URL.Query()
+	list := []*mergeRequest{}
+	for _, mr := range p.mrs {
+		if state := q.Get("state"); state != "" && state != "all" && mr.State != state {
+			continue
+		}
+		if !hasLabels(mr.Labels, splitList(q.Get("labels"))) {
+			continue
+		}
+		if v := q.Get("milestone"); v != "" && (mr.Milestone == nil || mr.Milestone.Title != v) {
+			continue
+		}
+		if v := q.Get("source_branch"); v != "" && mr.SourceBranch != v {
+			continue
+		}
+		if v := q.Get("target_branch"); v != "" && mr.TargetBranch != v {
+			continue
+		}
+		if v := q.Get("author_id"); v != "" && strconv.Itoa(mr.Author.ID) != v {
+			continue
+		}
+		if v := q.Get("assignee_id"); v != "" && !hasUserID(mr.Assignees, v) {
+			continue
+		}
+		if v := q.Get("reviewer_id"); v != "" && !hasUserID(mr.Reviewers, v) {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, q.Get("created_after")); err == nil && mr.CreatedAt.Before(t) {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, q.Get("updated_after")); err == nil && mr.UpdatedAt.Before(t) {
+			continue
+		}
+		if v := q.Get("search"); v != "" && !containsFold(mr.Title, v) && !containsFold(mr.Description, v) {
+			continue
+		}
+		if v := q.Get("wip"); v != "" && mr.WorkInProgress != (v == "yes") {
+			continue
+		}
+		list = append(list, mr)
+	}
+
+	key := func(mr *mergeRequest) int64 { return mr.CreatedAt.UnixNano() }
+	if q.Get("order_by") == "updated_at" {
+		key = func(mr *mergeRequest) int64 { return mr.UpdatedAt.UnixNano() }
+	}
+	sort.SliceStable(list, func(a, b int) bool {
+		ka, kb := key(list[a]), key(list[b])
+		if ka == kb {
+			ka, kb = int64(list[a].ID), int64(list[b].ID)
+		}
+		if q.Get("sort") == "asc" {
+			return ka < kb
+		}
+		return ka > kb
+	})
+	writePage(w, r, list)
+}
+
+func (s *Server) getMergeRequest(w http.ResponseWriter, r *request) {
+	p, mr := s.routeMergeRequest(w, r)
+	if mr == nil {
+		return
+	}
+	s.mergeStatus(p, mr)
+	writeJSON(w, http.StatusOK, mr)
+}
+
+func (s *Server) getMergeRequestChanges(w http.ResponseWriter, r *request) {
+	_, mr := s.routeMergeRequest(w, r)
+	if mr == nil {
+		return
+	}
+	changes := mr.diffs
+	if changes == nil {
+		changes = []*gitlab.Diff{}
+	}
+	writeJSON(w, http.StatusOK, struct {
+		*mergeRequest
+		Changes []*gitlab.Diff `json:"changes"`
+	}{mr, changes})
+}
+
+func (s *Server) listMergeRequestVersions(w http.ResponseWriter, r *request) {
+	_, mr := s.routeMergeRequest(w, r)
+	if mr == nil {
+		return
+	}
+	// The newest version comes first, and the list leaves out the diffs
+	list := []*gitlab.MergeRequestDiffVersion{}
+	for i := len(mr.versions) - 1; i >= 0; i-- {
+		v := *mr.versions[i]
+		v.Diffs = nil
+		list = append(list, &v)
+	}
+	writePage(w, r, list)
+}
+
+func (s *Server) getMergeRequestVersion(w http.ResponseWriter, r *request) {
+	_, mr := s.routeMergeRequest(w, r)
+	if mr == nil {
+		return
+	}
+	id, _ := strconv.Atoi(r.params["version"])
+	for _, v := range mr.versions {
+		if v.ID == id {
+			writeJSON(w, http.StatusOK, v)
+			return
+		}
+	}
+	notFound(w, "Merge Request Diff Version")
+}
+
+// mergeRequestOptions are the fields accepted when creating or updating a
+// merge request
+type mergeRequestOptions struct {
+	Title              *string `json:"title"`
+	Description        *string `json:"description"`
+	SourceBranch       *string `json:"source_branch"`
+	TargetBranch       *string `json:"target_branch"`
+	TargetProjectID    *int    `json:"target_project_id"`
+	Labels             *labels `json:"labels"`
+	AssigneeID         *int    `json:"assignee_id"`
+	AssigneeIDs        *[]int  `json:"assignee_ids"`
+	ReviewerIDs        *[]int  `json:"reviewer_ids"`
+	MilestoneID        *int    `json:"milestone_id"`
+	RemoveSourceBranch *bool   `json:"remove_source_branch"`
+	Squash             *bool   `json:"squash"`
+	DiscussionLocked   *bool   `json:"discussion_locked"`
+	StateEvent         *string `json:"state_event"`
+}
+
+// applyMergeRequestOptions sets the fields of mr given in opt
+func (s *Server) applyMergeRequestOptions(p *project, mr *mergeRequest, opt mergeRequestOptions) {
+	if opt.Title != nil {
+		mr.Title = *opt.Title
+		mr.WorkInProgress = isDraftTitle(mr.Title)
+	}
+	if opt.Description != nil {
+		mr.Description = *opt.Description
+	}
+	if opt.TargetBranch != nil {
+		mr.TargetBranch = *opt.TargetBranch
+	}
+	if opt.Labels != nil {
+		mr.Labels = []string(*opt.Labels)
+		for _, l := range mr.Labels {
+			s.label(p, l)
+		}
+	}
+	if opt.AssigneeID != nil {
+		s.setAssignees(mr, []int{*opt.AssigneeID})
+	}
+	if opt.AssigneeIDs != nil {
+		s.setAssignees(mr, *opt.AssigneeIDs)
+	}
+	if opt.ReviewerIDs != nil {
+		s.setReviewers(mr, *opt.ReviewerIDs)
+	}
+	if opt.MilestoneID != nil {
+		mr.Milestone = p.milestone(*opt.MilestoneID)
+	}
+	if opt.RemoveSourceBranch != nil {
+		mr.ForceRemoveSourceBranch = *opt.RemoveSourceBranch
+	}
+	if opt.Squash != nil {
+		mr.Squash = *opt.Squash
+	}
+	if opt.DiscussionLocked != nil {
+		mr.DiscussionLocked = *opt.DiscussionLocked
+	}
+	if opt.StateEvent != nil {
+		switch *opt.StateEvent {
+		case "close":
+			mr.State = "closed"
+			mr.ClosedAt = now()
+		case "reopen":
+			mr.State = "opened"
+			mr.ClosedAt = nil
+		}
+	}
+	mr.UpdatedAt = now()
+}
+
+// isDraftTitle reports whether title marks a merge request as a work in
+// progress
+func isDraftTitle(title string) bool {
+	for _, prefix := range []string{"WIP:", "WIP ", "[WIP]", "Draft:", "[Draft]", "(Draft)"} {
+		if len(title) >= len(prefix) && containsFold(title[:len(prefix)], prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) createMergeRequest(w http.ResponseWriter, r *request) {
+	src := s.routeProject(w, r)
+	if src == nil {
+		return
+	}
+	var opt mergeRequestOptions
+	if err := decode(r, &opt); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if opt.Title == nil || opt.SourceBranch == nil || opt.TargetBranch == nil {
+		writeError(w, http.StatusBadRequest, "title, source_branch and target_branch are required")
+		return
+	}
+	target := src
+	if opt.TargetProjectID != nil {
+		if target = s.project(strconv.Itoa(*opt.TargetProjectID)); target == nil {
+			notFound(w, "Project")
+			return
+		}
+	}
+	if _, ok := src.branches[*opt.SourceBranch]; !ok {
+		writeError(w, http.StatusBadRequest, "Source branch does not exist")
+		return
+	}
+	if _, ok := target.branches[*opt.TargetBranch]; !ok {
+		writeError(w, http.StatusBadRequest, "Target branch does not exist")
+		return
+	}
+	for _, other := range target.mrs {
+		if other.State == "opened" && other.SourceProjectID == src.ID &&
+			other.SourceBranch == *opt.SourceBranch && other.TargetBranch == *opt.TargetBranch {
+			writeError(w, http.StatusConflict, fmt.Sprintf("Another open merge request already exists for this source branch: !%d", other.IID))
+			return
+		}
+	}
+
+	mr := &mergeRequest{MergeRequest: &gitlab.MergeRequest{
+		SourceBranch:    *opt.SourceBranch,
+		SourceProjectID: src.ID,
+	}}
+	setAuthor(mr.MergeRequest, r.user)
+	s.applyMergeRequestOptions(target, mr, opt)
+	mr.UpdatedAt = nil
+	s.addMergeRequest(target, mr)
+	writeJSON(w, http.StatusCreated, mr)
+}
+
+func (s *Server) updateMergeRequest(w http.ResponseWriter, r *request) {
+	p, mr := s.routeMergeRequest(w, r)
+	if mr == nil {
+		return
+	}
+	var opt mergeRequestOptions
+	if err := decode(r, &opt); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.applyMergeRequestOptions(p, mr, opt)
+	writeJSON(w, http.StatusOK, mr)
+}
+
+func (s *Server) mergeMergeRequest(w http.ResponseWriter, r *request) {
+	p, mr := s.routeMergeRequest(w, r)
+	if mr == nil {
+		return
+	}
+	if mr.State != "opened" {
+		writeError(w, http.StatusMethodNotAllowed, "405 Method Not Allowed")
+		return
+	}
+	if s.mergeStatus(p, mr); mr.DetailedMergeStatus != "mergeable" {
+		writeError(w, http.StatusMethodNotAllowed, "405 Method Not Allowed")
+		return
+	}
+	var opt struct {
+		SHA                       *string `json:"sha"`
+		ShouldRemoveSourceBranch  *bool   `json:"should_remove_source_branch"`
+		MergeWhenPipelineSucceeds *bool   `json:"merge_when_pipeline_succeeds"`
+		Squash                    *bool   `json:"squash"`
+	}
+	if err := decode(r, &opt); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if opt.SHA != nil && *opt.SHA != mr.SHA {
+		writeError(w, http.StatusConflict, "SHA does not match HEAD of source branch: "+mr.SHA)
+		return
+	}
+	if opt.ShouldRemoveSourceBranch != nil {
+		mr.ShouldRemoveSourceBranch = *opt.ShouldRemoveSourceBranch
+	}
+	if opt.Squash != nil {
+		mr.Squash = *opt.Squash
+	}
+	if opt.MergeWhenPipelineSucceeds != nil && *opt.MergeWhenPipelineSucceeds {
+		switch mr.Pipeline.Status {
+		case "created", "pending", "running":
+			mr.MergeWhenPipelineSucceeds = true
+			mr.mergeUser = r.user
+			writeJSON(w, http.StatusOK, mr)
+			return
+		}
+	}
+	merge(mr, r.user)
+	writeJSON(w, http.StatusOK, mr)
+}
+
+// merge marks mr as merged by u
+func merge(mr *mergeRequest, u *gitlab.User) {
+	mr.State = "merged"
+	mr.MergeWhenPipelineSucceeds = false
+	mr.MergedAt = now()
+	mr.UpdatedAt = mr.MergedAt
+	mr.MergedBy.ID = u.ID
+	mr.MergedBy.Username = u.Username
+	mr.MergedBy.Name = u.Name
+	mr.MergeCommitSHA = mr.SHA
+}
+
+// mergeStatus works out whether mr can be merged, blocking merge requests
+// which are drafts or have unresolved threads
+func (s *Server) mergeStatus(p *project, mr *mergeRequest) {
+	mr.BlockingDiscussionsResolved = true
+	for _, d := range p.discussions[MergeRequests+"/"+strconv.Itoa(mr.IID)] {
+		for _, n := range d.Notes {
+			if n.Resolvable && !n.Resolved {
+				mr.BlockingDiscussionsResolved = false
+			}
+		}
+	}
+	switch {
+	case mr.State != "opened":
+		mr.DetailedMergeStatus = "not_open"
+	case mr.WorkInProgress:
+		mr.DetailedMergeStatus = "draft_status"
+	case !mr.BlockingDiscussionsResolved:
+		mr.DetailedMergeStatus = "discussions_not_resolved"
+	default:
+		mr.DetailedMergeStatus = "mergeable"
+	}
+}
+
+func (s *Server) rebaseMergeRequest(w http.ResponseWriter, r *request) {
+	_, mr := s.routeMergeRequest(w, r)
+	if mr == nil {
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]bool{"rebase_in_progress": true})
+}
+
+func (s *Server) approveMergeRequest(w http.ResponseWriter, r *request) {
+	_, mr := s.routeMergeRequest(w, r)
+	if mr == nil {
+		return
+	}
+	for _, u := range mr.approvedBy {
+		if u.ID == r.user.ID {
+			writeError(w, http.StatusUnauthorized, "401 Unauthorized")
+			return
+		}
+	}
+	mr.approvedBy = append(mr.approvedBy, r.user)
+	writeJSON(w, http.StatusCreated, &gitlab.MergeRequestApprovals{
+		ID:          mr.ID,
+		ProjectID:   mr.ProjectID,
+		Title:       mr.Title,
+		Description: mr.Description,
+		State:       mr.State,
+	})
+}
+
+func (s *Server) unapproveMergeRequest(w http.ResponseWriter, r *request) {
+	_, mr := s.routeMergeRequest(w, r)
+	if mr == nil {
+		return
+	}
+	for i, u := range mr.approvedBy {
+		if u.ID == r.user.ID {
+			mr.approvedBy = append(mr.approvedBy[:i], mr.approvedBy[i+1:]...)
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+	}
+	notFound(w, "Approval")
+}
+
+func (s *Server) getMergeRequestApprovals(w http.ResponseWriter, r *request) {
+	_, mr := s.routeMergeRequest(w, r)
+	if mr == nil {
+		return
+	}
+	approvals := &gitlab.MergeRequestApprovals{
+		ID:                mr.ID,
+		ProjectID:         mr.ProjectID,
+		Title:             mr.Title,
+		Description:       mr.Description,
+		State:             mr.State,
+		CreatedAt:         mr.CreatedAt,
+		UpdatedAt:         mr.UpdatedAt,
+		MergeStatus:       mr.MergeStatus,
+		ApprovalsRequired: mr.approvalsRequired,
+		ApprovedBy:        []*gitlab.MergeRequestApproverUser{},
+		Approvers:         []*gitlab.MergeRequestApproverUser{},
+		ApproverGroups:    []*gitlab.MergeRequestApproverGroup{},
+	}
+	for _, u := range mr.approvedBy {
+		a := &gitlab.MergeRequestApproverUser{}
+		a.User.ID = u.ID
+		a.User.Name = u.Name
+		a.User.Username = u.Username
+		a.User.State = u.State
+		approvals.ApprovedBy = append(approvals.ApprovedBy, a)
+	}
+	if left := mr.approvalsRequired - len(mr.approvedBy); left > 0 {
+		approvals.ApprovalsLeft = left
+	}
+	writeJSON(w, http.StatusOK, approvals)
+}
+
+func (s *Server) awardMergeRequest(w http.ResponseWriter, r *request) {
+	_, mr := s.routeMergeRequest(w, r)
+	if mr == nil {
+		return
+	}
+	var opt struct {
+		Name string `json:"name"`
+	}
+	if err := decode(r, &opt); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	switch opt.Name {
+	case "thumbsup":
+		mr.Upvotes++
+	case "thumbsdown":
+		mr.Downvotes++
+	}
+	award := &gitlab.AwardEmoji{
+		ID:            s.nextID(),
+		Name:          opt.Name,
+		CreatedAt:     now(),
+		AwardableID:   mr.ID,
+		AwardableType: "MergeRequest",
+	}
+	award.User.ID = r.user.ID
+	award.User.Username = r.user.Username
+	award.User.Name = r.user.Name
+	writeJSON(w, http.StatusCreated, award)
+}
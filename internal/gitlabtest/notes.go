@@ -0,0 +1,284 @@
+package gitlabtest
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// Noteable types, used to pick what a note is added to
+const (
+	Issues        = "issues"
+	MergeRequests = "merge_requests"
+)
+
+// AddNote adds a comment to the issue or merge request iid of the project
+// pid. noteable is either Issues or MergeRequests.
+func (s *Server) AddNote(pid int, noteable string, iid int, n *gitlab.Note) *gitlab.Note {
+	d := s.AddDiscussion(pid, noteable, iid, n)
+	d.IndividualNote = true
+	return n
+}
+
+// AddDiscussion adds a thread of notes to the issue or merge request iid of
+// the project pid. noteable is either Issues or MergeRequests.
+func (s *Server) AddDiscussion(pid int, noteable string, iid int, notes ...*gitlab.Note) *gitlab.Discussion {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.mustProject(pid)
+	id, typ := s.noteableID(p, noteable, iid)
+	if id == 0 {
+		panic(fmt.Sprintf("gitlabtest: %s %d not found in project %d", noteable, iid, pid))
+	}
+	for _, n := range notes {
+		s.fillNote(n, id, iid, typ)
+	}
+	return s.addDiscussion(p, noteable, iid, notes)
+}
+
+func (s *Server) addDiscussion(p *project, noteable string, iid int, notes []*gitlab.Note) *gitlab.Discussion {
+	key := noteable + "/" + strconv.Itoa(iid)
+	d := &gitlab.Discussion{
+		ID:    fmt.Sprintf("%x", sha1.Sum([]byte(key+"/"+strconv.Itoa(s.nextID())))),
+		Notes: notes,
+	}
+	p.discussions[key] = append(p.discussions[key], d)
+	return d
+}
+
+// fillNote sets the fields of n which tie it to the noteable
+func (s *Server) fillNote(n *gitlab.Note, noteableID, iid int, typ string) {
+	n.ID = s.nextID()
+	n.NoteableID = noteableID
+	n.NoteableIID = iid
+	n.NoteableType = typ
+	if n.CreatedAt == nil {
+		n.CreatedAt = now()
+	}
+	if n.UpdatedAt == nil {
+		n.UpdatedAt = n.CreatedAt
+	}
+}
+
+// noteableID returns the ID and type of the issue or merge request iid,
+// or zero when it doesn't exist
+func (s *Server) noteableID(p *project, noteable string, iid int) (int, string) {
+	switch noteable {
+	case Issues:
+		if i := p.issue(iid); i != nil {
+			return i.ID, "Issue"
+		}
+	case MergeRequests:
+		if mr := p.mergeRequest(iid); mr != nil {
+			return mr.ID, "MergeRequest"
+		}
+	}
+	return 0, ""
+}
+
+// routeNoteable returns the project, noteable type and iid of the route,
+// writing a 404 when the issue or merge request doesn't exist
+func (s *Server) routeNoteable(w http.ResponseWriter, r *request) (*project, string, int, bool) {
+	p := s.routeProject(w, r)
+	if p == nil {
+		return nil, "", 0, false
+	}
+	noteable := MergeRequests
+	if strings.Contains(r.URL.Path, "/issues/") {
+		noteable = Issues
+	}
+	iid, _ := strconv.Atoi(r.params["iid"])
+	if id, _ := s.noteableID(p, noteable, iid); id == 0 {
+		if noteable == Issues {
+			notFound(w, "Issue")
+		} else {
+			notFound(w, "Merge Request")
+		}
+		return nil, "", 0, false
+	}
+	return p, noteable, iid, true
+}
+
+func (s *Server) listNotes(w http.ResponseWriter, r *request) {
+	p, noteable, iid, ok := s.routeNoteable(w, r)
+	if !ok {
+		return
+	}
+	notes := []*gitlab.Note{}
+	for _, d := range p.discussions[noteable+"/"+strconv.Itoa(iid)] {
+		notes = append(notes, d.Notes...)
+	}
+	writePage(w, r, notes)
+}
+
+func (s *Server) listDiscussions(w http.ResponseWriter, r *request) {
+	p, noteable, iid, ok := s.routeNoteable(w, r)
+	if !ok {
+		return
+	}
+	list := p.discussions[noteable+"/"+strconv.Itoa(iid)]
+	if list == nil {
+		list = []*gitlab.Discussion{}
+	}
+	writePage(w, r, list)
+}
+
+func (s *Server) createNote(w http.ResponseWriter, r *request) {
+	p, noteable, iid, ok := s.routeNoteable(w, r)
+	if !ok {
+		return
+	}
+	var opt struct {
+		Body string `json:"body"`
+	}
+	if err := decode(r, &opt); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if opt.Body == "" {
+		writeError(w, http.StatusBadRequest, "body is missing")
+		return
+	}
+	n := &gitlab.Note{Body: opt.Body}
+	setNoteAuthor(n, r.user)
+	id, typ := s.noteableID(p, noteable, iid)
+	s.fillNote(n, id, iid, typ)
+	d := s.addDiscussion(p, noteable, iid, []*gitlab.Note{n})
+	d.IndividualNote = true
+	writeJSON(w, http.StatusCreated, n)
+}
+
+func (s *Server) createDiscussion(w http.ResponseWriter, r *request) {
+	p, noteable, iid, ok := s.routeNoteable(w, r)
+	if !ok {
+		return
+	}
+	var opt struct {
+		Body     string               `json:"body"`
+		Position *gitlab.NotePosition `json:"position"`
+	}
+	if err := decode(r, &opt); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if opt.Body == "" {
+		writeError(w, http.StatusBadRequest, "body is missing")
+		return
+	}
+	n := &gitlab.Note{Body: opt.Body}
+	if pos := opt.Position; pos != nil {
+		if noteable != MergeRequests {
+			writeError(w, http.StatusBadRequest, "position is only supported on merge requests")
+			return
+		}
+		if pos.PositionType != "text" || pos.BaseSHA == "" || pos.StartSHA == "" || pos.HeadSHA == "" {
+			writeError(w, http.StatusBadRequest, "position is invalid")
+			return
+		}
+		if pos.NewLine == 0 && pos.OldLine == 0 {
+			writeError(w, http.StatusBadRequest, "position is missing a line")
+			return
+		}
+		n.Position = pos
+	}
+	if noteable == MergeRequests {
+		n.Resolvable = true
+	}
+	setNoteAuthor(n, r.user)
+	id, typ := s.noteableID(p, noteable, iid)
+	s.fillNote(n, id, iid, typ)
+	d := s.addDiscussion(p, noteable, iid, []*gitlab.Note{n})
+	writeJSON(w, http.StatusCreated, d)
+}
+
+// routeDiscussion returns the project, noteable type and iid of the route
+// along with its discussion, writing a 404 when any of them doesn't exist
+func (s *Server) routeDiscussion(w http.ResponseWriter, r *request) (*project, string, int, *gitlab.Discussion) {
+	p, noteable, iid, ok := s.routeNoteable(w, r)
+	if !ok {
+		return nil, "", 0, nil
+	}
+	for _, d := range p.discussions[noteable+"/"+strconv.Itoa(iid)] {
+		if d.ID == r.params["discussion"] {
+			return p, noteable, iid, d
+		}
+	}
+	notFound(w, "Discussion")
+	return nil, "", 0, nil
+}
+
+func (s *Server) addDiscussionNote(w http.ResponseWriter, r *request) {
+	p, noteable, iid, d := s.routeDiscussion(w, r)
+	if d == nil {
+		return
+	}
+	var opt struct {
+		Body string `json:"body"`
+	}
+	if err := decode(r, &opt); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if opt.Body == "" {
+		writeError(w, http.StatusBadRequest, "body is missing")
+		return
+	}
+	first := d.Notes[0]
+	n := &gitlab.Note{Body: opt.Body, Position: first.Position, Resolvable: first.Resolvable}
+	setNoteAuthor(n, r.user)
+	id, typ := s.noteableID(p, noteable, iid)
+	s.fillNote(n, id, iid, typ)
+	// Replying to a comment turns it into a thread
+	d.IndividualNote = false
+	d.Notes = append(d.Notes, n)
+	writeJSON(w, http.StatusCreated, n)
+}
+
+func (s *Server) resolveDiscussion(w http.ResponseWriter, r *request) {
+	_, _, _, d := s.routeDiscussion(w, r)
+	if d == nil {
+		return
+	}
+	var opt struct {
+		Resolved *bool `json:"resolved"`
+	}
+	if err := decode(r, &opt); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if opt.Resolved == nil {
+		writeError(w, http.StatusBadRequest, "resolved is missing")
+		return
+	}
+	resolvable := false
+	for _, n := range d.Notes {
+		if !n.Resolvable {
+			continue
+		}
+		resolvable = true
+		n.Resolved = *opt.Resolved
+		n.ResolvedBy.ID, n.ResolvedBy.Username, n.ResolvedBy.Name = 0, "", ""
+		if n.Resolved {
+			n.ResolvedBy.ID = r.user.ID
+			n.ResolvedBy.Username = r.user.Username
+			n.ResolvedBy.Name = r.user.Name
+		}
+	}
+	if !resolvable {
+		writeError(w, http.StatusBadRequest, "Discussion is not resolvable")
+		return
+	}
+	writeJSON(w, http.StatusOK, d)
+}
+
+// setNoteAuthor sets the author of n to u
+func setNoteAuthor(n *gitlab.Note, u *gitlab.User) {
+	n.Author.ID = u.ID
+	n.Author.Username = u.Username
+	n.Author.Name = u.Name
+	n.Author.State = u.State
+}
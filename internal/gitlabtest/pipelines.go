@@ -0,0 +1,490 @@
+package gitlabtest
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	gitlab "github.com/xanzy/go-gitlab"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// pipeline is a pipeline along with the fields go-gitlab doesn't know about
+type pipeline struct {
+	*gitlab.Pipeline
+	Source string `json:"source"`
+	WebURL string `json:"web_url"`
+
+	variables []*gitlab.PipelineVariable
+}
+
+// AddPipeline adds a pipeline to the project pid, filling in the ID and
+// status when not set. It is started by a push.
+func (s *Server) AddPipeline(pid int, pl *gitlab.Pipeline) *gitlab.Pipeline {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.mustProject(pid)
+	s.addPipeline(p, pl, "push")
+	return pl
+}
+
+func (s *Server) addPipeline(p *project, pl *gitlab.Pipeline, source string) *pipeline {
+	if pl.ID == 0 {
+		pl.ID = s.nextID()
+	} else if pl.ID > s.lastID {
+		s.lastID = pl.ID
+	}
+	if pl.Status == "" {
+		pl.Status = "pending"
+	}
+	if pl.CreatedAt == nil {
+		pl.CreatedAt = now()
+	}
+	if pl.UpdatedAt == nil {
+		pl.UpdatedAt = pl.CreatedAt
+	}
+	wrapped := &pipeline{
+		Pipeline: pl,
+		Source:   source,
+		WebURL:   p.WebURL + "/pipelines/" + strconv.Itoa(pl.ID),
+	}
+	p.pipelines = append(p.pipelines, wrapped)
+	return wrapped
+}
+
+// SetPipelineStatus changes the status of the pipeline pipelineID of the
+// project pid, as if it ran. The merge requests whose pipeline it is follow
+// it, and those set to merge when the pipeline succeeds are merged once it
+// does.
+func (s *Server) SetPipelineStatus(pid, pipelineID int, status string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.mustProject(pid)
+	pl := p.pipeline(pipelineID)
+	if pl == nil {
+		panic(fmt.Sprintf("gitlabtest: pipeline %d not found in project %d", pipelineID, pid))
+	}
+	pl.Status = status
+	pl.UpdatedAt = now()
+	switch status {
+	case "success", "failed", "canceled", "skipped":
+		pl.FinishedAt = pl.UpdatedAt
+	}
+	for _, j := range p.jobs {
+		if j.Pipeline.ID == pipelineID {
+			j.Pipeline.Status = status
+		}
+	}
+	for _, mr := range p.mrs {
+		if mr.Pipeline.ID != pipelineID {
+			continue
+		}
+		mr.Pipeline.Status = status
+		if status == "success" && mr.State == "opened" && mr.MergeWhenPipelineSucceeds {
+			merge(mr, mr.mergeUser)
+		}
+	}
+}
+
+// AddJob adds a job with the given trace to the pipeline pipelineID of the
+// project pid. Jobs are listed in the order they are added.
+func (s *Server) AddJob(pid, pipelineID int, j *gitlab.Job, trace string) *gitlab.Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.mustProject(pid)
+	pl := p.pipeline(pipelineID)
+	if pl == nil {
+		panic(fmt.Sprintf("gitlabtest: pipeline %d not found in project %d", pipelineID, pid))
+	}
+	s.addJob(p, pl.Pipeline, j)
+	p.traces[j.ID] = trace
+	return j
+}
+
+func (s *Server) addJob(p *project, pl *gitlab.Pipeline, j *gitlab.Job) {
+	if j.ID == 0 {
+		j.ID = s.nextID()
+	} else if j.ID > s.lastID {
+		s.lastID = j.ID
+	}
+	if j.Status == "" {
+		j.Status = "created"
+	}
+	if j.CreatedAt == nil {
+		j.CreatedAt = now()
+	}
+	j.Pipeline.ID = pl.ID
+	j.Pipeline.Ref = pl.Ref
+	j.Pipeline.Sha = pl.SHA
+	j.Pipeline.Status = pl.Status
+	j.Ref = pl.Ref
+	j.WebURL = p.WebURL + "/-/jobs/" + strconv.Itoa(j.ID)
+	p.jobs = append(p.jobs, j)
+}
+
+// AddArtifacts sets the artifacts of the job jobID of the project pid to a
+// zip archive of files, which maps paths to contents
+func (s *Server) AddArtifacts(pid, jobID int, files map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.mustProject(pid)
+	j := p.job(jobID)
+	if j == nil {
+		panic(fmt.Sprintf("gitlabtest: job %d not found in project %d", jobID, pid))
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range names {
+		f, err := zw.Create(name)
+		if err != nil {
+			panic(err)
+		}
+		f.Write([]byte(files[name]))
+	}
+	if err := zw.Close(); err != nil {
+		panic(err)
+	}
+	p.artifacts[j.ID] = buf.Bytes()
+	j.ArtifactsFile.Filename = "artifacts.zip"
+	j.ArtifactsFile.Size = buf.Len()
+}
+
+// pipeline returns the pipeline of p with the given ID, or nil
+func (p *project) pipeline(id int) *pipeline {
+	for _, pl := range p.pipelines {
+		if pl.ID == id {
+			return pl
+		}
+	}
+	return nil
+}
+
+// job returns the job of p with the given ID, or nil
+func (p *project) job(id int) *gitlab.Job {
+	for _, j := range p.jobs {
+		if j.ID == id {
+			return j
+		}
+	}
+	return nil
+}
+
+// routeJob returns the job of the :id and :job route parameters, writing a
+// 404 when it doesn't exist
+func (s *Server) routeJob(w http.ResponseWriter, r *request) (*project, *gitlab.Job) {
+	p := s.routeProject(w, r)
+	if p == nil {
+		return nil, nil
+	}
+	id, _ := strconv.Atoi(r.params["job"])
+	j := p.job(id)
+	if j == nil {
+		notFound(w, "Job")
+		return nil, nil
+	}
+	return p, j
+}
+
+func (s *Server) listPipelines(w http.ResponseWriter, r *request) {
+	p := s.routeProject(w, r)
+	if p == nil {
+		return
+	}
+	q := r.URL.Query()
+	list := []*pipeline{}
+	for _, pl := range p.pipelines {
+		if v := q.Get("ref"); v != "" && pl.Ref != v {
+			continue
+		}
+		if v := q.Get("sha"); v != "" && pl.SHA != v {
+			continue
+		}
+		if v := q.Get("status"); v != "" && pl.Status != v {
+			continue
+		}
+		list = append(list, pl)
+	}
+	sortPipelines(list, q.Get("sort") == "asc")
+	writePage(w, r, list)
+}
+
+// sortPipelines sorts the newest pipelines first, as GitLab orders by
+// descending ID, or the oldest first when asc is true
+func sortPipelines(list []*pipeline, asc bool) {
+	sort.SliceStable(list, func(i, j int) bool {
+		if asc {
+			return list[i].ID < list[j].ID
+		}
+		return list[i].ID > list[j].ID
+	})
+}
+
+func (s *Server) getPipeline(w http.ResponseWriter, r *request) {
+	p := s.routeProject(w, r)
+	if p == nil {
+		return
+	}
+	id, _ := strconv.Atoi(r.params["pipeline"])
+	pl := p.pipeline(id)
+	if pl == nil {
+		notFound(w, "Pipeline")
+		return
+	}
+	writeJSON(w, http.StatusOK, pl)
+}
+
+// listMergeRequestPipelines lists the pipelines of the merge request refs and
+// of its source branch, the newest first
+func (s *Server) listMergeRequestPipelines(w http.ResponseWriter, r *request) {
+	p, mr := s.routeMergeRequest(w, r)
+	if mr == nil {
+		return
+	}
+	prefix := fmt.Sprintf("refs/merge-requests/%d/", mr.IID)
+	list := []*pipeline{}
+	for _, pl := range p.pipelines {
+		if pl.Ref == mr.SourceBranch || strings.HasPrefix(pl.Ref, prefix) {
+			list = append(list, pl)
+		}
+	}
+	sortPipelines(list, false)
+	writePage(w, r, list)
+}
+
+func (s *Server) createPipeline(w http.ResponseWriter, r *request) {
+	p := s.routeProject(w, r)
+	if p == nil {
+		return
+	}
+	var opt struct {
+		Ref       string                     `json:"ref"`
+		Variables []*gitlab.PipelineVariable `json:"variables"`
+	}
+	if err := decode(r, &opt); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	b, ok := p.branches[opt.Ref]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Reference not found")
+		return
+	}
+	pl := &gitlab.Pipeline{Ref: opt.Ref, SHA: b.Commit.ID}
+	pl.User.ID = r.user.ID
+	pl.User.Username = r.user.Username
+	pl.User.Name = r.user.Name
+	created := s.addPipeline(p, pl, "api")
+	created.variables = opt.Variables
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (s *Server) listPipelineVariables(w http.ResponseWriter, r *request) {
+	p := s.routeProject(w, r)
+	if p == nil {
+		return
+	}
+	id, _ := strconv.Atoi(r.params["pipeline"])
+	pl := p.pipeline(id)
+	if pl == nil {
+		notFound(w, "Pipeline")
+		return
+	}
+	writeJSON(w, http.StatusOK, append([]*gitlab.PipelineVariable{}, pl.variables...))
+}
+
+func (s *Server) listPipelineJobs(w http.ResponseWriter, r *request) {
+	p := s.routeProject(w, r)
+	if p == nil {
+		return
+	}
+	id, _ := strconv.Atoi(r.params["pipeline"])
+	if p.pipeline(id) == nil {
+		notFound(w, "Pipeline")
+		return
+	}
+	scope := r.URL.Query()["scope[]"]
+	list := []*gitlab.Job{}
+	// GitLab lists the newest jobs first
+	for i := len(p.jobs) - 1; i >= 0; i-- {
+		j := p.jobs[i]
+		if j.Pipeline.ID != id {
+			continue
+		}
+		if len(scope) > 0 && !contains(scope, j.Status) {
+			continue
+		}
+		list = append(list, j)
+	}
+	writePage(w, r, list)
+}
+
+func (s *Server) getTrace(w http.ResponseWriter, r *request) {
+	p, j := s.routeJob(w, r)
+	if j == nil {
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(p.traces[j.ID]))
+}
+
+// writeArtifacts writes the artifacts archive of j, or a 404 when it has none
+func writeArtifacts(w http.ResponseWriter, p *project, j *gitlab.Job) {
+	archive, ok := p.artifacts[j.ID]
+	if !ok {
+		writeError(w, http.StatusNotFound, "404 Not Found")
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(archive)
+}
+
+func (s *Server) getArtifacts(w http.ResponseWriter, r *request) {
+	p, j := s.routeJob(w, r)
+	if j == nil {
+		return
+	}
+	writeArtifacts(w, p, j)
+}
+
+// getLatestArtifacts serves the artifacts of the job named by the job query
+// parameter in the latest successful pipeline of the :ref route parameter
+func (s *Server) getLatestArtifacts(w http.ResponseWriter, r *request) {
+	p := s.routeProject(w, r)
+	if p == nil {
+		return
+	}
+	name := r.URL.Query().Get("job")
+	var latest *gitlab.Job
+	for _, j := range p.jobs {
+		if j.Ref != r.params["ref"] || j.Name != name {
+			continue
+		}
+		if pl := p.pipeline(j.Pipeline.ID); pl.Status != "success" {
+			continue
+		}
+		if latest == nil || j.Pipeline.ID >= latest.Pipeline.ID {
+			latest = j
+		}
+	}
+	if latest == nil {
+		writeError(w, http.StatusNotFound, "404 Not Found")
+		return
+	}
+	writeArtifacts(w, p, latest)
+}
+
+func (s *Server) playJob(w http.ResponseWriter, r *request) {
+	_, j := s.routeJob(w, r)
+	if j == nil {
+		return
+	}
+	if j.Status != "manual" {
+		writeError(w, http.StatusBadRequest, "400 Unplayable Job")
+		return
+	}
+	j.Status = "pending"
+	writeJSON(w, http.StatusOK, j)
+}
+
+func (s *Server) retryJob(w http.ResponseWriter, r *request) {
+	p, j := s.routeJob(w, r)
+	if j == nil {
+		return
+	}
+	switch j.Status {
+	case "failed", "success", "canceled":
+	default:
+		writeError(w, http.StatusForbidden, "403 Forbidden - Job is not retryable")
+		return
+	}
+	retry := *j
+	retry.ID = 0
+	retry.Status = "pending"
+	retry.CreatedAt = nil
+	retry.StartedAt = nil
+	retry.FinishedAt = nil
+	s.addJob(p, p.pipeline(j.Pipeline.ID).Pipeline, &retry)
+	writeJSON(w, http.StatusCreated, &retry)
+}
+
+func (s *Server) cancelJob(w http.ResponseWriter, r *request) {
+	_, j := s.routeJob(w, r)
+	if j == nil {
+		return
+	}
+	switch j.Status {
+	case "created", "pending", "running":
+		j.Status = "canceled"
+		j.FinishedAt = now()
+	}
+	writeJSON(w, http.StatusCreated, j)
+}
+
+// globalKeywords are the top level keys of .gitlab-ci.yml which aren't jobs
+var globalKeywords = map[string]bool{
+	"image": true, "services": true, "stages": true, "types": true,
+	"before_script": true, "after_script": true, "variables": true,
+	"cache": true, "include": true, "workflow": true, "default": true,
+}
+
+// lint checks the basic structure of a .gitlab-ci.yml: it must be a map
+// where every job is a map with a script
+func (s *Server) lint(w http.ResponseWriter, r *request) {
+	var opt struct {
+		Content string `json:"content"`
+	}
+	if err := decode(r, &opt); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	result := &gitlab.LintResult{Status: "invalid", Errors: []string{}}
+	if strings.TrimSpace(opt.Content) == "" {
+		result.Errors = append(result.Errors, "Please provide content of .gitlab-ci.yml")
+		writeJSON(w, http.StatusOK, result)
+		return
+	}
+	var config map[string]interface{}
+	if err := yaml.Unmarshal([]byte(opt.Content), &config); err != nil {
+		result.Errors = append(result.Errors, "Invalid configuration format")
+		writeJSON(w, http.StatusOK, result)
+		return
+	}
+	names := make([]string, 0, len(config))
+	for name := range config {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	jobs := 0
+	for _, name := range names {
+		if globalKeywords[name] || strings.HasPrefix(name, ".") {
+			continue
+		}
+		jobs++
+		job, ok := config[name].(map[interface{}]interface{})
+		if !ok {
+			result.Errors = append(result.Errors, fmt.Sprintf("jobs:%s config should be a hash", name))
+			continue
+		}
+		if _, ok := job["script"]; !ok {
+			if _, ok := job["trigger"]; !ok {
+				result.Errors = append(result.Errors, fmt.Sprintf("jobs:%s script can't be blank", name))
+			}
+		}
+	}
+	if jobs == 0 {
+		result.Errors = append(result.Errors, "jobs config should contain at least one visible job")
+	}
+	if len(result.Errors) == 0 {
+		result.Status = "valid"
+	}
+	writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,326 @@
+package gitlabtest
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// AddProject adds a project, filling in the ID, URLs and other fields
+// derived from its PathWithNamespace when not set. The project is owned by
+// the user named after its namespace, if there is one.
+func (s *Server) AddProject(p *gitlab.Project) *gitlab.Project {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addProject(p).Project
+}
+
+func (s *Server) addProject(p *gitlab.Project) *project {
+	if p.ID == 0 {
+		p.ID = s.nextID()
+	} else if p.ID > s.lastID {
+		s.lastID = p.ID
+	}
+	namespace := path.Dir(p.PathWithNamespace)
+	if p.Path == "" {
+		p.Path = path.Base(p.PathWithNamespace)
+	}
+	if p.Name == "" {
+		p.Name = p.Path
+	}
+	if p.NameWithNamespace == "" {
+		p.NameWithNamespace = namespace + " / " + p.Name
+	}
+	if p.Namespace == nil {
+		p.Namespace = &gitlab.ProjectNamespace{
+			Name:     namespace,
+			Path:     namespace,
+			FullPath: namespace,
+			Kind:     "user",
+		}
+	}
+	if p.Owner == nil {
+		p.Owner = s.user(namespace)
+	}
+	if p.DefaultBranch == "" {
+		p.DefaultBranch = "master"
+	}
+	if p.Visibility == "" {
+		p.Visibility = gitlab.PublicVisibility
+	}
+	if p.CreatedAt == nil {
+		p.CreatedAt = now()
+	}
+	p.WebURL = s.WebURL + "/" + p.PathWithNamespace
+	p.HTTPURLToRepo = p.WebURL + ".git"
+	p.SSHURLToRepo = fmt.Sprintf("git@%s:%s.git", s.host(), p.PathWithNamespace)
+
+	proj := &project{
+		Project:     p,
+		branches:    make(map[string]*gitlab.Branch),
+		discussions: make(map[string][]*gitlab.Discussion),
+		traces:      make(map[int]string),
+		artifacts:   make(map[int][]byte),
+		comparisons: make(map[string][]*gitlab.Diff),
+	}
+	s.projects = append(s.projects, proj)
+	return proj
+}
+
+// AddBranch adds a branch pointing at the commit sha to the project pid
+func (s *Server) AddBranch(pid int, name, sha string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.mustProject(pid)
+	p.branches[name] = &gitlab.Branch{
+		Name:   name,
+		Commit: &gitlab.Commit{ID: sha, ShortID: shortSHA(sha)},
+	}
+}
+
+// AddLabel adds a label to the project pid
+func (s *Server) AddLabel(pid int, name string) *gitlab.Label {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.mustProject(pid)
+	l := &gitlab.Label{ID: s.nextID(), Name: name, Color: "#428BCA"}
+	p.labels = append(p.labels, l)
+	return l
+}
+
+// project returns the project with the given ID or path, or nil
+func (s *Server) project(id string) *project {
+	n, err := strconv.Atoi(id)
+	for _, p := range s.projects {
+		if (err == nil && p.ID == n) || p.PathWithNamespace == id {
+			return p
+		}
+	}
+	return nil
+}
+
+// mustProject returns the project with the given ID, panicking when there
+// is none as it's a mistake in the test seeding the server
+func (s *Server) mustProject(pid int) *project {
+	p := s.project(strconv.Itoa(pid))
+	if p == nil {
+		panic(fmt.Sprintf("gitlabtest: project %d not found", pid))
+	}
+	return p
+}
+
+// routeProject returns the project of the :id route parameter, writing a
+// 404 when it doesn't exist
+func (s *Server) routeProject(w http.ResponseWriter, r *request) *project {
+	p := s.project(r.params["id"])
+	if p == nil {
+		notFound(w, "Project")
+	}
+	return p
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 8 {
+		return sha[:8]
+	}
+	return sha
+}
+
+func (s *Server) listProjects(w http.ResponseWriter, r *request) {
+	q := r.URL.Query()
+	list := []*gitlab.Project{}
+	for _, p := range s.projects {
+		owned := p.Owner != nil && p.Owner.ID == r.user.ID
+		if q.Get("owned") == "true" && !owned {
+			continue
+		}
+		if q.Get("membership") == "true" && !owned && p.Namespace.FullPath != r.user.Username {
+			continue
+		}
+		// Nobody stars projects on the fake
+		if q.Get("starred") == "true" {
+			continue
+		}
+		if v := q.Get("visibility"); v != "" && string(p.Visibility) != v {
+			continue
+		}
+		if v := q.Get("search"); v != "" && !containsFold(p.PathWithNamespace, v) && !containsFold(p.Name, v) {
+			continue
+		}
+		list = append(list, p.Project)
+	}
+
+	// GitLab orders by created_at by default, which follows the ID
+	less := func(i, j int) bool { return list[i].ID < list[j].ID }
+	switch q.Get("order_by") {
+	case "name":
+		less = func(i, j int) bool { return list[i].Name < list[j].Name }
+	case "path":
+		less = func(i, j int) bool { return list[i].Path < list[j].Path }
+	}
+	if q.Get("sort") == "asc" {
+		sort.SliceStable(list, less)
+	} else {
+		sort.SliceStable(list, func(i, j int) bool { return less(j, i) })
+	}
+	writePage(w, r, list)
+}
+
+func (s *Server) getProject(w http.ResponseWriter, r *request) {
+	p := s.routeProject(w, r)
+	if p == nil {
+		return
+	}
+	writeJSON(w, http.StatusOK, p.Project)
+}
+
+func (s *Server) createProject(w http.ResponseWriter, r *request) {
+	var opt struct {
+		Name        string `json:"name"`
+		Path        string `json:"path"`
+		Description string `json:"description"`
+		Visibility  string `json:"visibility"`
+	}
+	if err := decode(r, &opt); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if opt.Path == "" {
+		opt.Path = opt.Name
+	}
+	if opt.Path == "" {
+		writeError(w, http.StatusBadRequest, "name is missing, path is missing")
+		return
+	}
+	if opt.Name == "" {
+		opt.Name = opt.Path
+	}
+	pwn := r.user.Username + "/" + opt.Path
+	if s.project(pwn) != nil {
+		writeError(w, http.StatusBadRequest, "path has already been taken")
+		return
+	}
+	p := s.addProject(&gitlab.Project{
+		Name:              opt.Name,
+		Path:              opt.Path,
+		PathWithNamespace: pwn,
+		Description:       opt.Description,
+		Visibility:        gitlab.VisibilityValue(opt.Visibility),
+		Owner:             r.user,
+	})
+	writeJSON(w, http.StatusCreated, p.Project)
+}
+
+func (s *Server) deleteProject(w http.ResponseWriter, r *request) {
+	p := s.routeProject(w, r)
+	if p == nil {
+		return
+	}
+	for i := range s.projects {
+		if s.projects[i] == p {
+			s.projects = append(s.projects[:i], s.projects[i+1:]...)
+			break
+		}
+	}
+	writeError(w, http.StatusAccepted, "202 Accepted")
+}
+
+func (s *Server) forkProject(w http.ResponseWriter, r *request) {
+	parent := s.routeProject(w, r)
+	if parent == nil {
+		return
+	}
+	pwn := r.user.Username + "/" + parent.Path
+	if s.project(pwn) != nil {
+		writeError(w, http.StatusConflict, "Project namespace name has already been taken")
+		return
+	}
+	fork := s.addProject(&gitlab.Project{
+		Name:              parent.Name,
+		Path:              parent.Path,
+		PathWithNamespace: pwn,
+		Description:       parent.Description,
+		DefaultBranch:     parent.DefaultBranch,
+		Owner:             r.user,
+		ForkedFromProject: &gitlab.ForkParent{
+			ID:                parent.ID,
+			Name:              parent.Name,
+			NameWithNamespace: parent.NameWithNamespace,
+			Path:              parent.Path,
+			PathWithNamespace: parent.PathWithNamespace,
+			HTTPURLToRepo:     parent.HTTPURLToRepo,
+			WebURL:            parent.WebURL,
+		},
+	})
+	for name, b := range parent.branches {
+		c := *b
+		fork.branches[name] = &c
+	}
+	writeJSON(w, http.StatusCreated, fork.Project)
+}
+
+func (s *Server) getBranch(w http.ResponseWriter, r *request) {
+	p := s.routeProject(w, r)
+	if p == nil {
+		return
+	}
+	b, ok := p.branches[r.params["branch"]]
+	if !ok {
+		notFound(w, "Branch")
+		return
+	}
+	writeJSON(w, http.StatusOK, b)
+}
+
+// AddComparison sets the diffs served when comparing the commits from and
+// to of the project pid
+func (s *Server) AddComparison(pid int, from, to string, diffs ...*gitlab.Diff) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mustProject(pid).comparisons[from+"..."+to] = diffs
+}
+
+func (s *Server) compare(w http.ResponseWriter, r *request) {
+	p := s.routeProject(w, r)
+	if p == nil {
+		return
+	}
+	from, to := r.URL.Query().Get("from"), r.URL.Query().Get("to")
+	diffs, ok := p.comparisons[from+"..."+to]
+	if !ok && from != to {
+		notFound(w, "Ref")
+		return
+	}
+	if diffs == nil {
+		diffs = []*gitlab.Diff{}
+	}
+	writeJSON(w, http.StatusOK, &gitlab.Compare{
+		Diffs:          diffs,
+		CompareSameRef: from == to,
+	})
+}
+
+func (s *Server) listLabels(w http.ResponseWriter, r *request) {
+	p := s.routeProject(w, r)
+	if p == nil {
+		return
+	}
+	writePage(w, r, p.labels)
+}
+
+// label returns the label of p with the given name, creating it when
+// missing as GitLab does when an issue or merge request uses a new label
+func (s *Server) label(p *project, name string) *gitlab.Label {
+	for _, l := range p.labels {
+		if l.Name == name {
+			return l
+		}
+	}
+	l := &gitlab.Label{ID: s.nextID(), Name: name, Color: "#428BCA"}
+	p.labels = append(p.labels, l)
+	return l
+}
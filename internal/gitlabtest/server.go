@@ -0,0 +1,430 @@
+// Package gitlabtest provides an in-memory fake of the parts of the GitLab v4
+// API used by lab, so the tests can run without network access.
+//
+// The fake is stateful: objects created through the API can be read back,
+// updated and deleted like on a real instance. Tests seed the objects they
+// rely on with the Add methods, or with Seed for the gitlab.com test projects
+// lab's integration tests were written against.
+package gitlabtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// Server is a fake GitLab instance listening on a local port
+type Server struct {
+	*httptest.Server
+
+	// WebURL is used to build the web_url and repository URLs of the
+	// objects served. It defaults to the URL of the server and must be set
+	// before any objects are added.
+	WebURL string
+
+	mu       sync.Mutex
+	lastID   int
+	users    []*gitlab.User
+	tokens   map[string]*gitlab.User
+	projects []*project
+	groups   []*group
+	snippets []*snippet
+	routes   []route
+}
+
+// project holds a project and everything stored under it
+type project struct {
+	*gitlab.Project
+	branches    map[string]*gitlab.Branch
+	labels      []*gitlab.Label
+	milestones  []*gitlab.Milestone
+	issues      []*gitlab.Issue
+	mrs         []*mergeRequest
+	discussions map[string][]*gitlab.Discussion
+	pipelines   []*pipeline
+	jobs        []*gitlab.Job
+	traces      map[int]string
+	artifacts   map[int][]byte
+	variables   []*variable
+	comparisons map[string][]*gitlab.Diff
+	snippets    []*snippet
+}
+
+type snippet struct {
+	*gitlab.Snippet
+	content string
+}
+
+// request is an API request along with the authenticated user and the
+// parameters matched from the route
+type request struct {
+	*http.Request
+	user   *gitlab.User
+	params map[string]string
+}
+
+type route struct {
+	method  string
+	pattern []string
+	handler func(w http.ResponseWriter, r *request)
+}
+
+// NewServer starts a fake GitLab server. Callers should call Close when
+// finished to shut it down.
+func NewServer() *Server {
+	s := &Server{tokens: make(map[string]*gitlab.User)}
+	s.registerRoutes()
+	s.Server = httptest.NewServer(s)
+	s.WebURL = s.URL
+	return s
+}
+
+// handle registers a handler for requests matching method and pattern. The
+// pattern is a path relative to /api/v4 where segments starting with a colon
+// match any value, such as /projects/:id.
+func (s *Server) handle(method, pattern string, handler func(w http.ResponseWriter, r *request)) {
+	s.routes = append(s.routes, route{
+		method:  method,
+		pattern: strings.Split(strings.Trim(pattern, "/"), "/"),
+		handler: handler,
+	})
+}
+
+func (s *Server) registerRoutes() {
+	s.handle("GET", "/user", s.currentUser)
+	s.handle("GET", "/users", s.listUsers)
+	s.handle("GET", "/users/:user", s.getUser)
+
+	s.handle("GET", "/groups/:group/members/all", s.listGroupMembers)
+	s.handle("GET", "/groups/:group/variables", s.listVariables)
+	s.handle("POST", "/groups/:group/variables", s.createVariable)
+	s.handle("GET", "/groups/:group/variables/:key", s.getVariable)
+	s.handle("PUT", "/groups/:group/variables/:key", s.updateVariable)
+	s.handle("DELETE", "/groups/:group/variables/:key", s.deleteVariable)
+
+	s.handle("GET", "/projects", s.listProjects)
+	s.handle("POST", "/projects", s.createProject)
+	s.handle("GET", "/projects/:id", s.getProject)
+	s.handle("DELETE", "/projects/:id", s.deleteProject)
+	s.handle("POST", "/projects/:id/fork", s.forkProject)
+	s.handle("GET", "/projects/:id/repository/branches/:branch", s.getBranch)
+	s.handle("GET", "/projects/:id/repository/compare", s.compare)
+	s.handle("GET", "/projects/:id/labels", s.listLabels)
+	s.handle("GET", "/projects/:id/members/all", s.listProjectMembers)
+	s.handle("GET", "/projects/:id/milestones", s.listMilestones)
+	s.handle("GET", "/projects/:id/variables", s.listVariables)
+	s.handle("POST", "/projects/:id/variables", s.createVariable)
+	s.handle("GET", "/projects/:id/variables/:key", s.getVariable)
+	s.handle("PUT", "/projects/:id/variables/:key", s.updateVariable)
+	s.handle("DELETE", "/projects/:id/variables/:key", s.deleteVariable)
+
+	s.handle("GET", "/projects/:id/issues", s.listIssues)
+	s.handle("POST", "/projects/:id/issues", s.createIssue)
+	s.handle("GET", "/projects/:id/issues/:iid", s.getIssue)
+	s.handle("PUT", "/projects/:id/issues/:iid", s.updateIssue)
+
+	s.handle("GET", "/projects/:id/merge_requests", s.listMergeRequests)
+	s.handle("POST", "/projects/:id/merge_requests", s.createMergeRequest)
+	s.handle("GET", "/projects/:id/merge_requests/:iid", s.getMergeRequest)
+	s.handle("PUT", "/projects/:id/merge_requests/:iid", s.updateMergeRequest)
+	s.handle("GET", "/projects/:id/merge_requests/:iid/changes", s.getMergeRequestChanges)
+	s.handle("GET", "/projects/:id/merge_requests/:iid/versions", s.listMergeRequestVersions)
+	s.handle("GET", "/projects/:id/merge_requests/:iid/versions/:version", s.getMergeRequestVersion)
+	s.handle("PUT", "/projects/:id/merge_requests/:iid/merge", s.mergeMergeRequest)
+	s.handle("PUT", "/projects/:id/merge_requests/:iid/rebase", s.rebaseMergeRequest)
+	s.handle("POST", "/projects/:id/merge_requests/:iid/approve", s.approveMergeRequest)
+	s.handle("POST", "/projects/:id/merge_requests/:iid/unapprove", s.unapproveMergeRequest)
+	s.handle("GET", "/projects/:id/merge_requests/:iid/approvals", s.getMergeRequestApprovals)
+	s.handle("GET", "/projects/:id/merge_requests/:iid/pipelines", s.listMergeRequestPipelines)
+	s.handle("PUT", "/projects/:id/merge_requests/:iid/discussions/:discussion", s.resolveDiscussion)
+	s.handle("POST", "/projects/:id/merge_requests/:iid/award_emoji", s.awardMergeRequest)
+
+	for _, noteable := range []string{"issues", "merge_requests"} {
+		s.handle("GET", "/projects/:id/"+noteable+"/:iid/notes", s.listNotes)
+		s.handle("POST", "/projects/:id/"+noteable+"/:iid/notes", s.createNote)
+		s.handle("GET", "/projects/:id/"+noteable+"/:iid/discussions", s.listDiscussions)
+		s.handle("POST", "/projects/:id/"+noteable+"/:iid/discussions", s.createDiscussion)
+		s.handle("POST", "/projects/:id/"+noteable+"/:iid/discussions/:discussion/notes", s.addDiscussionNote)
+	}
+
+	s.handle("GET", "/projects/:id/pipelines", s.listPipelines)
+	s.handle("POST", "/projects/:id/pipeline", s.createPipeline)
+	s.handle("POST", "/projects/:id/trigger/pipeline", s.createPipeline)
+	s.handle("GET", "/projects/:id/pipelines/:pipeline", s.getPipeline)
+	s.handle("GET", "/projects/:id/pipelines/:pipeline/jobs", s.listPipelineJobs)
+	s.handle("GET", "/projects/:id/pipelines/:pipeline/variables", s.listPipelineVariables)
+	s.handle("GET", "/projects/:id/jobs/:job/trace", s.getTrace)
+	s.handle("GET", "/projects/:id/jobs/:job/artifacts", s.getArtifacts)
+	s.handle("GET", "/projects/:id/jobs/artifacts/:ref/download", s.getLatestArtifacts)
+	s.handle("POST", "/projects/:id/jobs/:job/play", s.playJob)
+	s.handle("POST", "/projects/:id/jobs/:job/retry", s.retryJob)
+	s.handle("POST", "/projects/:id/jobs/:job/cancel", s.cancelJob)
+	s.handle("POST", "/ci/lint", s.lint)
+
+	s.handle("GET", "/snippets", s.listSnippets)
+	s.handle("POST", "/snippets", s.createSnippet)
+	s.handle("GET", "/snippets/:snippet", s.getSnippet)
+	s.handle("GET", "/snippets/:snippet/raw", s.getSnippetRaw)
+	s.handle("DELETE", "/snippets/:snippet", s.deleteSnippet)
+	s.handle("GET", "/projects/:id/snippets", s.listSnippets)
+	s.handle("POST", "/projects/:id/snippets", s.createSnippet)
+	s.handle("GET", "/projects/:id/snippets/:snippet", s.getSnippet)
+	s.handle("GET", "/projects/:id/snippets/:snippet/raw", s.getSnippetRaw)
+	s.handle("DELETE", "/projects/:id/snippets/:snippet", s.deleteSnippet)
+}
+
+// ServeHTTP implements http.Handler. Requests are served one at a time so
+// handlers don't need to worry about locking.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := strings.TrimPrefix(r.URL.EscapedPath(), "/api/v4")
+	if p == r.URL.EscapedPath() {
+		writeError(w, http.StatusNotFound, "404 Not Found")
+		return
+	}
+	segments := strings.Split(strings.Trim(p, "/"), "/")
+	for _, rt := range s.routes {
+		if rt.method != r.Method {
+			continue
+		}
+		params, ok := match(rt.pattern, segments)
+		if !ok {
+			continue
+		}
+		user := s.authenticate(r)
+		if user == nil {
+			writeError(w, http.StatusUnauthorized, "401 Unauthorized")
+			return
+		}
+		rt.handler(w, &request{Request: r, user: user, params: params})
+		return
+	}
+	writeError(w, http.StatusNotFound, "404 Not Found")
+}
+
+// match returns the parameters of pattern when it matches the escaped path
+// segments. Parameters are unescaped, so a project may be given by its
+// encoded path as well as its ID.
+func match(pattern, segments []string) (map[string]string, bool) {
+	if len(pattern) != len(segments) {
+		return nil, false
+	}
+	params := make(map[string]string)
+	for i, p := range pattern {
+		seg, err := url.PathUnescape(segments[i])
+		if err != nil {
+			return nil, false
+		}
+		if strings.HasPrefix(p, ":") {
+			params[p[1:]] = seg
+			continue
+		}
+		if p != seg {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// authenticate returns the user owning the token sent with r
+func (s *Server) authenticate(r *http.Request) *gitlab.User {
+	token := r.Header.Get("Private-Token")
+	if token == "" {
+		token = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+	return s.tokens[token]
+}
+
+func (s *Server) nextID() int {
+	s.lastID++
+	return s.lastID
+}
+
+// now returns the time used for created_at and updated_at fields
+func now() *time.Time {
+	t := time.Now().UTC().Truncate(time.Second)
+	return &t
+}
+
+// host returns the host part of WebURL, used in SSH clone URLs
+func (s *Server) host() string {
+	u, err := url.Parse(s.WebURL)
+	if err != nil {
+		return s.WebURL
+	}
+	return u.Hostname()
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error in the format used by the GitLab API, which
+// go-gitlab includes in the errors it returns
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"message": message})
+}
+
+// writePage writes the page of list requested by r, setting the pagination
+// headers the same way GitLab does. list must be a slice.
+func writePage(w http.ResponseWriter, r *request, list interface{}) {
+	v := reflect.ValueOf(list)
+	total := v.Len()
+
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if perPage <= 0 {
+		perPage = 20
+	}
+	if perPage > 100 {
+		perPage = 100
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page <= 0 {
+		page = 1
+	}
+	totalPages := (total + perPage - 1) / perPage
+	if totalPages == 0 {
+		totalPages = 1
+	}
+
+	h := w.Header()
+	h.Set("X-Page", strconv.Itoa(page))
+	h.Set("X-Per-Page", strconv.Itoa(perPage))
+	h.Set("X-Total", strconv.Itoa(total))
+	h.Set("X-Total-Pages", strconv.Itoa(totalPages))
+	h.Set("X-Next-Page", "")
+	h.Set("X-Prev-Page", "")
+	if page < totalPages {
+		h.Set("X-Next-Page", strconv.Itoa(page+1))
+	}
+	if page > 1 {
+		h.Set("X-Prev-Page", strconv.Itoa(page-1))
+	}
+
+	start, end := (page-1)*perPage, page*perPage
+	if start > total {
+		start = total
+	}
+	if end > total {
+		end = total
+	}
+	writeJSON(w, http.StatusOK, v.Slice(start, end).Interface())
+}
+
+// decode reads the JSON body of r into v. go-gitlab sends the options of
+// POST and PUT requests as JSON, but the query string is also accepted for
+// requests made by hand.
+func decode(r *request, v interface{}) error {
+	if r.Body != nil && r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+			return err
+		}
+	}
+	q := r.URL.Query()
+	if len(q) == 0 {
+		return nil
+	}
+	m := make(map[string]interface{})
+	for k := range q {
+		m[k] = q.Get(k)
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	// Values sent in the query are all strings, so ignore type errors
+	// for fields which expect numbers or booleans
+	json.Unmarshal(b, v)
+	return nil
+}
+
+// labels decodes the comma separated list sent by go-gitlab, as well as a
+// JSON list
+type labels []string
+
+func (l *labels) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*l = splitList(s)
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// splitList splits a comma separated query value, ignoring empty entries
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// intParam returns the parameter name of the route as an int, writing a 404
+// when it isn't a number
+func intParam(w http.ResponseWriter, r *request, name string) (int, bool) {
+	n, err := strconv.Atoi(r.params[name])
+	if err != nil {
+		writeError(w, http.StatusNotFound, "404 Not Found")
+		return 0, false
+	}
+	return n, true
+}
+
+// containsFold reports whether substr is within s, ignoring case, as
+// GitLab's search parameter does
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// hasLabels reports whether have includes every label of want
+func hasLabels(have []string, want []string) bool {
+	for _, w := range want {
+		found := false
+		for _, h := range have {
+			if strings.EqualFold(h, w) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// contains reports whether list includes s
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// notFound writes the 404 GitLab returns for a missing object of kind
+func notFound(w http.ResponseWriter, kind string) {
+	writeError(w, http.StatusNotFound, fmt.Sprintf("404 %s Not Found", kind))
+}
@@ -0,0 +1,182 @@
+package gitlabtest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+func newClient(t *testing.T, s *Server, token string) *gitlab.Client {
+	c := gitlab.NewClient(nil, token)
+	require.NoError(t, c.SetBaseURL(s.URL+"/api/v4"))
+	return c
+}
+
+func TestUnauthorized(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	Seed(s)
+
+	_, resp, err := newClient(t, s, "bad token").Users.CurrentUser()
+	require.Error(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	u, _, err := newClient(t, s, Token).Users.CurrentUser()
+	require.NoError(t, err)
+	assert.Equal(t, "lab-testing", u.Username)
+}
+
+func TestGetProjectByPath(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.WebURL = "https://gitlab.com"
+	Seed(s)
+	c := newClient(t, s, Token)
+
+	p, _, err := c.Projects.GetProject("lab-testing/test")
+	require.NoError(t, err)
+	assert.Equal(t, 5694926, p.ID)
+	assert.Equal(t, "https://gitlab.com/lab-testing/test", p.WebURL)
+	assert.Equal(t, "git@gitlab.com:lab-testing/test.git", p.SSHURLToRepo)
+	assert.Equal(t, "zaquestion/test", p.ForkedFromProject.PathWithNamespace)
+
+	_, resp, err := c.Projects.GetProject("lab-testing/missing")
+	require.Error(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestPagination(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	Seed(s)
+	c := newClient(t, s, Token)
+
+	opt := &gitlab.ListProjectsOptions{ListOptions: gitlab.ListOptions{PerPage: 50}}
+	var all []*gitlab.Project
+	for {
+		projects, resp, err := c.Projects.ListProjects(opt)
+		require.NoError(t, err)
+		require.True(t, len(projects) <= 50)
+		all = append(all, projects...)
+		if resp.NextPage == 0 {
+			assert.Equal(t, resp.TotalPages, resp.CurrentPage)
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	assert.Equal(t, 123, len(all))
+}
+
+func TestIssueRoundTrip(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	Seed(s)
+	c := newClient(t, s, Token)
+
+	issue, _, err := c.Issues.CreateIssue("lab-testing/test", &gitlab.CreateIssueOptions{
+		Title:  gitlab.String("issue title"),
+		Labels: gitlab.Labels{"bug", "new"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, issue.IID)
+	assert.Equal(t, "lab-testing", issue.Author.Username)
+
+	_, _, err = c.Issues.UpdateIssue("lab-testing/test", issue.IID, &gitlab.UpdateIssueOptions{
+		StateEvent: gitlab.String("close"),
+	})
+	require.NoError(t, err)
+
+	issues, _, err := c.Issues.ListProjectIssues("lab-testing/test", &gitlab.ListProjectIssuesOptions{
+		State:  gitlab.String("closed"),
+		Labels: gitlab.Labels{"new"},
+	})
+	require.NoError(t, err)
+	require.Len(t, issues, 1)
+	assert.Equal(t, "issue title", issues[0].Title)
+
+	labels, _, err := c.Labels.ListLabels("lab-testing/test", nil)
+	require.NoError(t, err)
+	assert.Len(t, labels, 3, "Expected the new label to be created")
+}
+
+func TestNotes(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	Seed(s)
+	c := newClient(t, s, Token)
+
+	note, _, err := c.Notes.CreateMergeRequestNote("lab-testing/test", 1, &gitlab.CreateMergeRequestNoteOptions{
+		Body: gitlab.String("note text"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, note.NoteableIID)
+
+	discussions, _, err := c.Discussions.ListMergeRequestDiscussions("lab-testing/test", 1, nil)
+	require.NoError(t, err)
+	require.Len(t, discussions, 1)
+	assert.True(t, discussions[0].IndividualNote)
+	assert.Equal(t, "note text", discussions[0].Notes[0].Body)
+
+	_, resp, err := c.Notes.CreateIssueNote("lab-testing/test", 99, &gitlab.CreateIssueNoteOptions{
+		Body: gitlab.String("note text"),
+	})
+	require.Error(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestCreateMergeRequestConflict(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	Seed(s)
+	c := newClient(t, s, Token)
+
+	opt := &gitlab.CreateMergeRequestOptions{
+		Title:           gitlab.String("mr title"),
+		SourceBranch:    gitlab.String("mrtest"),
+		TargetBranch:    gitlab.String("master"),
+		TargetProjectID: gitlab.Int(4181224),
+	}
+	mr, _, err := c.MergeRequests.CreateMergeRequest("lab-testing/test", opt)
+	require.NoError(t, err)
+	assert.Equal(t, 4181224, mr.ProjectID)
+	assert.Equal(t, 5694926, mr.SourceProjectID)
+
+	_, resp, err := c.MergeRequests.CreateMergeRequest("lab-testing/test", opt)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+}
+
+func TestPipelineJobs(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	Seed(s)
+	c := newClient(t, s, Token)
+
+	pipelines, _, err := c.Pipelines.ListProjectPipelines(4181224, &gitlab.ListProjectPipelinesOptions{
+		Ref: gitlab.String("ci_test_pipeline"),
+	})
+	require.NoError(t, err)
+	require.Len(t, pipelines, 1)
+
+	jobs, _, err := c.Jobs.ListPipelineJobs(4181224, pipelines[0].ID, &gitlab.ListJobsOptions{
+		ListOptions: gitlab.ListOptions{PerPage: 100},
+	})
+	require.NoError(t, err)
+	require.Len(t, jobs, 19)
+	// The newest job comes first
+	assert.Equal(t, "build1", jobs[18].Name)
+
+	r, _, err := c.Jobs.GetTraceFile(4181224, jobs[18].ID)
+	require.NoError(t, err)
+	trace, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Contains(t, string(trace), "Job succeeded")
+
+	job, _, err := c.Jobs.PlayJob(4181224, jobs[9].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "pending", job.Status)
+}
@@ -0,0 +1,164 @@
+package gitlabtest
+
+import (
+	"net/http"
+	"strconv"
+
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// AddSnippet adds a snippet with the given content to the project pid, or a
+// personal snippet of its author when pid is 0
+func (s *Server) AddSnippet(pid int, snip *gitlab.Snippet, content string) *gitlab.Snippet {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var p *project
+	if pid != 0 {
+		p = s.mustProject(pid)
+	}
+	s.addSnippet(p, &snippet{Snippet: snip, content: content})
+	return snip
+}
+
+func (s *Server) addSnippet(p *project, snip *snippet) {
+	snip.ID = s.nextID()
+	if snip.CreatedAt == nil {
+		snip.CreatedAt = now()
+	}
+	if snip.UpdatedAt == nil {
+		snip.UpdatedAt = snip.CreatedAt
+	}
+	if p == nil {
+		snip.WebURL = s.WebURL + "/snippets/" + strconv.Itoa(snip.ID)
+		s.snippets = append(s.snippets, snip)
+	} else {
+		snip.WebURL = p.WebURL + "/snippets/" + strconv.Itoa(snip.ID)
+		p.snippets = append(p.snippets, snip)
+	}
+	snip.RawURL = snip.WebURL + "/raw"
+}
+
+// routeSnippets returns the snippets of the project in the route, or the
+// personal snippets. The project is nil for personal snippets.
+func (s *Server) routeSnippets(w http.ResponseWriter, r *request) (*project, []*snippet, bool) {
+	if _, ok := r.params["id"]; !ok {
+		return nil, s.snippets, true
+	}
+	p := s.routeProject(w, r)
+	if p == nil {
+		return nil, nil, false
+	}
+	return p, p.snippets, true
+}
+
+// routeSnippet returns the snippet of the :snippet route parameter, writing
+// a 404 when it doesn't exist
+func (s *Server) routeSnippet(w http.ResponseWriter, r *request) (*project, *snippet) {
+	p, list, ok := s.routeSnippets(w, r)
+	if !ok {
+		return nil, nil
+	}
+	id, _ := strconv.Atoi(r.params["snippet"])
+	for _, snip := range list {
+		if snip.ID == id {
+			return p, snip
+		}
+	}
+	notFound(w, "Snippet")
+	return nil, nil
+}
+
+func (s *Server) listSnippets(w http.ResponseWriter, r *request) {
+	p, snips, ok := s.routeSnippets(w, r)
+	if !ok {
+		return
+	}
+	list := []*gitlab.Snippet{}
+	// Newest first, as on GitLab
+	for i := len(snips) - 1; i >= 0; i-- {
+		// Personal snippets are only listed for their author
+		if p == nil && snips[i].Author.ID != r.user.ID {
+			continue
+		}
+		list = append(list, snips[i].Snippet)
+	}
+	writePage(w, r, list)
+}
+
+func (s *Server) getSnippet(w http.ResponseWriter, r *request) {
+	_, snip := s.routeSnippet(w, r)
+	if snip == nil {
+		return
+	}
+	writeJSON(w, http.StatusOK, snip.Snippet)
+}
+
+func (s *Server) getSnippetRaw(w http.ResponseWriter, r *request) {
+	_, snip := s.routeSnippet(w, r)
+	if snip == nil {
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(snip.content))
+}
+
+func (s *Server) createSnippet(w http.ResponseWriter, r *request) {
+	p, _, ok := s.routeSnippets(w, r)
+	if !ok {
+		return
+	}
+	var opt struct {
+		Title       string `json:"title"`
+		FileName    string `json:"file_name"`
+		Description string `json:"description"`
+		Content     string `json:"content"`
+		Code        string `json:"code"`
+	}
+	if err := decode(r, &opt); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// Project snippets used to take the content as code
+	if opt.Content == "" {
+		opt.Content = opt.Code
+	}
+	if opt.Title == "" || opt.Content == "" {
+		writeError(w, http.StatusBadRequest, "title and content are required")
+		return
+	}
+	snip := &snippet{
+		Snippet: &gitlab.Snippet{
+			Title:       opt.Title,
+			FileName:    opt.FileName,
+			Description: opt.Description,
+		},
+		content: opt.Content,
+	}
+	snip.Author.ID = r.user.ID
+	snip.Author.Username = r.user.Username
+	snip.Author.Name = r.user.Name
+	snip.Author.State = r.user.State
+	s.addSnippet(p, snip)
+	writeJSON(w, http.StatusCreated, snip.Snippet)
+}
+
+func (s *Server) deleteSnippet(w http.ResponseWriter, r *request) {
+	p, snip := s.routeSnippet(w, r)
+	if snip == nil {
+		return
+	}
+	remove := func(list []*snippet) []*snippet {
+		for i := range list {
+			if list[i] == snip {
+				return append(list[:i], list[i+1:]...)
+			}
+		}
+		return list
+	}
+	if p == nil {
+		s.snippets = remove(s.snippets)
+	} else {
+		p.snippets = remove(p.snippets)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
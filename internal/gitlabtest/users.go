@@ -0,0 +1,101 @@
+package gitlabtest
+
+import (
+	"net/http"
+	"strconv"
+
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// AddUser adds a user who authenticates with token. An empty token adds a
+// user who can't log in, such as the author of seeded objects.
+func (s *Server) AddUser(username, token string) *gitlab.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := &gitlab.User{
+		ID:        s.nextID(),
+		Username:  username,
+		Name:      username,
+		State:     "active",
+		CreatedAt: now(),
+	}
+	s.users = append(s.users, u)
+	if token != "" {
+		s.tokens[token] = u
+	}
+	return u
+}
+
+// user returns the user with the given username, or nil
+func (s *Server) user(username string) *gitlab.User {
+	for _, u := range s.users {
+		if u.Username == username {
+			return u
+		}
+	}
+	return nil
+}
+
+// userByID returns the user with the given ID, or nil
+func (s *Server) userByID(id int) *gitlab.User {
+	for _, u := range s.users {
+		if u.ID == id {
+			return u
+		}
+	}
+	return nil
+}
+
+func (s *Server) issueAuthor(u *gitlab.User) *gitlab.IssueAuthor {
+	return &gitlab.IssueAuthor{
+		ID:       u.ID,
+		State:    u.State,
+		Name:     u.Name,
+		Username: u.Username,
+		WebURL:   s.WebURL + "/" + u.Username,
+	}
+}
+
+func (s *Server) issueAssignee(u *gitlab.User) *gitlab.IssueAssignee {
+	return &gitlab.IssueAssignee{
+		ID:       u.ID,
+		State:    u.State,
+		Name:     u.Name,
+		Username: u.Username,
+		WebURL:   s.WebURL + "/" + u.Username,
+	}
+}
+
+func (s *Server) currentUser(w http.ResponseWriter, r *request) {
+	writeJSON(w, http.StatusOK, r.user)
+}
+
+func (s *Server) listUsers(w http.ResponseWriter, r *request) {
+	username := r.URL.Query().Get("username")
+	search := r.URL.Query().Get("search")
+	list := []*gitlab.User{}
+	for _, u := range s.users {
+		if username != "" && u.Username != username {
+			continue
+		}
+		if search != "" && !containsFold(u.Username, search) && !containsFold(u.Name, search) {
+			continue
+		}
+		list = append(list, u)
+	}
+	writePage(w, r, list)
+}
+
+func (s *Server) getUser(w http.ResponseWriter, r *request) {
+	id, err := strconv.Atoi(r.params["user"])
+	if err != nil {
+		notFound(w, "User")
+		return
+	}
+	u := s.userByID(id)
+	if u == nil {
+		notFound(w, "User")
+		return
+	}
+	writeJSON(w, http.StatusOK, u)
+}
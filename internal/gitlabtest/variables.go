@@ -0,0 +1,197 @@
+package gitlabtest
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// variable is a CI/CD variable of a project or a group
+type variable struct {
+	Key              string `json:"key"`
+	Value            string `json:"value"`
+	VariableType     string `json:"variable_type"`
+	Protected        bool   `json:"protected"`
+	Masked           bool   `json:"masked"`
+	EnvironmentScope string `json:"environment_scope"`
+}
+
+// variableKey is the format GitLab accepts for variable keys
+var variableKey = regexp.MustCompile(`^[a-zA-Z0-9_]{1,255}$`)
+
+// maskable reports whether value meets GitLab's requirements for masked
+// variables: a single line of at least 8 characters
+func maskable(value string) bool {
+	return len(value) >= 8 && !strings.ContainsAny(value, "\n\r")
+}
+
+// routeVariables returns the variables of the project of the :id route
+// parameter, or of the group of the :group route parameter, writing a 404
+// when it doesn't exist
+func (s *Server) routeVariables(w http.ResponseWriter, r *request) *[]*variable {
+	if _, ok := r.params["group"]; ok {
+		g := s.group(r.params["group"])
+		if g == nil {
+			notFound(w, "Group")
+			return nil
+		}
+		return &g.variables
+	}
+	p := s.routeProject(w, r)
+	if p == nil {
+		return nil
+	}
+	return &p.variables
+}
+
+// findVariable returns the index in vars of the variable :key with the
+// environment scope given by the filter[environment_scope] query parameter,
+// or by scope when not empty, or else of the first one with that key. It is
+// -1 when there is no such variable.
+func findVariable(vars []*variable, r *request, scope string) int {
+	if scope == "" {
+		scope = r.URL.Query().Get("filter[environment_scope]")
+	}
+	for i, v := range vars {
+		if v.Key == r.params["key"] && (scope == "" || v.EnvironmentScope == scope) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Server) listVariables(w http.ResponseWriter, r *request) {
+	vars := s.routeVariables(w, r)
+	if vars == nil {
+		return
+	}
+	writePage(w, r, append([]*variable{}, *vars...))
+}
+
+func (s *Server) getVariable(w http.ResponseWriter, r *request) {
+	vars := s.routeVariables(w, r)
+	if vars == nil {
+		return
+	}
+	i := findVariable(*vars, r, "")
+	if i < 0 {
+		notFound(w, "Variable")
+		return
+	}
+	writeJSON(w, http.StatusOK, (*vars)[i])
+}
+
+// variableOptions are the attributes of a variable sent on create and update
+type variableOptions struct {
+	Key              string  `json:"key"`
+	Value            *string `json:"value"`
+	VariableType     *string `json:"variable_type"`
+	Protected        *bool   `json:"protected"`
+	Masked           *bool   `json:"masked"`
+	EnvironmentScope *string `json:"environment_scope"`
+
+	// Filter picks the variable to update among those with the key, as
+	// the filter[environment_scope] parameter does
+	Filter struct {
+		EnvironmentScope string `json:"environment_scope"`
+	} `json:"filter"`
+}
+
+// apply sets the attributes given in opt on v
+func (opt *variableOptions) apply(v *variable) {
+	if opt.Value != nil {
+		v.Value = *opt.Value
+	}
+	if opt.VariableType != nil {
+		v.VariableType = *opt.VariableType
+	}
+	if opt.Protected != nil {
+		v.Protected = *opt.Protected
+	}
+	if opt.Masked != nil {
+		v.Masked = *opt.Masked
+	}
+	if opt.EnvironmentScope != nil {
+		v.EnvironmentScope = *opt.EnvironmentScope
+	}
+}
+
+// validateVariable returns the error GitLab gives for an invalid v among
+// vars, or "" when it is valid. old is the variable v updates, if any.
+func validateVariable(vars []*variable, v, old *variable) string {
+	if !variableKey.MatchString(v.Key) {
+		return "key can contain only letters, digits and '_'."
+	}
+	if v.VariableType != "env_var" && v.VariableType != "file" {
+		return "variable_type does not have a valid value"
+	}
+	if v.Masked && !maskable(v.Value) {
+		return "value is invalid"
+	}
+	for _, other := range vars {
+		if other != old && other.Key == v.Key && other.EnvironmentScope == v.EnvironmentScope {
+			return "(" + v.Key + ") has already been taken"
+		}
+	}
+	return ""
+}
+
+func (s *Server) createVariable(w http.ResponseWriter, r *request) {
+	vars := s.routeVariables(w, r)
+	if vars == nil {
+		return
+	}
+	var opt variableOptions
+	if err := decode(r, &opt); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	v := &variable{Key: opt.Key, VariableType: "env_var", EnvironmentScope: "*"}
+	opt.apply(v)
+	if msg := validateVariable(*vars, v, nil); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+	*vars = append(*vars, v)
+	writeJSON(w, http.StatusCreated, v)
+}
+
+func (s *Server) updateVariable(w http.ResponseWriter, r *request) {
+	vars := s.routeVariables(w, r)
+	if vars == nil {
+		return
+	}
+	var opt variableOptions
+	if err := decode(r, &opt); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	i := findVariable(*vars, r, opt.Filter.EnvironmentScope)
+	if i < 0 {
+		notFound(w, "Variable")
+		return
+	}
+	old := (*vars)[i]
+	v := *old
+	opt.apply(&v)
+	if msg := validateVariable(*vars, &v, old); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+	*old = v
+	writeJSON(w, http.StatusOK, old)
+}
+
+func (s *Server) deleteVariable(w http.ResponseWriter, r *request) {
+	vars := s.routeVariables(w, r)
+	if vars == nil {
+		return
+	}
+	i := findVariable(*vars, r, "")
+	if i < 0 {
+		notFound(w, "Variable")
+		return
+	}
+	*vars = append((*vars)[:i], (*vars)[i+1:]...)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -9,7 +9,6 @@ import (
 	"strings"
 
 	"github.com/spf13/viper"
-	gitlab "github.com/xanzy/go-gitlab"
 	"github.com/zaquestion/lab/cmd"
 	"github.com/zaquestion/lab/internal/config"
 	lab "github.com/zaquestion/lab/internal/gitlab"
@@ -18,7 +17,9 @@ import (
 // version gets set on releases during build by goreleaser.
 var version = "master"
 
-func loadConfig() (string, string, string) {
+// loadConfig returns the GitLab hosts lab is configured for. The first host
+// is the default, used when the host of a remote is not configured.
+func loadConfig() []lab.Config {
 	var home string
 	switch runtime.GOOS {
 	case "windows":
@@ -50,17 +51,14 @@ func loadConfig() (string, string, string) {
 	viper.AutomaticEnv()
 
 	host, user, token := viper.GetString("core.host"), viper.GetString("core.user"), viper.GetString("core.token")
-	if host != "" && user != "" && token != "" {
-		return host, user, token
-	} else if host != "" && token != "" {
-		user = getUser(host, token)
-		return host, user, token
+	if host != "" && token != "" {
+		return []lab.Config{{Host: host, User: user, Token: token}}
 	}
 
 	// Attempt to auto-configure for GitLab CI
 	host, user, token = config.CI()
 	if host != "" && user != "" && token != "" {
-		return host, user, token
+		return []lab.Config{{Host: host, User: user, Token: token}}
 	}
 
 	if _, ok := viper.ReadInConfig().(viper.ConfigFileNotFoundError); ok {
@@ -73,56 +71,62 @@ func loadConfig() (string, string, string) {
 		}
 	}
 
-	c := viper.AllSettings()["core"]
-	var cfg map[string]interface{}
-	switch v := c.(type) {
+	// Each "core" block configures one host
+	var blocks []map[string]interface{}
+	switch v := viper.AllSettings()["core"].(type) {
 	// Most run this is the type
 	case []map[string]interface{}:
-		cfg = v[0]
+		blocks = v
 	// On the first run when the cfg is created it comes in as this type
 	// for whatever reason
 	case map[string]interface{}:
-		cfg = v
+		blocks = []map[string]interface{}{v}
+	}
+	if len(blocks) == 0 {
+		log.Fatalf("missing config block core in %s", viper.ConfigFileUsed())
 	}
 
-	for _, v := range []string{"host", "token"} {
-		if cv, ok := cfg[v]; !ok {
-			log.Println(cv)
-			log.Fatalf("missing config value core.%s in %s", v, viper.ConfigFileUsed())
+	configs := make([]lab.Config, len(blocks))
+	for i, cfg := range blocks {
+		for _, v := range []string{"host", "token"} {
+			if cv, ok := cfg[v]; !ok {
+				log.Println(cv)
+				log.Fatalf("missing config value core.%s in %s", v, viper.ConfigFileUsed())
+			}
+		}
+		configs[i].Host = cfg["host"].(string)
+		configs[i].Token = cfg["token"].(string)
+		// The user is looked up using the token when not configured
+		if u, ok := cfg["user"].(string); ok {
+			configs[i].User = u
 		}
 	}
 
-	// Set environment overrides
-	// Note: the code below that uses `cfg["host"]` to access these values
-	// is tough to simplify since cfg["host"] is accessing the array "core"
-	// and viper.GetString("core.host") is expecting a non-array so it
-	// doens't match
+	// Set environment overrides on the default host
+	// Note: viper.GetString("core.host") can't see into the array "core"
+	// read from the config file, so only the environment is returned here
 	if v := viper.GetString("core.host"); v != "" {
-		cfg["host"] = v
+		configs[0].Host = v
 	}
 	if v := viper.GetString("core.token"); v != "" {
-		cfg["token"] = v
+		configs[0].Token = v
 	}
-	host = cfg["host"].(string)
-	token = cfg["token"].(string)
-	user = getUser(host, token)
-	viper.Set("core.user", user)
-	return host, user, token
-}
-
-func getUser(host, token string) string {
-	lab := gitlab.NewClient(nil, token)
-	lab.SetBaseURL(host + "/api/v4")
-	u, _, err := lab.Users.CurrentUser()
-	if err != nil {
-		log.Fatal(err)
+	if v := viper.GetString("core.user"); v != "" {
+		configs[0].User = v
 	}
-	return u.Username
+	return configs
 }
 
 func main() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 	cmd.Version = version
-	lab.Init(loadConfig())
-	cmd.Execute()
+	// Without a cache directory, such as when $HOME isn't set, nothing
+	// is cached
+	cacheDir, _ := lab.CacheDir()
+	var clients []*lab.Client
+	for _, c := range loadConfig() {
+		c.CacheDir = cacheDir
+		clients = append(clients, lab.NewClient(c))
+	}
+	cmd.Execute(clients)
 }